@@ -1,4 +1,5 @@
 -- Initialize DFS Database
+-- Baseline saja, perubahan skema berikutnya lewat migrasi naming service
 
 USE dfs_meta;
 
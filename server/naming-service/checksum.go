@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// parseClientDigest membaca checksum SHA256 yang dikirim client lewat header
+// X-Checksum-SHA256 (hex), header Digest (RFC 3230, "SHA-256=<base64>") atau
+// field form checksum_sha256. Mengembalikan hex lowercase, "" jika tidak ada.
+func parseClientDigest(header http.Header, formValue string) (string, error) {
+	if value := strings.TrimSpace(header.Get("X-Checksum-SHA256")); value != "" {
+		return normalizeHexChecksum(value)
+	}
+	if value := strings.TrimSpace(formValue); value != "" {
+		return normalizeHexChecksum(value)
+	}
+
+	for _, digest := range strings.Split(header.Get("Digest"), ",") {
+		algo, value, ok := strings.Cut(strings.TrimSpace(digest), "=")
+		if !ok || !strings.EqualFold(algo, "SHA-256") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(raw) != sha256.Size {
+			return "", fmt.Errorf("header Digest SHA-256 tidak valid")
+		}
+		return hex.EncodeToString(raw), nil
+	}
+
+	return "", nil
+}
+
+func normalizeHexChecksum(value string) (string, error) {
+	raw, err := hex.DecodeString(value)
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf("checksum SHA256 harus 64 karakter hex")
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// digestHeader mengubah checksum hex menjadi nilai header Digest
+func digestHeader(checksum string) string {
+	raw, err := hex.DecodeString(checksum)
+	if err != nil {
+		return ""
+	}
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(raw)
+}
+
+// hashMultipartFile menghitung SHA256 file upload (sudah di-spool oleh parser multipart)
+func hashMultipartFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// getObjectChecksum mengembalikan checksum yang tercatat untuk satu object:
+// checksum chunk jika chunkIndex terisi, selain itu checksum file utuh
+func getObjectChecksum(fileKey string, chunkIndex *int) (string, error) {
+	if chunkIndex == nil {
+		record, err := getFileRecord(fileKey)
+		if record == nil || err != nil {
+			return "", err
+		}
+		return record.ChecksumSHA256, nil
+	}
+
+	chunk, err := metadata.GetChunk(fileKey, *chunkIndex)
+	if chunk == nil || err != nil {
+		return "", err
+	}
+	return chunk.ChecksumSHA256, nil
+}
+
+// verifyStoredObject membandingkan checksum data yang dikirim naming service dengan
+// checksum yang dihitung storage node dan checksum yang seharusnya (jika ada)
+func verifyStoredObject(objectKey, sent, stored, expected string) error {
+	if stored != "" && !strings.EqualFold(stored, sent) {
+		return fmt.Errorf("%w: %s dikirim %s, node menyimpan %s", errChecksumMismatch, objectKey, sent, stored)
+	}
+	if expected != "" && !strings.EqualFold(expected, sent) {
+		return fmt.Errorf("%w: %s seharusnya %s, sumber mengirim %s", errChecksumMismatch, objectKey, expected, sent)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseClientDigest(t *testing.T) {
+	const hexSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	const b64Sum = "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="
+
+	tests := []struct {
+		name      string
+		header    map[string]string
+		formValue string
+		want      string
+		wantErr   bool
+	}{
+		{name: "no digest", want: ""},
+		{name: "hex header", header: map[string]string{"X-Checksum-SHA256": hexSum}, want: hexSum},
+		{name: "hex header uppercase", header: map[string]string{"X-Checksum-SHA256": " 2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824 "}, want: hexSum},
+		{name: "form field", formValue: hexSum, want: hexSum},
+		{name: "header wins over form", header: map[string]string{"X-Checksum-SHA256": hexSum}, formValue: "bad", want: hexSum},
+		{name: "digest header", header: map[string]string{"Digest": "SHA-256=" + b64Sum}, want: hexSum},
+		{name: "digest with other algorithms", header: map[string]string{"Digest": "md5=HUXZLQLMuI/KZ5KDcJPcOA==, sha-256=" + b64Sum}, want: hexSum},
+		{name: "digest without sha-256", header: map[string]string{"Digest": "md5=HUXZLQLMuI/KZ5KDcJPcOA=="}, want: ""},
+		{name: "short hex", header: map[string]string{"X-Checksum-SHA256": "abcd"}, wantErr: true},
+		{name: "non hex form", formValue: "zz" + hexSum[2:], wantErr: true},
+		{name: "invalid digest base64", header: map[string]string{"Digest": "SHA-256=!!!"}, wantErr: true},
+		{name: "digest wrong length", header: map[string]string{"Digest": "SHA-256=YWJj"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			got, err := parseClientDigest(header, tt.formValue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseClientDigest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestHeaderRoundTrip(t *testing.T) {
+	const hexSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	header := http.Header{}
+	header.Set("Digest", digestHeader(hexSum))
+	if got, err := parseClientDigest(header, ""); err != nil || got != hexSum {
+		t.Errorf("parseClientDigest(digestHeader) = %q, %v; want %q", got, err, hexSum)
+	}
+	if got := digestHeader("bukan-hex"); got != "" {
+		t.Errorf("digestHeader(bukan-hex) = %q, want empty", got)
+	}
+}
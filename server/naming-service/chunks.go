@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+)
+
+// Ukuran chunk untuk file besar. File yang lebih besar dari ukuran ini dipecah
+// menjadi beberapa chunk yang ditempatkan terpisah. 0 = chunking nonaktif.
+var chunkSizeBytes = int64(getEnvInt("CHUNK_SIZE_MB", 64)) << 20
+
+var errChunkReplicationNotReached = errors.New("chunk replication factor not reached")
+
+type FileChunk struct {
+	FileKey        string   `json:"file_key"`
+	ChunkIndex     int      `json:"chunk_index"`
+	SizeBytes      int64    `json:"size_bytes"`
+	ChecksumSHA256 string   `json:"checksum_sha256"`
+	Locations      []string `json:"locations"`
+}
+
+// chunkObjectKey adalah file_id yang dipakai storage node untuk menyimpan satu chunk
+func chunkObjectKey(fileKey string, index int) string {
+	return fmt.Sprintf("%s_chunk_%05d", fileKey, index)
+}
+
+func shouldChunk(sizeBytes int64) bool {
+	return chunkSizeBytes > 0 && sizeBytes > chunkSizeBytes
+}
+
+// storeChunkedFile memecah file menjadi chunk berukuran chunkSizeBytes dan
+// mengupload setiap chunk ke replicationFactor node. Placement digeser per chunk
+// supaya chunk tersebar di semua kandidat. Jika ada chunk yang tidak mencapai
+// replication factor, semua chunk yang sudah tersimpan dihapus lagi.
+func storeChunkedFile(fileKey string, file *multipart.FileHeader, candidates []Node, replicationFactor int) ([]FileChunk, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("gagal baca file: %v", err)
+	}
+	defer src.Close()
+
+	chunkCount := int((file.Size + chunkSizeBytes - 1) / chunkSizeBytes)
+	chunks := make([]FileChunk, 0, chunkCount)
+
+	rollback := func() {
+		deleteChunkObjects(fileKey, chunks, candidates)
+	}
+
+	for i := 0; i < chunkCount; i++ {
+		offset := int64(i) * chunkSizeBytes
+		size := chunkSizeBytes
+		if offset+size > file.Size {
+			size = file.Size - offset
+		}
+
+		// Hitung checksum chunk sebelum upload
+		chunkHasher := sha256.New()
+		if _, err := io.Copy(chunkHasher, io.NewSectionReader(src, offset, size)); err != nil {
+			rollback()
+			return nil, fmt.Errorf("gagal baca chunk %d: %v", i, err)
+		}
+
+		chunk := FileChunk{
+			FileKey:        fileKey,
+			ChunkIndex:     i,
+			SizeBytes:      size,
+			ChecksumSHA256: hex.EncodeToString(chunkHasher.Sum(nil)),
+		}
+
+		objectKey := chunkObjectKey(fileKey, i)
+		for _, node := range placementOrder(candidates, i) {
+			if len(chunk.Locations) == replicationFactor {
+				break
+			}
+			if err := uploadObjectToNode(node.Address, objectKey, objectKey, io.NewSectionReader(src, offset, size), chunk.ChecksumSHA256); err != nil {
+				log.Printf("❌ Upload chunk %d of %s to %s failed: %v\n", i, fileKey, node.ID, err)
+				continue
+			}
+			chunk.Locations = append(chunk.Locations, node.ID)
+		}
+
+		chunks = append(chunks, chunk)
+
+		if len(chunk.Locations) < replicationFactor {
+			rollback()
+			return nil, fmt.Errorf("%w: chunk %d stored on %d/%d nodes", errChunkReplicationNotReached, i, len(chunk.Locations), replicationFactor)
+		}
+	}
+
+	return chunks, nil
+}
+
+// deleteChunkObjects menghapus semua salinan chunk yang sudah tersimpan,
+// dipakai saat upload chunked atau pendaftaran metadatanya gagal
+func deleteChunkObjects(fileKey string, chunks []FileChunk, nodes []Node) {
+	addrMap := make(map[string]string)
+	for _, n := range nodes {
+		addrMap[n.ID] = n.Address
+	}
+	for _, chunk := range chunks {
+		for _, nodeID := range chunk.Locations {
+			if _, err := deleteFileFromNode(addrMap[nodeID], chunkObjectKey(fileKey, chunk.ChunkIndex)); err != nil {
+				log.Printf("⚠️ Rollback delete chunk %d of %s from %s failed: %v\n", chunk.ChunkIndex, fileKey, nodeID, err)
+			}
+		}
+	}
+}
+
+// uploadObjectToNode mengupload satu object (chunk/shard/part) ke storage node tanpa replikasi otomatis
+func uploadObjectToNode(nodeAddr, objectKey, filename string, src io.Reader, expectedChecksum string) error {
+	_, err := sendObjectToNode(nodeAddr, objectKey, filename, src, expectedChecksum)
+	return err
+}
+
+// getChunkManifest mengembalikan semua chunk sebuah file (urut index) dengan lokasi ACTIVE-nya
+func getChunkManifest(fileKey string) ([]FileChunk, error) {
+	return metadata.GetChunkManifest(fileKey)
+}
+
+func markChunkLocationActive(fileKey string, chunkIndex int, nodeID string) error {
+	return metadata.SetChunkLocationActive(fileKey, chunkIndex, nodeID)
+}
+
+// getChunkedFileNodes mengembalikan node yang menyimpan minimal satu chunk file
+func getChunkedFileNodes(fileKey string) ([]string, error) {
+	return metadata.GetChunkedFileNodes(fileKey)
+}
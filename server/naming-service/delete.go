@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// objectDeleteResult adalah hasil hapus satu object dari node-node-nya
+type objectDeleteResult struct {
+	Deleted      int
+	Failed       int
+	TotalNodes   int
+	DeletedNodes []string
+	// Delete yang ditunda sebagai tombstone (node DOWN atau delete gagal)
+	Deferred int
+}
+
+func (r *objectDeleteResult) add(other objectDeleteResult) {
+	r.Deleted += other.Deleted
+	r.Failed += other.Failed
+	r.TotalNodes += other.TotalNodes
+	r.DeletedNodes = append(r.DeletedNodes, other.DeletedNodes...)
+	r.Deferred += other.Deferred
+}
+
+// deleteStoredObject menghapus blob satu object (salinan utuh, chunk, atau
+// shard erasure) dari semua node yang tercatat, lalu menghapus metadatanya
+// (replication_queue, file_locations, chunk manifest, files) dalam satu
+// transaksi. Blob di node yang DOWN atau gagal dihapus dicatat sebagai
+// tombstone di transaksi yang sama dan dihapus oleh tombstone replayer saat
+// node UP. Jika metadata gagal dihapus, caller bisa mengulang.
+func deleteStoredObject(objectKey string, nodes []Node) (objectDeleteResult, error) {
+	var result objectDeleteResult
+
+	// Semua baris lokasi ikut dihapus, bukan hanya yang ACTIVE: salinan yang
+	// ditandai CORRUPT / MISSING oleh scrubber atau DELETED oleh drain dan
+	// rebalance bisa masih ada di node dan jadi orphan setelah metadata hilang
+	locations, err := metadata.ListObjectLocations(objectKey)
+	if err != nil {
+		return result, err
+	}
+
+	// Jika tidak ada di metadata, coba delete dari semua nodes yang terdaftar (fallback)
+	fallback := false
+	if len(locations) == 0 {
+		record, err := getFileRecord(objectKey)
+		if err != nil {
+			return result, err
+		}
+		fallback = record == nil
+		for _, nodeID := range getActiveNodeIDs(nodes) {
+			locations = append(locations, ObjectLocation{NodeID: nodeID, BlobKey: objectKey})
+		}
+		log.Printf("⚠️ File %s not found in file_locations, trying all nodes\n", objectKey)
+	}
+
+	holders := make(map[string]bool)
+	for _, loc := range locations {
+		holders[loc.NodeID] = true
+	}
+	result.TotalNodes = len(holders)
+
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	var tombstones []DeleteTombstone
+	deletedSet := make(map[string]bool)
+	deleteBlob := func(nodeID, blobKey string) {
+		node, ok := nodeByID[nodeID]
+		if !ok {
+			log.Printf("⚠️ Node %s not found in nodeMap, delete of %s skipped\n", nodeID, blobKey)
+			result.Failed++
+			return
+		}
+
+		// Node RETIRED tidak akan kembali, tombstone-nya tidak akan pernah diputar
+		if node.Status == "RETIRED" {
+			log.Printf("ℹ️ %s is RETIRED, skipping delete of %s\n", nodeID, blobKey)
+			return
+		}
+
+		// Node DOWN tidak dicoba; fallback ke semua node juga tidak meninggalkan
+		// tombstone karena belum tentu node itu pernah menyimpan object-nya
+		if node.Status == "DOWN" {
+			if !fallback {
+				tombstones = append(tombstones, DeleteTombstone{NodeID: nodeID, FileKey: objectKey, BlobKey: blobKey})
+				log.Printf("🪦 %s is DOWN, delete of %s deferred\n", nodeID, blobKey)
+			}
+			return
+		}
+
+		status, err := deleteFileFromNode(node.Address, blobKey)
+		switch {
+		case err == nil && status == 200:
+			result.Deleted++
+			deletedSet[nodeID] = true
+			log.Printf("✅ Deleted %s from %s\n", blobKey, nodeID)
+		case err == nil && status == 404:
+			// File not found on this node, not an error
+			log.Printf("ℹ️ %s not found on %s (already deleted or never existed)\n", blobKey, nodeID)
+		default:
+			log.Printf("❌ Failed to delete %s from %s (status %d): %v\n", blobKey, nodeID, status, err)
+			result.Failed++
+			if !fallback {
+				tombstones = append(tombstones, DeleteTombstone{NodeID: nodeID, FileKey: objectKey, BlobKey: blobKey})
+			}
+		}
+	}
+
+	for _, loc := range locations {
+		deleteBlob(loc.NodeID, loc.BlobKey)
+	}
+
+	for nodeID := range deletedSet {
+		result.DeletedNodes = append(result.DeletedNodes, nodeID)
+	}
+	sort.Strings(result.DeletedNodes)
+
+	if err := metadata.DeleteFile(objectKey, tombstones); err != nil {
+		return result, err
+	}
+	result.Deferred = len(tombstones)
+	return result, nil
+}
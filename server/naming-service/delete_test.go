@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestDeleteStoredObjectTombstones(t *testing.T) {
+	s := newTestBoltStore(t)
+	prev := metadata
+	metadata = s
+	t.Cleanup(func() { metadata = prev })
+
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "f", SizeBytes: 1, NodeIDs: []string{"n1", "n2", "n3"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Salinan yang ditandai scrubber tetap harus dihapus dari node-nya
+	if err := s.UpdateLocationStatus("f", nil, "n2", "CORRUPT"); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []Node{
+		{ID: "n1", Status: "DOWN"},
+		{ID: "n2", Status: "DOWN"},
+		{ID: "n3", Status: "RETIRED"},
+	}
+	result, err := deleteStoredObject("f", nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deferred != 2 || result.Failed != 0 || result.TotalNodes != 3 {
+		t.Errorf("result = %+v, want 2 deferred and no failures", result)
+	}
+
+	tombstones, err := s.ListTombstones("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, ts := range tombstones {
+		got[ts.NodeID] = ts.BlobKey
+	}
+	if len(got) != 2 || got["n1"] != "f" || got["n2"] != "f" {
+		t.Errorf("tombstones = %+v, want n1 and n2 only", tombstones)
+	}
+	if f, _ := s.GetFile("f"); f != nil {
+		t.Errorf("GetFile after delete = %+v", f)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Failure domain menentukan node mana yang bisa gagal bersamaan (satu rack atau
+// satu zone). Salinan satu object sebisa mungkin ditempatkan di domain berbeda;
+// dengan FAILURE_DOMAIN_STRICT=true node di domain yang sudah terpakai tidak
+// dipakai sama sekali, sehingga upload gagal jika domain tidak cukup.
+var (
+	failureDomainLevel  = strings.ToLower(getEnv("FAILURE_DOMAIN_LEVEL", "rack"))
+	failureDomainStrict = getEnv("FAILURE_DOMAIN_STRICT", "false") == "true"
+)
+
+// failureDomain mengembalikan kunci domain node. Node tanpa label dianggap
+// domain sendiri supaya cluster tanpa topologi tetap berjalan seperti biasa.
+func failureDomain(n Node) string {
+	if failureDomainLevel == "rack" && n.Rack != "" {
+		return "rack:" + n.Zone + "/" + n.Rack
+	}
+	if n.Zone != "" {
+		return "zone:" + n.Zone
+	}
+	return "node:" + n.ID
+}
+
+// spreadAcrossDomains mengurutkan ulang kandidat (urutan policy dipertahankan)
+// sehingga node pertama dari setiap domain yang belum ada di used didahulukan.
+// Sisanya ditaruh di belakang sebagai cadangan, atau dibuang jika strict.
+func spreadAcrossDomains(nodes []Node, used map[string]bool) []Node {
+	seen := make(map[string]bool, len(used))
+	for domain := range used {
+		seen[domain] = true
+	}
+
+	spread := make([]Node, 0, len(nodes))
+	var rest []Node
+	for _, n := range nodes {
+		domain := failureDomain(n)
+		if seen[domain] {
+			rest = append(rest, n)
+			continue
+		}
+		seen[domain] = true
+		spread = append(spread, n)
+	}
+
+	if failureDomainStrict {
+		return spread
+	}
+	return append(spread, rest...)
+}
+
+// spreadWriteTiers menyebarkan node ready ke domain berbeda, lalu node cadangan
+// di belakangnya. Domain yang sudah dipakai node ready ikut dihitung untuk
+// cadangan, tapi cadangan tidak pernah mendahului node ready.
+func spreadWriteTiers(ready, fallback []Node, used map[string]bool) []Node {
+	spread := spreadAcrossDomains(ready, used)
+	taken := make(map[string]bool, len(used)+len(spread))
+	for domain := range used {
+		taken[domain] = true
+	}
+	for _, n := range spread {
+		taken[failureDomain(n)] = true
+	}
+	return append(spread, spreadAcrossDomains(fallback, taken)...)
+}
+
+// placementOrder memutar node ready di daftar kandidat mulai dari start (supaya
+// chunk/part menyebar ke semua node) lalu menyebarkannya ke domain berbeda.
+// Cadangan dari rankForWrite tetap di belakang.
+func placementOrder(candidates []Node, start int) []Node {
+	if len(candidates) == 0 {
+		return nil
+	}
+	var ready, fallback []Node
+	for _, n := range candidates {
+		if isWriteFallback(n) {
+			fallback = append(fallback, n)
+		} else {
+			ready = append(ready, n)
+		}
+	}
+	if len(ready) > 0 {
+		start %= len(ready)
+		ready = append(append([]Node(nil), ready[start:]...), ready[:start]...)
+	}
+	return spreadWriteTiers(ready, fallback, nil)
+}
+
+// domainsOf mengembalikan domain dari node-node yang menyimpan salinan
+func domainsOf(nodeIDs []string, nodeByID map[string]Node) map[string]bool {
+	domains := make(map[string]bool)
+	for _, nodeID := range nodeIDs {
+		if n, ok := nodeByID[nodeID]; ok {
+			domains[failureDomain(n)] = true
+		}
+	}
+	return domains
+}
+
+type PlacementViolation struct {
+	FileKey     string              `json:"file_key"`
+	ChunkIndex  *int                `json:"chunk_index,omitempty"`
+	StorageMode string              `json:"storage_mode"`
+	Copies      int                 `json:"copies"`
+	Domains     map[string][]string `json:"domains"`
+	// Fixable true jika cluster punya cukup domain untuk memisahkan semua salinan
+	Fixable bool `json:"fixable"`
+}
+
+// findPlacementViolations mencari file (atau chunk) yang punya lebih dari satu
+// salinan ACTIVE di failure domain yang sama. Shard erasure dihitung per file.
+func findPlacementViolations() ([]PlacementViolation, error) {
+	nodes, err := getAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeByID := make(map[string]Node)
+	clusterDomains := make(map[string]bool)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+		if n.Status != "RETIRED" {
+			clusterDomains[failureDomain(n)] = true
+		}
+	}
+
+	copies, err := metadata.ListObjectCopies("")
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*PlacementViolation)
+	var order []string
+	for _, c := range copies {
+		key := c.FileKey
+		if c.ChunkIndex != nil {
+			key = chunkObjectKey(c.FileKey, *c.ChunkIndex)
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &PlacementViolation{FileKey: c.FileKey, ChunkIndex: c.ChunkIndex, StorageMode: c.StorageMode, Domains: make(map[string][]string)}
+			groups[key] = group
+			order = append(order, key)
+		}
+
+		domain := "node:" + c.NodeID
+		if n, ok := nodeByID[c.NodeID]; ok {
+			domain = failureDomain(n)
+		}
+		group.Domains[domain] = append(group.Domains[domain], c.NodeID)
+		group.Copies++
+	}
+
+	violations := []PlacementViolation{}
+	for _, key := range order {
+		group := groups[key]
+		if len(group.Domains) == group.Copies {
+			continue
+		}
+		for _, nodeIDs := range group.Domains {
+			sort.Strings(nodeIDs)
+		}
+		group.Fixable = len(clusterDomains) >= group.Copies
+		violations = append(violations, *group)
+	}
+	return violations, nil
+}
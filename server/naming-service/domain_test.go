@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpreadAcrossDomains(t *testing.T) {
+	level, strict := failureDomainLevel, failureDomainStrict
+	t.Cleanup(func() { failureDomainLevel, failureDomainStrict = level, strict })
+
+	nodes := []Node{
+		{ID: "a1", Zone: "z1", Rack: "r1"},
+		{ID: "a2", Zone: "z1", Rack: "r1"},
+		{ID: "b1", Zone: "z1", Rack: "r2"},
+		{ID: "c1", Zone: "z2", Rack: "r3"},
+		{ID: "x"},
+	}
+
+	tests := []struct {
+		name   string
+		level  string
+		strict bool
+		used   map[string]bool
+		want   []string
+	}{
+		{name: "rack level", level: "rack", want: []string{"a1", "b1", "c1", "x", "a2"}},
+		{name: "zone level", level: "zone", want: []string{"a1", "c1", "x", "a2", "b1"}},
+		{name: "used domain goes last", level: "rack", used: map[string]bool{"rack:z1/r1": true}, want: []string{"b1", "c1", "x", "a1", "a2"}},
+		{name: "strict drops duplicates", level: "rack", strict: true, want: []string{"a1", "b1", "c1", "x"}},
+		{name: "strict drops used domains", level: "zone", strict: true, used: map[string]bool{"zone:z1": true}, want: []string{"c1", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failureDomainLevel, failureDomainStrict = tt.level, tt.strict
+
+			var got []string
+			for _, n := range spreadAcrossDomains(nodes, tt.used) {
+				got = append(got, n.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spreadAcrossDomains = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureDomain(t *testing.T) {
+	level := failureDomainLevel
+	t.Cleanup(func() { failureDomainLevel = level })
+
+	racked := Node{ID: "n1", Zone: "z1", Rack: "r1"}
+	zoned := Node{ID: "n2", Zone: "z1"}
+	bare := Node{ID: "n3"}
+
+	failureDomainLevel = "rack"
+	if got := failureDomain(racked); got != "rack:z1/r1" {
+		t.Errorf("rack level, racked node = %q", got)
+	}
+	if got := failureDomain(zoned); got != "zone:z1" {
+		t.Errorf("rack level, node tanpa rack = %q", got)
+	}
+
+	failureDomainLevel = "zone"
+	if got := failureDomain(racked); got != "zone:z1" {
+		t.Errorf("zone level, racked node = %q", got)
+	}
+	if got := failureDomain(bare); got != "node:n3" {
+		t.Errorf("node tanpa label = %q", got)
+	}
+}
+
+func TestPlacementOrderRotatesThenSpreads(t *testing.T) {
+	level, strict := failureDomainLevel, failureDomainStrict
+	failureDomainLevel, failureDomainStrict = "zone", false
+	t.Cleanup(func() { failureDomainLevel, failureDomainStrict = level, strict })
+
+	nodes := []Node{{ID: "a", Zone: "z1"}, {ID: "b", Zone: "z1"}, {ID: "c", Zone: "z2"}}
+	var got []string
+	for _, n := range placementOrder(nodes, 4) {
+		got = append(got, n.ID)
+	}
+	// start 4 % 3 = 1: b, c, a -> a satu zone dengan b sehingga ke belakang
+	if want := []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("placementOrder = %v, want %v", got, want)
+	}
+	if placementOrder(nil, 3) != nil {
+		t.Errorf("placementOrder(nil) harus nil")
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// fileSource membuka isi file (atau object di storage node) mulai dari offset
+// tertentu. Reader yang dikembalikan sudah menangani failover ke sumber lain.
+type fileSource interface {
+	OpenAt(offset int64) (io.ReadCloser, error)
+}
+
+// openNodeObject membuka object di storage node mulai dari offset memakai header Range
+func openNodeObject(node Node, objectKey string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/files/%s", node.Address, objectKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// Node tidak mendukung Range, buang byte sebelum offset
+		if offset > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+		return resp.Body, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Offset tepat di akhir object
+		resp.Body.Close()
+		return http.NoBody, nil
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("node %s return status %d", node.ID, resp.StatusCode)
+	}
+}
+
+// orderSources mengurutkan node UP yang ada di nodeIDs memakai PLACEMENT_POLICY.
+// Salinan di node BACKUP selalu di urutan terakhir, jadi hanya dibaca jika
+// salinan lain gagal.
+func orderSources(nodeIDs []string, nodeByID map[string]Node) []Node {
+	var sources []Node
+	for _, nodeID := range nodeIDs {
+		if node, ok := nodeByID[nodeID]; ok && node.Status == "UP" {
+			sources = append(sources, node)
+		}
+	}
+	return coldBackupsLast(rankForRead(sources))
+}
+
+// replicaSource membaca satu object yang punya salinan utuh di beberapa node
+type replicaSource struct {
+	objectKey string
+	size      int64
+	nodes     []Node
+}
+
+func (s *replicaSource) OpenAt(offset int64) (io.ReadCloser, error) {
+	r := &failoverReader{source: s, offset: offset}
+	if err := r.reopen(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// failoverReader pindah ke replica berikutnya jika node yang sedang dibaca
+// terputus, lalu melanjutkan dari byte offset yang sudah terkirim
+type failoverReader struct {
+	source  *replicaSource
+	next    int
+	offset  int64
+	current string
+	body    io.ReadCloser
+}
+
+func (r *failoverReader) reopen() error {
+	for r.next < len(r.source.nodes) {
+		node := r.source.nodes[r.next]
+		r.next++
+
+		body, err := openNodeObject(node, r.source.objectKey, r.offset)
+		if err != nil {
+			log.Printf("⚠️ Cannot read %s from %s: %v\n", r.source.objectKey, node.ID, err)
+			continue
+		}
+		if r.current != "" {
+			log.Printf("🔁 Failover %s from %s to %s at offset %d\n", r.source.objectKey, r.current, node.ID, r.offset)
+		}
+		r.current = node.ID
+		r.body = body
+		return nil
+	}
+	return fmt.Errorf("tidak ada replica tersisa untuk %s", r.source.objectKey)
+}
+
+func (r *failoverReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.source.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+
+	log.Printf("⚠️ Read %s from %s failed at offset %d: %v\n", r.source.objectKey, r.current, r.offset, err)
+	r.body.Close()
+	r.body = http.NoBody
+	if reopenErr := r.reopen(); reopenErr != nil {
+		return n, err
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return r.Read(p)
+}
+
+func (r *failoverReader) Close() error {
+	return r.body.Close()
+}
+
+// chunkedSource membaca file CHUNKED dengan menyambung chunk secara berurutan
+type chunkedSource struct {
+	fileKey string
+	chunks  []FileChunk
+	sources [][]Node
+}
+
+func (s *chunkedSource) OpenAt(offset int64) (io.ReadCloser, error) {
+	r := &chunkedReader{source: s}
+	for r.index < len(s.chunks) && offset >= s.chunks[r.index].SizeBytes {
+		offset -= s.chunks[r.index].SizeBytes
+		r.index++
+	}
+	r.chunkOffset = offset
+	return r, nil
+}
+
+type chunkedReader struct {
+	source      *chunkedSource
+	index       int
+	chunkOffset int64
+	current     io.ReadCloser
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for {
+		if r.index >= len(r.source.chunks) {
+			return 0, io.EOF
+		}
+
+		if r.current == nil {
+			chunk := r.source.chunks[r.index]
+			replicas := &replicaSource{
+				objectKey: chunkObjectKey(r.source.fileKey, chunk.ChunkIndex),
+				size:      chunk.SizeBytes,
+				nodes:     r.source.sources[r.index],
+			}
+			rc, err := replicas.OpenAt(r.chunkOffset)
+			if err != nil {
+				return 0, err
+			}
+			r.current = rc
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			r.index++
+			r.chunkOffset = 0
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkedReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+type shardSource struct {
+	Index int
+	Node  Node
+}
+
+// erasureSource membaca file ERASURE per stripe dari k shard
+type erasureSource struct {
+	fileKey   string
+	size      int64
+	params    ErasureParams
+	available []shardSource
+}
+
+func (s *erasureSource) OpenAt(offset int64) (io.ReadCloser, error) {
+	enc, err := reedsolomon.New(s.params.DataShards, s.params.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	total := s.params.TotalShards()
+	stripeSize := int64(s.params.DataShards) * s.params.BlockSize
+
+	r := &erasureReader{
+		source:  s,
+		enc:     enc,
+		readers: make([]io.ReadCloser, total),
+		buffers: make([][]byte, total),
+		shards:  make([][]byte, total),
+		output:  make([]byte, stripeSize),
+		stripe:  offset / stripeSize,
+		skip:    offset % stripeSize,
+	}
+	for i := range r.buffers {
+		r.buffers[i] = make([]byte, s.params.BlockSize)
+	}
+
+	r.spare = append(r.spare, s.available...)
+	for opened := 0; opened < s.params.DataShards; opened++ {
+		if err := r.openSpare(); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+type erasureReader struct {
+	source  *erasureSource
+	enc     reedsolomon.Encoder
+	spare   []shardSource
+	readers []io.ReadCloser
+	buffers [][]byte
+	shards  [][]byte
+	output  []byte
+	pending []byte
+	stripe  int64 // index stripe berikutnya yang dibaca
+	skip    int64 // byte di awal stripe pertama yang tidak dikirim
+}
+
+// openSpare membuka shard cadangan berikutnya di posisi stripe saat ini
+func (r *erasureReader) openSpare() error {
+	for len(r.spare) > 0 {
+		source := r.spare[0]
+		r.spare = r.spare[1:]
+
+		objectKey := shardObjectKey(r.source.fileKey, source.Index)
+		body, err := openNodeObject(source.Node, objectKey, r.stripe*r.source.params.BlockSize)
+		if err != nil {
+			log.Printf("⚠️ Cannot read shard %d of %s from %s: %v\n", source.Index, r.source.fileKey, source.Node.ID, err)
+			continue
+		}
+		r.readers[source.Index] = body
+		return nil
+	}
+	return fmt.Errorf("shard yang tersedia kurang dari %d", r.source.params.DataShards)
+}
+
+func (r *erasureReader) decodeStripe() error {
+	k := r.source.params.DataShards
+
+	for i := range r.shards {
+		r.shards[i] = r.buffers[i][:0]
+	}
+
+	// Baca satu block dari setiap shard yang terbuka. Shard yang gagal ditutup
+	// dan diganti shard cadangan, yang dibaca ulang di putaran berikutnya.
+	for complete := false; !complete; {
+		complete = true
+		for i, rc := range r.readers {
+			if rc == nil || len(r.shards[i]) > 0 {
+				continue
+			}
+			if _, err := io.ReadFull(rc, r.buffers[i]); err != nil {
+				log.Printf("⚠️ Shard %d of %s failed at stripe %d: %v\n", i, r.source.fileKey, r.stripe, err)
+				rc.Close()
+				r.readers[i] = nil
+				if err := r.openSpare(); err != nil {
+					return err
+				}
+				complete = false
+				continue
+			}
+			r.shards[i] = r.buffers[i]
+		}
+	}
+
+	for i := 0; i < k; i++ {
+		if len(r.shards[i]) == 0 {
+			if err := r.enc.ReconstructData(r.shards); err != nil {
+				return fmt.Errorf("gagal rekonstruksi stripe %d: %v", r.stripe, err)
+			}
+			break
+		}
+	}
+
+	blockSize := r.source.params.BlockSize
+	for i := 0; i < k; i++ {
+		copy(r.output[int64(i)*blockSize:], r.shards[i])
+	}
+
+	stripeSize := int64(k) * blockSize
+	end := r.source.size - r.stripe*stripeSize
+	if end > stripeSize {
+		end = stripeSize
+	}
+	r.pending = r.output[r.skip:end]
+	r.skip = 0
+	r.stripe++
+
+	return nil
+}
+
+func (r *erasureReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		stripeSize := int64(r.source.params.DataShards) * r.source.params.BlockSize
+		if r.stripe*stripeSize >= r.source.size {
+			return 0, io.EOF
+		}
+		if err := r.decodeStripe(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *erasureReader) Close() error {
+	for _, rc := range r.readers {
+		if rc != nil {
+			rc.Close()
+		}
+	}
+	return nil
+}
+
+// remoteReadSeeker membungkus fileSource menjadi io.ReadSeeker untuk
+// http.ServeContent. Stream baru dibuka saat Read pertama setelah Seek.
+// Jika file dibaca utuh dari awal, isinya di-hash dan dicocokkan dengan checksum;
+// saat tidak cocok, byte terakhir ditahan supaya client tidak menerima file utuh.
+type remoteReadSeeker struct {
+	source   fileSource
+	size     int64
+	checksum string
+	offset   int64
+	body     io.ReadCloser
+	hasher   hash.Hash
+}
+
+func (r *remoteReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.source.OpenAt(r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	if r.offset == 0 && r.checksum != "" {
+		r.hasher = sha256.New()
+	}
+
+	n, err := r.body.Read(p)
+	if r.hasher != nil {
+		r.hasher.Write(p[:n])
+		if r.offset+int64(n) == r.size {
+			if actual := hex.EncodeToString(r.hasher.Sum(nil)); actual != r.checksum {
+				log.Printf("❌ Download aborted, checksum mismatch: expected %s, got %s\n", r.checksum, actual)
+				return 0, fmt.Errorf("%w: expected %s, got %s", errChecksumMismatch, r.checksum, actual)
+			}
+		}
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *remoteReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek sebelum awal file")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	r.hasher = nil
+	return offset, nil
+}
+
+func (r *remoteReadSeeker) Close() error {
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+var errNoSourceAvailable = errors.New("file not available on any UP node")
+
+// newFileSource menyiapkan sumber baca sesuai storage_mode file dan mengisi header
+// informasi routing. Error dikembalikan sebelum response dikirim jika metadata
+// menunjukkan file tidak bisa dibaca.
+func newFileSource(record *FileMetadata, nodes []Node, header http.Header) (fileSource, error) {
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	switch record.StorageMode {
+	case "CHUNKED":
+		chunks, err := getChunkManifest(record.FileKey)
+		if err != nil {
+			return nil, err
+		}
+		source := &chunkedSource{fileKey: record.FileKey, chunks: chunks, sources: make([][]Node, len(chunks))}
+		for i, chunk := range chunks {
+			source.sources[i] = orderSources(chunk.Locations, nodeByID)
+			if len(source.sources[i]) == 0 {
+				return nil, fmt.Errorf("%w: chunk %d", errNoSourceAvailable, chunk.ChunkIndex)
+			}
+		}
+		header.Set("X-Chunk-Count", strconv.Itoa(len(chunks)))
+		return source, nil
+
+	case "ERASURE":
+		params := ErasureParams{
+			DataShards:   record.ECDataShards,
+			ParityShards: record.ECParityShards,
+			BlockSize:    record.ECBlockSize,
+		}
+		shardByNode, err := getShardLocations(record.FileKey)
+		if err != nil {
+			return nil, err
+		}
+
+		source := &erasureSource{fileKey: record.FileKey, size: record.SizeBytes, params: params}
+		var holders []Node
+		for _, n := range nodes {
+			if index, ok := shardByNode[n.ID]; ok && n.Status == "UP" && index < params.TotalShards() {
+				holders = append(holders, n)
+			}
+		}
+		for _, n := range rankForRead(holders) {
+			source.available = append(source.available, shardSource{Index: shardByNode[n.ID], Node: n})
+		}
+		if len(source.available) < params.DataShards {
+			return nil, fmt.Errorf("%w: %d/%d shards", errNoSourceAvailable, len(source.available), params.DataShards)
+		}
+
+		// Data shard didahulukan karena tidak perlu decoding, lalu urutan policy
+		sort.SliceStable(source.available, func(i, j int) bool {
+			return source.available[i].Index < params.DataShards && source.available[j].Index >= params.DataShards
+		})
+
+		dataShards := 0
+		for _, shard := range source.available {
+			if shard.Index < params.DataShards {
+				dataShards++
+			}
+		}
+		header.Set("X-Erasure-Reconstructed", strconv.FormatBool(dataShards < params.DataShards))
+		return source, nil
+
+	default:
+		nodeIDs, err := getFileLocations(record.FileKey)
+		if err != nil {
+			return nil, err
+		}
+		replicas := orderSources(nodeIDs, nodeByID)
+		if len(replicas) == 0 {
+			return nil, errNoSourceAvailable
+		}
+		header.Set("X-Routed-From", replicas[0].ID)
+		header.Set("X-Node-Latency-Ms", fmt.Sprintf("%d", replicas[0].LatencyMs))
+		return &replicaSource{objectKey: record.FileKey, size: record.SizeBytes, nodes: replicas}, nil
+	}
+}
+
+// parseUploadedAt mengubah files.uploaded_at (hasil scan ke string) menjadi time.Time
+func parseUploadedAt(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Drain mengosongkan node sebelum dikeluarkan dari cluster. Node DRAINING tidak
+// dipilih untuk upload baru; setiap salinan ACTIVE di node tersebut disalin ke
+// node lain lewat replication_queue, dan salinan di node yang drain baru dilepas
+// setelah salinan lain cukup. Saat tidak ada lagi yang tersisa node di-RETIRE
+// dan ditandai DECOMMISSIONED.
+var drainCheckInterval = time.Duration(getEnvInt("DRAIN_CHECK_INTERVAL_SECONDS", 30)) * time.Second
+
+var errNodeNotDraining = errors.New("node tidak sedang drain")
+
+var drainWake = make(chan struct{}, 1)
+
+func wakeDrainMonitor() {
+	select {
+	case drainWake <- struct{}{}:
+	default:
+	}
+}
+
+// drainObject adalah satu salinan di node yang drain: file utuh, satu chunk
+// (ChunkIndex) atau satu shard erasure (ShardIndex)
+type drainObject struct {
+	FileKey    string
+	ChunkIndex *int
+	ShardIndex *int
+	Target     int
+}
+
+func (o drainObject) objectKey() string {
+	switch {
+	case o.ChunkIndex != nil:
+		return chunkObjectKey(o.FileKey, *o.ChunkIndex)
+	case o.ShardIndex != nil:
+		return shardObjectKey(o.FileKey, *o.ShardIndex)
+	}
+	return o.FileKey
+}
+
+type DrainStatus struct {
+	NodeID              string     `json:"node_id"`
+	DrainState          string     `json:"drain_state"`
+	DrainStartedAt      *time.Time `json:"drain_started_at,omitempty"`
+	TotalObjects        int        `json:"total_objects"`
+	RemainingObjects    int        `json:"remaining_objects"`
+	ProgressPercent     float64    `json:"progress_percent"`
+	PendingReplications int        `json:"pending_replications"`
+	DeadReplications    int        `json:"dead_replications"`
+}
+
+// startDrain menandai node DRAINING. Replikasi yang masih menuju node ini
+// dibatalkan karena datanya justru akan dipindah keluar.
+func startDrain(nodeID string) error {
+	node, err := getNode(nodeID)
+	if err != nil {
+		return err
+	}
+	if node.Status == "RETIRED" {
+		return errNodeNotFound
+	}
+	if node.DrainState == "DRAINING" {
+		return nil
+	}
+
+	total, err := countActiveLocationsOnNode(nodeID)
+	if err != nil {
+		return err
+	}
+
+	if err := metadata.StartDrain(nodeID, total); err != nil {
+		return err
+	}
+	if err := cancelReplicationsToNode(nodeID); err != nil {
+		return err
+	}
+
+	log.Printf("🚚 Drain started for %s (%d objects)\n", nodeID, total)
+	wakeDrainMonitor()
+	return nil
+}
+
+func cancelDrain(nodeID string) error {
+	if err := metadata.CancelDrain(nodeID); err != nil {
+		return err
+	}
+	log.Printf("🚚 Drain cancelled for %s\n", nodeID)
+	return nil
+}
+
+// cancelReplicationsToNode memindahkan item yang belum jalan ke DEAD supaya
+// tidak ada salinan baru yang mendarat di node yang sedang drain
+func cancelReplicationsToNode(nodeID string) error {
+	return metadata.CancelReplicationsToNode(nodeID, "target node draining")
+}
+
+func getDrainStatus(nodeID string) (*DrainStatus, error) {
+	status, err := metadata.GetDrainStatus(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.RemainingObjects, err = countActiveLocationsOnNode(nodeID); err != nil {
+		return nil, err
+	}
+	if status.TotalObjects < status.RemainingObjects {
+		status.TotalObjects = status.RemainingObjects
+	}
+	switch {
+	case status.DrainState == "DECOMMISSIONED":
+		status.ProgressPercent = 100
+	case status.TotalObjects > 0:
+		status.ProgressPercent = float64(status.TotalObjects-status.RemainingObjects) / float64(status.TotalObjects) * 100
+	}
+
+	return status, nil
+}
+
+// getDrainObjects mengembalikan semua salinan ACTIVE yang masih ada di node
+func getDrainObjects(nodeID string) ([]drainObject, error) {
+	copies, err := metadata.ListObjectCopies(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]drainObject, len(copies))
+	for i, c := range copies {
+		objects[i] = drainObject{FileKey: c.FileKey, ChunkIndex: c.ChunkIndex, ShardIndex: c.ShardIndex, Target: c.target()}
+	}
+	return objects, nil
+}
+
+// drainOneObject memastikan salinan di node yang drain punya pengganti.
+// Mengembalikan true jika salinan di node tersebut sudah dilepas.
+func drainOneObject(node Node, obj drainObject, nodes []Node) (bool, error) {
+	item := ReplicationQueueItem{FileKey: obj.FileKey, ChunkIndex: obj.ChunkIndex, ShardIndex: obj.ShardIndex}
+	holders, err := getReplicationHolders(item)
+	if err != nil {
+		return false, err
+	}
+	queued, err := getQueuedTargets(obj.FileKey, obj.ChunkIndex, obj.ShardIndex)
+	if err != nil {
+		return false, err
+	}
+
+	// Node yang masih bisa menampung data: tidak RETIRED dan tidak sedang drain
+	remaining := make(map[string]Node)
+	for _, n := range nodes {
+		if n.Status != "RETIRED" && n.DrainState == "NONE" {
+			remaining[n.ID] = n
+		}
+	}
+
+	// Satu shard cukup ada di satu node lain; file/chunk mengikuti replication
+	// factor, dibatasi jumlah node yang tersisa
+	needed := obj.Target
+	if obj.ShardIndex != nil {
+		needed = 1
+	}
+	if needed > len(remaining) {
+		needed = len(remaining)
+	}
+	if needed == 0 {
+		return false, fmt.Errorf("tidak ada node lain untuk menampung %s", obj.objectKey())
+	}
+
+	holding := map[string]bool{node.ID: true}
+	elsewhere := 0
+	for _, nodeID := range holders {
+		holding[nodeID] = true
+		if _, ok := remaining[nodeID]; ok {
+			elsewhere++
+		}
+	}
+
+	if elsewhere >= needed {
+		if err := markLocationStatus(obj.FileKey, obj.ChunkIndex, node.ID, "DELETED"); err != nil {
+			return false, err
+		}
+		if node.Status == "UP" {
+			if status, err := deleteFileFromNode(node.Address, obj.objectKey()); err != nil || (status != 200 && status != 404) {
+				log.Printf("⚠️ Drain: %s di %s belum terhapus (status %d, err %v)\n", obj.objectKey(), node.ID, status, err)
+			}
+		}
+		return true, nil
+	}
+
+	exclude := make(map[string]bool)
+	for nodeID := range holding {
+		exclude[nodeID] = true
+	}
+	var kept []string
+	for nodeID := range holding {
+		if _, ok := remaining[nodeID]; ok {
+			kept = append(kept, nodeID)
+		}
+	}
+	pending := 0
+	for nodeID, status := range queued {
+		exclude[nodeID] = true
+		if _, ok := remaining[nodeID]; ok && status != "DEAD" && !holding[nodeID] {
+			pending++
+			kept = append(kept, nodeID)
+		}
+	}
+	missing := needed - elsewhere - pending
+	if missing <= 0 {
+		return false, nil
+	}
+
+	// Node yang sudah memegang shard lain dari file yang sama tidak boleh
+	// menerima shard ini (satu shard per node)
+	if obj.ShardIndex != nil {
+		shards, err := getShardLocations(obj.FileKey)
+		if err != nil {
+			return false, err
+		}
+		for nodeID := range shards {
+			exclude[nodeID] = true
+			if _, ok := remaining[nodeID]; ok {
+				kept = append(kept, nodeID)
+			}
+		}
+	}
+
+	source := ""
+	if node.Status == "UP" {
+		source = node.ID
+	} else {
+		for _, nodeID := range holders {
+			if n, ok := remaining[nodeID]; ok && n.Status == "UP" {
+				source = nodeID
+				break
+			}
+		}
+	}
+	if source == "" {
+		return false, fmt.Errorf("tidak ada salinan UP untuk %s", obj.objectKey())
+	}
+
+	for _, candidate := range selectNodesForUpload(nodes, domainsOf(kept, remaining)) {
+		if missing == 0 {
+			break
+		}
+		if exclude[candidate.ID] {
+			continue
+		}
+
+		switch {
+		case obj.ShardIndex != nil:
+			err = addShardToReplicationQueue(obj.FileKey, *obj.ShardIndex, candidate.ID, source)
+		case obj.ChunkIndex != nil:
+			err = addChunkToReplicationQueue(obj.FileKey, *obj.ChunkIndex, candidate.ID, source)
+		default:
+			err = addToReplicationQueue(obj.FileKey, candidate.ID, source)
+		}
+		if err != nil {
+			return false, err
+		}
+		log.Printf("🚚 Drain: %s queued %s -> %s\n", obj.objectKey(), source, candidate.ID)
+		exclude[candidate.ID] = true
+		missing--
+	}
+
+	return false, nil
+}
+
+// drainNode menjalankan satu putaran drain untuk satu node dan menyelesaikan
+// decommission jika tidak ada lagi salinan ACTIVE di node tersebut
+func drainNode(node Node, nodes []Node) error {
+	if err := cancelReplicationsToNode(node.ID); err != nil {
+		return err
+	}
+
+	objects, err := getDrainObjects(node.ID)
+	if err != nil {
+		return err
+	}
+
+	released := 0
+	for _, obj := range objects {
+		done, err := drainOneObject(node, obj, nodes)
+		if err != nil {
+			log.Printf("⚠️ Drain %s on %s: %v\n", obj.objectKey(), node.ID, err)
+			continue
+		}
+		if done {
+			released++
+		}
+	}
+	if released > 0 {
+		log.Printf("🚚 Drain %s: released %d of %d objects\n", node.ID, released, len(objects))
+	}
+	if released < len(objects) {
+		return nil
+	}
+
+	if err := retireNode(node.ID); err != nil {
+		return err
+	}
+	if err := metadata.FinishDrain(node.ID); err != nil {
+		return err
+	}
+	log.Printf("🏁 Node %s decommissioned\n", node.ID)
+	return nil
+}
+
+func runDrainPass() {
+	nodes, err := getAllNodes()
+	if err != nil {
+		log.Println("error ambil nodes untuk drain:", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if node.DrainState != "DRAINING" || node.Status == "RETIRED" {
+			continue
+		}
+		if err := drainNode(node, nodes); err != nil {
+			log.Printf("⚠️ Drain %s failed: %v\n", node.ID, err)
+		}
+	}
+}
+
+func runDrainMonitor() {
+	for {
+		if isLeader() {
+			runDrainPass()
+		}
+
+		select {
+		case <-drainWake:
+		case <-time.After(drainCheckInterval):
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Parameter default storage class erasure coding: k data shard + m parity shard.
+// File tetap bisa dibaca selama minimal k shard tersedia.
+var (
+	defaultECDataShards   = getEnvInt("EC_DATA_SHARDS", 2)
+	defaultECParityShards = getEnvInt("EC_PARITY_SHARDS", 1)
+	ecMaxBlockSize        = int64(getEnvInt("EC_BLOCK_SIZE_KB", 1024)) << 10
+)
+
+var errErasurePlacementFailed = errors.New("erasure coded shards could not be placed")
+
+type ErasureParams struct {
+	DataShards   int
+	ParityShards int
+	BlockSize    int64
+}
+
+func (p ErasureParams) TotalShards() int {
+	return p.DataShards + p.ParityShards
+}
+
+// parseErasureParams membaca override k/m dari request, string kosong = default
+func parseErasureParams(dataRaw, parityRaw string) (ErasureParams, error) {
+	params := ErasureParams{DataShards: defaultECDataShards, ParityShards: defaultECParityShards}
+
+	if dataRaw != "" {
+		n, err := strconv.Atoi(dataRaw)
+		if err != nil || n < 1 {
+			return params, fmt.Errorf("ec_data_shards harus angka >= 1")
+		}
+		params.DataShards = n
+	}
+	if parityRaw != "" {
+		n, err := strconv.Atoi(parityRaw)
+		if err != nil || n < 1 {
+			return params, fmt.Errorf("ec_parity_shards harus angka >= 1")
+		}
+		params.ParityShards = n
+	}
+	if params.TotalShards() > 256 {
+		return params, fmt.Errorf("total shard maksimal 256")
+	}
+
+	return params, nil
+}
+
+// ecBlockSizeFor memilih ukuran block per shard. File kecil memakai block kecil
+// supaya padding di stripe terakhir tidak membesar-besarkan ukuran shard.
+func ecBlockSizeFor(sizeBytes int64, dataShards int) int64 {
+	perShard := (sizeBytes + int64(dataShards) - 1) / int64(dataShards)
+	perShard = (perShard + 63) / 64 * 64
+	if perShard < 64 {
+		perShard = 64
+	}
+	if perShard > ecMaxBlockSize {
+		return ecMaxBlockSize
+	}
+	return perShard
+}
+
+// shardObjectKey adalah file_id yang dipakai storage node untuk menyimpan satu shard
+func shardObjectKey(fileKey string, index int) string {
+	return fmt.Sprintf("%s_shard_%02d", fileKey, index)
+}
+
+// stickyWriter berhenti menulis setelah error pertama dan menyimpan error tersebut,
+// supaya satu shard yang gagal tidak menghentikan encoding shard lain.
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return len(p), nil
+	}
+	if _, err := s.w.Write(p); err != nil {
+		s.err = err
+	}
+	return len(p), nil
+}
+
+// encodeStripes membaca file per stripe (k block), menghitung parity, lalu menulis
+// block ke-i setiap stripe ke writers[i]. Writer nil dilewati.
+func encodeStripes(src io.ReaderAt, size int64, params ErasureParams, enc reedsolomon.Encoder, writers []io.Writer) error {
+	k, m, blockSize := params.DataShards, params.ParityShards, params.BlockSize
+	stripeSize := int64(k) * blockSize
+
+	stripe := make([]byte, stripeSize)
+	parity := make([]byte, int64(m)*blockSize)
+	shards := make([][]byte, k+m)
+
+	for offset := int64(0); offset < size; offset += stripeSize {
+		n := stripeSize
+		if offset+n > size {
+			n = size - offset
+		}
+		if read, err := src.ReadAt(stripe[:n], offset); int64(read) != n {
+			return fmt.Errorf("gagal baca stripe di offset %d: %v", offset, err)
+		}
+		clear(stripe[n:])
+
+		for i := 0; i < k; i++ {
+			shards[i] = stripe[int64(i)*blockSize : int64(i+1)*blockSize]
+		}
+		for i := 0; i < m; i++ {
+			shards[k+i] = parity[int64(i)*blockSize : int64(i+1)*blockSize]
+		}
+		if err := enc.Encode(shards); err != nil {
+			return fmt.Errorf("gagal encode parity: %v", err)
+		}
+
+		for i, w := range writers {
+			if w != nil {
+				w.Write(shards[i])
+			}
+		}
+	}
+
+	return nil
+}
+
+type shardUpload struct {
+	writer *stickyWriter
+	pipe   *io.PipeWriter
+	done   chan error
+}
+
+func startShardUpload(node Node, objectKey string) *shardUpload {
+	pr, pw := io.Pipe()
+	u := &shardUpload{writer: &stickyWriter{w: pw}, pipe: pw, done: make(chan error, 1)}
+
+	go func() {
+		err := uploadObjectToNode(node.Address, objectKey, objectKey, pr, "")
+		// Buka blokir writer jika upload berhenti sebelum semua data terbaca
+		pr.CloseWithError(fmt.Errorf("upload ke %s selesai: %v", node.ID, err))
+		u.done <- err
+	}()
+
+	return u
+}
+
+// spoolShards meng-encode file sekali ke satu file sementara per shard, supaya
+// shard yang gagal diupload bisa dikirim ulang tanpa encode ulang. Caller wajib
+// memanggil removeShardSpool.
+func spoolShards(src io.ReaderAt, size int64, params ErasureParams, enc reedsolomon.Encoder) ([]*os.File, error) {
+	files := make([]*os.File, params.TotalShards())
+	buffered := make([]*bufio.Writer, len(files))
+	writers := make([]io.Writer, len(files))
+	for i := range files {
+		f, err := os.CreateTemp("", "dfs-shard-*")
+		if err != nil {
+			removeShardSpool(files)
+			return nil, err
+		}
+		files[i] = f
+		buffered[i] = bufio.NewWriter(f)
+		writers[i] = buffered[i]
+	}
+
+	if err := encodeStripes(src, size, params, enc, writers); err != nil {
+		removeShardSpool(files)
+		return nil, err
+	}
+	// Error tulis disimpan bufio.Writer dan muncul lagi saat Flush
+	for i, w := range buffered {
+		if err := w.Flush(); err != nil {
+			removeShardSpool(files)
+			return nil, fmt.Errorf("gagal tulis shard %d: %v", i, err)
+		}
+	}
+	return files, nil
+}
+
+func removeShardSpool(files []*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+}
+
+// storeErasureCodedFile meng-encode file menjadi k+m shard dan menaruh setiap
+// shard di node yang berbeda. Shard yang gagal diupload dikirim ulang (tanpa
+// encode ulang) ke kandidat berikutnya yang tidak berbagi failure domain dengan
+// shard lain. Mengembalikan node per index shard.
+func storeErasureCodedFile(fileKey string, file *multipart.FileHeader, candidates []Node, params ErasureParams) ([]string, error) {
+	total := params.TotalShards()
+	if len(candidates) < total {
+		return nil, fmt.Errorf("%w: butuh %d node UP, tersedia %d", errErasurePlacementFailed, total, len(candidates))
+	}
+
+	enc, err := reedsolomon.New(params.DataShards, params.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("gagal baca file: %v", err)
+	}
+	defer src.Close()
+
+	spool, err := spoolShards(src, file.Size, params, enc)
+	if err != nil {
+		return nil, err
+	}
+	defer removeShardSpool(spool)
+	shardSize := erasureShardSize(file.Size, params)
+
+	assigned := make([]Node, total)
+	copy(assigned, candidates[:total])
+	tried := make(map[string]bool)
+	for _, n := range assigned {
+		tried[n.ID] = true
+	}
+	placed := make([]bool, total)
+
+	rollback := func() {
+		for i, ok := range placed {
+			if ok {
+				if _, err := deleteFileFromNode(assigned[i].Address, shardObjectKey(fileKey, i)); err != nil {
+					log.Printf("⚠️ Rollback delete shard %d of %s from %s failed: %v\n", i, fileKey, assigned[i].ID, err)
+				}
+			}
+		}
+	}
+
+	// replacement memilih kandidat yang belum dicoba, diutamakan (atau jika
+	// strict, hanya) dari domain yang belum dipakai shard lain
+	replacement := func(index int) (Node, bool) {
+		used := make(map[string]bool)
+		for i, n := range assigned {
+			if i != index {
+				used[failureDomain(n)] = true
+			}
+		}
+		var pool []Node
+		for _, n := range candidates {
+			if !tried[n.ID] {
+				pool = append(pool, n)
+			}
+		}
+		if pool = spreadAcrossDomains(pool, used); len(pool) == 0 {
+			return Node{}, false
+		}
+		tried[pool[0].ID] = true
+		return pool[0], true
+	}
+
+	for {
+		errs := make([]chan error, total)
+		pending := 0
+		for i := 0; i < total; i++ {
+			if placed[i] {
+				continue
+			}
+			done := make(chan error, 1)
+			go func(node Node, shard io.Reader, objectKey string) {
+				done <- uploadObjectToNode(node.Address, objectKey, objectKey, shard, "")
+			}(assigned[i], io.NewSectionReader(spool[i], 0, shardSize), shardObjectKey(fileKey, i))
+			errs[i] = done
+			pending++
+		}
+		if pending == 0 {
+			break
+		}
+
+		exhausted := false
+		for i, done := range errs {
+			if done == nil {
+				continue
+			}
+			uploadErr := <-done
+			if uploadErr == nil {
+				placed[i] = true
+				continue
+			}
+
+			log.Printf("❌ Upload shard %d of %s to %s failed: %v\n", i, fileKey, assigned[i].ID, uploadErr)
+			if node, ok := replacement(i); ok {
+				assigned[i] = node
+			} else {
+				exhausted = true
+			}
+		}
+
+		if exhausted {
+			rollback()
+			return nil, fmt.Errorf("%w: kandidat node habis", errErasurePlacementFailed)
+		}
+	}
+
+	nodeIDs := make([]string, total)
+	for i, node := range assigned {
+		nodeIDs[i] = node.ID
+	}
+
+	return nodeIDs, nil
+}
+
+// deleteErasureShards menghapus shard upload EC yang metadatanya gagal
+// disimpan. nodeIDs[i] adalah node shard ke-i.
+func deleteErasureShards(fileKey string, nodeIDs []string, nodes []Node) {
+	addrMap := make(map[string]string)
+	for _, n := range nodes {
+		addrMap[n.ID] = n.Address
+	}
+	for i, nodeID := range nodeIDs {
+		if _, err := deleteFileFromNode(addrMap[nodeID], shardObjectKey(fileKey, i)); err != nil {
+			log.Printf("⚠️ Rollback delete shard %d of %s from %s failed: %v\n", i, fileKey, nodeID, err)
+		}
+	}
+}
+
+// rebuildShards membaca k shard yang tersisa block demi block, merekonstruksi
+// shard yang hilang lalu mengupload shard ke-i ke targets[i]. Mengembalikan
+// index shard yang berhasil ditempatkan; shard lain bisa dicoba lagi nanti.
+func rebuildShards(fileKey string, params ErasureParams, shardSize int64, survivors []shardSource, targets map[int]Node) ([]int, error) {
+	enc, err := reedsolomon.New(params.DataShards, params.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	total := params.TotalShards()
+	readers := make([]io.ReadCloser, total)
+	defer func() {
+		for _, rc := range readers {
+			if rc != nil {
+				rc.Close()
+			}
+		}
+	}()
+
+	opened := 0
+	for _, source := range survivors {
+		if opened == params.DataShards {
+			break
+		}
+		if readers[source.Index] != nil {
+			continue
+		}
+		body, err := openNodeObject(source.Node, shardObjectKey(fileKey, source.Index), 0)
+		if err != nil {
+			log.Printf("⚠️ Cannot read shard %d of %s from %s: %v\n", source.Index, fileKey, source.Node.ID, err)
+			continue
+		}
+		readers[source.Index] = body
+		opened++
+	}
+	if opened < params.DataShards {
+		return nil, fmt.Errorf("shard yang bisa dibaca kurang dari %d", params.DataShards)
+	}
+
+	uploads := make(map[int]*shardUpload)
+	for index, node := range targets {
+		uploads[index] = startShardUpload(node, shardObjectKey(fileKey, index))
+	}
+
+	buffers := make([][]byte, total)
+	for i := range buffers {
+		buffers[i] = make([]byte, params.BlockSize)
+	}
+	shards := make([][]byte, total)
+
+	var readErr error
+	for offset := int64(0); offset < shardSize && readErr == nil; offset += params.BlockSize {
+		// Shard yang tidak dibaca dikosongkan supaya direkonstruksi ke buffer-nya
+		for i, rc := range readers {
+			shards[i] = buffers[i][:0]
+			if rc == nil {
+				continue
+			}
+			if _, err := io.ReadFull(rc, buffers[i]); err != nil {
+				readErr = fmt.Errorf("gagal baca shard %d di offset %d: %v", i, offset, err)
+				break
+			}
+			shards[i] = buffers[i]
+		}
+		if readErr != nil {
+			break
+		}
+		if err := enc.Reconstruct(shards); err != nil {
+			readErr = fmt.Errorf("gagal rekonstruksi block di offset %d: %v", offset, err)
+			break
+		}
+		for index, u := range uploads {
+			u.writer.Write(shards[index])
+		}
+	}
+
+	var rebuilt []int
+	for index, u := range uploads {
+		if readErr != nil {
+			u.pipe.CloseWithError(readErr)
+		} else {
+			u.pipe.Close()
+		}
+		uploadErr := <-u.done
+		if readErr == nil && uploadErr == nil && u.writer.err == nil {
+			rebuilt = append(rebuilt, index)
+			continue
+		}
+		if readErr == nil {
+			log.Printf("❌ Upload rebuilt shard %d of %s to %s failed: %v %v\n", index, fileKey, targets[index].ID, uploadErr, u.writer.err)
+		}
+		// Sisa upload yang gagal di node tujuan dibersihkan
+		if _, err := deleteFileFromNode(targets[index].Address, shardObjectKey(fileKey, index)); err != nil {
+			log.Printf("⚠️ Cleanup rebuilt shard %d of %s on %s failed: %v\n", index, fileKey, targets[index].ID, err)
+		}
+	}
+	sort.Ints(rebuilt)
+	return rebuilt, readErr
+}
+
+// rebuildQueuedShard membangun ulang satu shard item replikasi di target dari
+// shard lain yang ACTIVE di node UP, lalu mencatatnya ACTIVE
+func rebuildQueuedShard(fileKey string, index int, target Node, nodeByID map[string]Node) error {
+	record, err := getFileRecord(fileKey)
+	if err != nil {
+		return err
+	}
+	if record == nil || record.StorageMode != "ERASURE" {
+		return fmt.Errorf("file %s bukan file erasure", fileKey)
+	}
+	params := ErasureParams{DataShards: record.ECDataShards, ParityShards: record.ECParityShards, BlockSize: record.ECBlockSize}
+
+	shardByNode, err := getShardLocations(fileKey)
+	if err != nil {
+		return err
+	}
+	var survivors []shardSource
+	for nodeID, i := range shardByNode {
+		if n, ok := nodeByID[nodeID]; ok && n.Status == "UP" && i != index {
+			survivors = append(survivors, shardSource{Index: i, Node: n})
+		}
+	}
+	// Data shard didahulukan supaya rekonstruksi membaca sesedikit mungkin parity
+	sort.Slice(survivors, func(i, j int) bool { return survivors[i].Index < survivors[j].Index })
+
+	rebuilt, err := rebuildShards(fileKey, params, erasureShardSize(record.SizeBytes, params), survivors, map[int]Node{index: target})
+	if err != nil {
+		return err
+	}
+	if len(rebuilt) == 0 {
+		return fmt.Errorf("gagal upload shard %d ke %s", index, target.ID)
+	}
+	log.Printf("🧩 Rebuilt shard %d of %s on %s\n", index, fileKey, target.ID)
+	return markShardLocationActive(fileKey, index, target.ID)
+}
+
+func markShardLocationActive(fileKey string, shardIndex int, nodeID string) error {
+	return metadata.SetShardLocationActive(fileKey, shardIndex, nodeID)
+}
+
+// getShardLocations mengembalikan index shard per node untuk file ERASURE
+func getShardLocations(fileKey string) (map[string]int, error) {
+	return metadata.GetShardLocations(fileKey)
+}
+
+// erasureShardSize adalah ukuran setiap shard di storage node (termasuk padding stripe terakhir)
+func erasureShardSize(sizeBytes int64, params ErasureParams) int64 {
+	stripeSize := int64(params.DataShards) * params.BlockSize
+	stripes := (sizeBytes + stripeSize - 1) / stripeSize
+	return stripes * params.BlockSize
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+func TestEcBlockSizeFor(t *testing.T) {
+	max := ecMaxBlockSize
+	ecMaxBlockSize = 1024
+	t.Cleanup(func() { ecMaxBlockSize = max })
+
+	cases := []struct {
+		size       int64
+		dataShards int
+		want       int64
+	}{
+		{0, 2, 64},
+		{1, 2, 64},
+		{100, 2, 64},
+		{1000, 2, 512},
+		{1000, 3, 384},
+		{2048, 2, 1024},
+		{10000, 2, 1024},
+	}
+	for _, c := range cases {
+		if got := ecBlockSizeFor(c.size, c.dataShards); got != c.want {
+			t.Errorf("ecBlockSizeFor(%d, %d) = %d, want %d", c.size, c.dataShards, got, c.want)
+		}
+	}
+}
+
+// encodeToBuffers meng-encode data ke shard di memori
+func encodeToBuffers(t *testing.T, data []byte, params ErasureParams) [][]byte {
+	t.Helper()
+	enc, err := reedsolomon.New(params.DataShards, params.ParityShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffers := make([]*bytes.Buffer, params.TotalShards())
+	writers := make([]io.Writer, params.TotalShards())
+	for i := range buffers {
+		buffers[i] = &bytes.Buffer{}
+		writers[i] = buffers[i]
+	}
+	if err := encodeStripes(bytes.NewReader(data), int64(len(data)), params, enc, writers); err != nil {
+		t.Fatal(err)
+	}
+
+	shards := make([][]byte, len(buffers))
+	for i, b := range buffers {
+		shards[i] = b.Bytes()
+	}
+	return shards
+}
+
+// decodeFromShards menyusun ulang file per stripe dari shard yang tidak nil
+func decodeFromShards(t *testing.T, shards [][]byte, size int64, params ErasureParams) []byte {
+	t.Helper()
+	enc, err := reedsolomon.New(params.DataShards, params.ParityShards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []byte
+	stripe := make([][]byte, len(shards))
+	for offset := int64(0); offset < erasureShardSize(size, params); offset += params.BlockSize {
+		for i, shard := range shards {
+			stripe[i] = nil
+			if shard != nil {
+				stripe[i] = shard[offset : offset+params.BlockSize]
+			}
+		}
+		if err := enc.ReconstructData(stripe); err != nil {
+			t.Fatalf("ReconstructData di offset %d: %v", offset, err)
+		}
+		for i := 0; i < params.DataShards; i++ {
+			out = append(out, stripe[i]...)
+		}
+	}
+	return out[:size]
+}
+
+func TestErasureRoundTrip(t *testing.T) {
+	roundTrip := func(t *testing.T, size int64, params ErasureParams, lost ...int) {
+		original := make([]byte, size)
+		rand.New(rand.NewSource(size)).Read(original)
+
+		shards := encodeToBuffers(t, original, params)
+		for i, shard := range shards {
+			if int64(len(shard)) != erasureShardSize(size, params) {
+				t.Fatalf("shard %d size = %d, want %d", i, len(shard), erasureShardSize(size, params))
+			}
+		}
+
+		for _, i := range lost {
+			shards[i] = nil
+		}
+		if decoded := decodeFromShards(t, shards, size, params); !bytes.Equal(decoded, original) {
+			t.Errorf("file hasil decode tidak sama dengan file asli")
+		}
+	}
+
+	t.Run("single byte", func(t *testing.T) {
+		roundTrip(t, 1, ErasureParams{DataShards: 2, ParityShards: 1, BlockSize: 64}, 0)
+	})
+	t.Run("data shard lost", func(t *testing.T) {
+		roundTrip(t, 1000, ErasureParams{DataShards: 2, ParityShards: 1, BlockSize: 64}, 1)
+	})
+	t.Run("data and parity lost", func(t *testing.T) {
+		roundTrip(t, 1000, ErasureParams{DataShards: 3, ParityShards: 2, BlockSize: 128}, 0, 2)
+	})
+	t.Run("all parity lost", func(t *testing.T) {
+		roundTrip(t, 4096, ErasureParams{DataShards: 4, ParityShards: 2, BlockSize: 256}, 4, 5)
+	})
+	t.Run("no loss with padding", func(t *testing.T) {
+		roundTrip(t, 777, ErasureParams{DataShards: 2, ParityShards: 2, BlockSize: 512})
+	})
+}
+
+func multipartFileHeader(t *testing.T, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "f.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/files", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestStoreErasureCodedFileReplacementDomain(t *testing.T) {
+	level, strict := failureDomainLevel, failureDomainStrict
+	failureDomainLevel, failureDomainStrict = "zone", false
+	t.Cleanup(func() { failureDomainLevel, failureDomainStrict = level, strict })
+
+	var deletes atomic.Int32
+	node := func(id, zone string, fail bool) Node {
+		return Node{ID: id, Zone: zone, Status: "UP", Address: fakeStorageNode(t, fail, &deletes).URL}
+	}
+	// Shard c gagal; d ada di zone yang sudah dipakai shard a, jadi e yang dipilih
+	candidates := []Node{node("a", "z1", false), node("b", "z2", false), node("c", "z3", true), node("d", "z1", false), node("e", "z4", false)}
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	params := ErasureParams{DataShards: 2, ParityShards: 1, BlockSize: 64}
+	nodeIDs, err := storeErasureCodedFile("ec", multipartFileHeader(t, data), candidates, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(nodeIDs, ","); got != "a,b,e" {
+		t.Errorf("placement = %s, want a,b,e", got)
+	}
+}
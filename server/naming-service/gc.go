@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Orphan GC mencocokkan inventory object setiap node UP (GET /objects di
+// storage node) dengan file_locations / chunk_locations / part multipart yang
+// masih berjalan. Object di node yang tidak dikenal metadata adalah orphan
+// (upload gagal sebelum register, delete setengah jalan, salinan sisa drain /
+// rebalance); object ACTIVE di metadata yang tidak ada di node dilaporkan
+// missing (perbaikannya tetap lewat scrubber). Orphan hanya dihapus jika lebih
+// tua dari ORPHAN_GC_MIN_AGE_HOURS supaya upload yang belum sempat register
+// tidak ikut terhapus. GC terjadwal hanya dry run kecuali ORPHAN_GC_DELETE=true.
+var (
+	orphanGCMinAge   = time.Duration(getEnvInt("ORPHAN_GC_MIN_AGE_HOURS", 24)) * time.Hour
+	orphanGCInterval = time.Duration(getEnvInt("ORPHAN_GC_INTERVAL_HOURS", 24)) * time.Hour
+	orphanGCDelete   = getEnv("ORPHAN_GC_DELETE", "false") == "true"
+)
+
+const (
+	// Batas jumlah entry orphan / missing yang disimpan di report
+	orphanGCReportLimit = 1000
+	// Tombstone yang dibaca per node untuk melewati blob yang sudah dijadwalkan
+	orphanGCTombstoneLimit = 10000
+)
+
+var errOrphanGCRunning = errors.New("orphan GC sedang berjalan")
+
+// Inventory besar bisa lambat di-list, timeout-nya longgar seperti scrubber
+var inventoryClient = &http.Client{Timeout: 2 * time.Minute}
+
+// NodeObject adalah satu entry inventory storage node
+type NodeObject struct {
+	FileID     string    `json:"file_id"`
+	StoredName string    `json:"stored_name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	// false = hanya .meta.json yang tersisa
+	HasData bool `json:"has_data"`
+}
+
+type OrphanObject struct {
+	NodeID     string    `json:"node_id"`
+	ObjectKey  string    `json:"object_key"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	// would_delete (dry run), deleted, failed, too_new, tombstoned
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+type MissingObject struct {
+	NodeID    string `json:"node_id"`
+	ObjectKey string `json:"object_key"`
+}
+
+type OrphanGCReport struct {
+	DryRun         bool       `json:"dry_run"`
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	MinAgeHours    int        `json:"min_age_hours"`
+	NodesScanned   int        `json:"nodes_scanned"`
+	NodesSkipped   []string   `json:"nodes_skipped"`
+	ObjectsScanned int        `json:"objects_scanned"`
+	OrphanCount    int        `json:"orphan_count"`
+	MissingCount   int        `json:"missing_count"`
+	OrphansDeleted int        `json:"orphans_deleted"`
+	OrphansFailed  int        `json:"orphans_failed"`
+	BytesReclaimed int64      `json:"bytes_reclaimed"`
+	// Dipotong di orphanGCReportLimit entry
+	Orphans []OrphanObject  `json:"orphans"`
+	Missing []MissingObject `json:"missing"`
+}
+
+type orphanGCState struct {
+	mu      sync.Mutex
+	running bool
+	last    *OrphanGCReport
+}
+
+var orphanGC orphanGCState
+
+func (s *orphanGCState) snapshot() (running bool, last *OrphanGCReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running, s.last
+}
+
+// fetchNodeInventory mengambil daftar object yang tersimpan di node
+func fetchNodeInventory(node Node) ([]NodeObject, error) {
+	resp, err := inventoryClient.Get(node.Address + "/objects")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("node return status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Objects []NodeObject `json:"objects"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("gagal parse response: %v", err)
+	}
+	return result.Objects, nil
+}
+
+// scanNodeOrphans mencocokkan inventory satu node dengan metadata dan, jika
+// bukan dry run, menghapus orphan yang lebih tua dari safety window
+func scanNodeOrphans(node Node, report *OrphanGCReport) error {
+	// Inventory diambil dulu: object yang selesai register setelah ini sudah
+	// terlihat di metadata dan tidak dianggap orphan
+	inventory, err := fetchNodeInventory(node)
+	if err != nil {
+		return err
+	}
+	expected, err := metadata.ListNodeLocations(node.ID)
+	if err != nil {
+		return err
+	}
+	tombstones, err := metadata.ListTombstones(node.ID, orphanGCTombstoneLimit)
+	if err != nil {
+		return err
+	}
+	tombstoned := make(map[string]bool)
+	for _, t := range tombstones {
+		tombstoned[t.BlobKey] = true
+	}
+
+	report.NodesScanned++
+	report.ObjectsScanned += len(inventory)
+
+	present := make(map[string]bool)
+	for _, obj := range inventory {
+		if obj.HasData {
+			present[obj.FileID] = true
+		}
+
+		if status, ok := expected[obj.FileID]; ok && status != "DELETED" {
+			continue
+		}
+
+		orphan := OrphanObject{
+			NodeID:     node.ID,
+			ObjectKey:  obj.FileID,
+			SizeBytes:  obj.SizeBytes,
+			ModifiedAt: obj.ModifiedAt,
+		}
+		switch {
+		case tombstoned[obj.FileID]:
+			// Sudah dijadwalkan dihapus oleh tombstone replayer
+			orphan.Action = "tombstoned"
+		case time.Since(obj.ModifiedAt) < orphanGCMinAge:
+			orphan.Action = "too_new"
+		case report.DryRun:
+			orphan.Action = "would_delete"
+		default:
+			status, err := deleteFileFromNode(node.Address, obj.FileID)
+			if err == nil && status != 200 && status != 404 {
+				err = fmt.Errorf("node return status %d", status)
+			}
+			if err != nil {
+				orphan.Action = "failed"
+				orphan.Error = err.Error()
+				report.OrphansFailed++
+				log.Printf("❌ Failed to delete orphan %s from %s: %v\n", obj.FileID, node.ID, err)
+			} else {
+				orphan.Action = "deleted"
+				report.OrphansDeleted++
+				report.BytesReclaimed += obj.SizeBytes
+				log.Printf("🧹 Deleted orphan %s from %s (%d bytes)\n", obj.FileID, node.ID, obj.SizeBytes)
+			}
+		}
+
+		report.OrphanCount++
+		if len(report.Orphans) < orphanGCReportLimit {
+			report.Orphans = append(report.Orphans, orphan)
+		}
+	}
+
+	var missing []string
+	for blobKey, status := range expected {
+		if status == "ACTIVE" && !present[blobKey] {
+			missing = append(missing, blobKey)
+		}
+	}
+	sort.Strings(missing)
+	for _, blobKey := range missing {
+		report.MissingCount++
+		if len(report.Missing) < orphanGCReportLimit {
+			report.Missing = append(report.Missing, MissingObject{NodeID: node.ID, ObjectKey: blobKey})
+		}
+	}
+	return nil
+}
+
+// runOrphanGC menjalankan satu putaran GC di semua node UP. Dry run hanya
+// melaporkan orphan dan missing tanpa menghapus apa pun.
+func runOrphanGC(dryRun bool) (*OrphanGCReport, error) {
+	orphanGC.mu.Lock()
+	if orphanGC.running {
+		orphanGC.mu.Unlock()
+		return nil, errOrphanGCRunning
+	}
+	orphanGC.running = true
+	orphanGC.mu.Unlock()
+
+	defer func() {
+		orphanGC.mu.Lock()
+		orphanGC.running = false
+		orphanGC.mu.Unlock()
+	}()
+
+	nodes, err := getAllNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OrphanGCReport{
+		DryRun:       dryRun,
+		StartedAt:    time.Now(),
+		MinAgeHours:  int(orphanGCMinAge.Hours()),
+		NodesSkipped: []string{},
+		Orphans:      []OrphanObject{},
+		Missing:      []MissingObject{},
+	}
+	for _, node := range nodes {
+		if node.Status == "RETIRED" {
+			continue
+		}
+		if node.Status != "UP" {
+			report.NodesSkipped = append(report.NodesSkipped, node.ID)
+			continue
+		}
+		if err := scanNodeOrphans(node, report); err != nil {
+			log.Printf("⚠️ Orphan GC on %s skipped: %v\n", node.ID, err)
+			report.NodesSkipped = append(report.NodesSkipped, node.ID)
+		}
+	}
+
+	now := time.Now()
+	report.CompletedAt = &now
+	log.Printf("🔎 Orphan GC (dry_run=%v): %d nodes, %d objects, %d orphans (%d deleted, %d failed), %d missing\n",
+		dryRun, report.NodesScanned, report.ObjectsScanned, report.OrphanCount,
+		report.OrphansDeleted, report.OrphansFailed, report.MissingCount)
+
+	orphanGC.mu.Lock()
+	orphanGC.last = report
+	orphanGC.mu.Unlock()
+	return report, nil
+}
+
+func runOrphanCollector() {
+	if orphanGCInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(orphanGCInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		if _, err := runOrphanGC(!orphanGCDelete); err != nil {
+			log.Println("error orphan GC:", err)
+		}
+	}
+}
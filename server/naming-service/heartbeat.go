@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Lama lease heartbeat. Storage node yang push heartbeat harus mengirim ulang
+// sebelum lease habis, kalau tidak node dianggap DOWN oleh failure detector.
+var heartbeatLease = time.Duration(getEnvInt("HEARTBEAT_LEASE_SECONDS", 15)) * time.Second
+
+func getEnvInt(key string, fallback int) int {
+	if value := getEnv(key, ""); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("⚠️ %s=%q bukan angka, pakai default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+type HeartbeatReport struct {
+	CapacityBytes    *int64 `json:"capacity_bytes"`
+	FreeBytes        *int64 `json:"free_bytes"`
+	InflightRequests *int64 `json:"inflight_requests"`
+}
+
+// hasActiveLease true jika node mengirim heartbeat sendiri dan lease-nya belum habis.
+// Node seperti ini dilewati oleh poller /health.
+func hasActiveLease(node Node, now time.Time) bool {
+	return node.LeaseExpiresAt != nil && node.LeaseExpiresAt.After(now)
+}
+
+// recordHeartbeat menyimpan laporan heartbeat dan memperpanjang lease node.
+// Mengembalikan status node sebelum heartbeat diterima.
+func recordHeartbeat(nodeID string, report HeartbeatReport) (string, error) {
+	node, err := getNode(nodeID)
+	if err != nil {
+		return "", err
+	}
+	if node.Status == "RETIRED" {
+		return node.Status, errNodeNotFound
+	}
+
+	if err := metadata.RecordHeartbeat(nodeID, report, heartbeatLease); err != nil {
+		return node.Status, err
+	}
+
+	return node.Status, nil
+}
+
+// expireLeases menandai DOWN node yang lease-nya habis. Lease di-reset ke NULL
+// supaya node tersebut kembali dicek oleh poller /health sebagai fallback.
+func expireLeases() ([]string, error) {
+	return metadata.ExpireLeases()
+}
+
+// runLeaseFailureDetector mengecek lease yang habis beberapa kali per periode lease
+func runLeaseFailureDetector() {
+	interval := heartbeatLease / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		expired, err := expireLeases()
+		if err != nil {
+			log.Println("error cek heartbeat lease:", err)
+			continue
+		}
+		for _, id := range expired {
+			log.Printf("💔 Node %s heartbeat lease expired: UP -> DOWN\n", id)
+		}
+	}
+}
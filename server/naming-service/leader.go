@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Beberapa instance naming service boleh melayani API bersamaan, tapi job
+// background (health ticker, failure detector, replication worker, scrubber,
+// planner, dll) hanya dijalankan oleh leader. Leader dipilih lewat lease row
+// di tabel leader_lease: pemegang lease memperbaruinya secara berkala, dan jika
+// leader mati lease habis lalu instance lain mengambil alih. Semua perbandingan
+// waktu memakai NOW() MySQL supaya tidak bergantung pada jam tiap instance.
+var (
+	leaderLease = time.Duration(getEnvInt("LEADER_LEASE_SECONDS", 15)) * time.Second
+	instanceID  = getEnv("INSTANCE_ID", defaultInstanceID())
+)
+
+const leaderLeaseName = "naming-service"
+
+func defaultInstanceID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+type LeaderStatus struct {
+	InstanceID     string     `json:"instance_id"`
+	IsLeader       bool       `json:"is_leader"`
+	Leader         string     `json:"leader"`
+	LeaderSince    *time.Time `json:"leader_since,omitempty"`
+	LastRenewError string     `json:"last_renew_error,omitempty"`
+}
+
+type leaderState struct {
+	mu         sync.RWMutex
+	standalone bool
+	isLeader   bool
+	leader     string
+	since      time.Time
+	renewedAt  time.Time
+	lastError  string
+}
+
+var leadership leaderState
+
+func isLeader() bool {
+	leadership.mu.RLock()
+	defer leadership.mu.RUnlock()
+	if leadership.standalone {
+		return true
+	}
+	// Fencing lokal: lease yang tidak berhasil diperbarui dianggap hilang lebih
+	// awal dari lease di database supaya tidak tumpang tindih dengan leader baru
+	return leadership.isLeader && time.Since(leadership.renewedAt) < leaderLease*2/3
+}
+
+// waitUntilLeader memblokir job background selama instance ini bukan leader
+func waitUntilLeader() {
+	for !isLeader() {
+		time.Sleep(time.Second)
+	}
+}
+
+func getLeaderStatus() LeaderStatus {
+	leadership.mu.RLock()
+	defer leadership.mu.RUnlock()
+	status := LeaderStatus{
+		InstanceID:     instanceID,
+		IsLeader:       leadership.isLeader,
+		Leader:         leadership.leader,
+		LastRenewError: leadership.lastError,
+	}
+	if leadership.isLeader {
+		since := leadership.since
+		status.LeaderSince = &since
+	}
+	return status
+}
+
+// becomeStandaloneLeader dipakai jika tidak ada instance lain yang bisa
+// berbagi metadata (backend embedded): tidak perlu election
+func becomeStandaloneLeader() {
+	leadership.mu.Lock()
+	defer leadership.mu.Unlock()
+	leadership.standalone = true
+	leadership.isLeader = true
+	leadership.leader = instanceID
+	leadership.since = time.Now()
+}
+
+// leaderLeaseStore diimplementasikan store yang bisa dibagi beberapa instance
+// (MySQL). AcquireLeaderLease mengambil atau memperpanjang lease name atas nama
+// holder dan mengembalikan id instance yang memegang lease setelah percobaan ini.
+type leaderLeaseStore interface {
+	AcquireLeaderLease(name, holder string, ttl time.Duration) (string, error)
+}
+
+// Tanpa method ini MySQL diam-diam berjalan sebagai standalone leader
+var _ leaderLeaseStore = (*mysqlStore)(nil)
+
+func runLeaderElection(store leaderLeaseStore) {
+	interval := leaderLease / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	for {
+		// Waktu sebelum query dipakai sebagai waktu perpanjangan (lebih konservatif)
+		now := time.Now()
+		holder, err := store.AcquireLeaderLease(leaderLeaseName, instanceID, leaderLease)
+
+		leadership.mu.Lock()
+		wasLeader := leadership.isLeader
+		if err != nil {
+			leadership.lastError = err.Error()
+		} else {
+			leadership.lastError = ""
+			leadership.leader = holder
+			leadership.isLeader = holder == instanceID
+			if leadership.isLeader {
+				leadership.renewedAt = now
+				if !wasLeader {
+					leadership.since = now
+				}
+			}
+		}
+		// Tidak bisa memperbarui lease sebelum habis: mundur supaya tidak ada dua leader
+		if leadership.isLeader && now.Sub(leadership.renewedAt) >= leaderLease*2/3 {
+			leadership.isLeader = false
+		}
+		isLeaderNow := leadership.isLeader
+		currentLeader := leadership.leader
+		leadership.mu.Unlock()
+
+		if err != nil {
+			log.Println("error leader lease:", err)
+		}
+		switch {
+		case isLeaderNow && !wasLeader:
+			log.Printf("👑 Instance %s is now the leader\n", instanceID)
+			wakeReplicationWorkers()
+			wakeDrainMonitor()
+		case !isLeaderNow && wasLeader:
+			log.Printf("🪦 Instance %s lost leadership (leader: %s)\n", instanceID, currentLeader)
+		}
+
+		time.Sleep(interval)
+	}
+}
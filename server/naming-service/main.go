@@ -1,20 +1,20 @@
 package main
 
 import (
-	"bytes"
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 type Node struct {
@@ -22,8 +22,22 @@ type Node struct {
 	Address       string     `json:"address"`
 	Status        string     `json:"status"`
 	Role          string     `json:"role"`
+	Zone          string     `json:"zone"`
+	Rack          string     `json:"rack"`
 	LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
 	LatencyMs     int64      `json:"latency_ms,omitempty"`
+
+	// Diisi dari heartbeat yang dikirim storage node (NULL jika node tidak push)
+	CapacityBytes    *int64     `json:"capacity_bytes,omitempty"`
+	FreeBytes        *int64     `json:"free_bytes,omitempty"`
+	InflightRequests *int64     `json:"inflight_requests,omitempty"`
+	LeaseExpiresAt   *time.Time `json:"lease_expires_at,omitempty"`
+
+	// Waktu node pertama kali tercatat DOWN, NULL selama node UP
+	DownSince *time.Time `json:"down_since,omitempty"`
+
+	// NONE, DRAINING atau DECOMMISSIONED
+	DrainState string `json:"drain_state"`
 }
 
 type NodeStatus struct {
@@ -39,6 +53,15 @@ type FileMetadata struct {
 	ChecksumSHA256   string   `json:"checksum_sha256"`
 	UploadedAt       string   `json:"uploaded_at"`
 	Replicas         []string `json:"replicas"`
+	StorageMode      string   `json:"storage_mode"` // REPLICATED / CHUNKED / ERASURE
+	ECDataShards     int      `json:"ec_data_shards,omitempty"`
+	ECParityShards   int      `json:"ec_parity_shards,omitempty"`
+	ECBlockSize      int64    `json:"-"`
+	// Diisi ListFiles: versi terbaru file_key dan object yang menyimpannya
+	Version   int    `json:"version,omitempty"`
+	ObjectKey string `json:"object_key,omitempty"`
+	// Terisi jika object ada di trash (soft delete)
+	TrashedAt *time.Time `json:"trashed_at,omitempty"`
 }
 
 type ReplicationQueueItem struct {
@@ -51,9 +74,14 @@ type ReplicationQueueItem struct {
 	LastAttempt  *time.Time `json:"last_attempt,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	ErrorMessage string     `json:"error_message,omitempty"`
-}
+	ChunkIndex   *int       `json:"chunk_index,omitempty"` // NULL = seluruh file
+	ShardIndex   *int       `json:"shard_index,omitempty"` // terisi = satu shard erasure
 
-var db *sql.DB
+	// Lease worker dan jadwal retry berikutnya (backoff)
+	LeaseOwner     *string    `json:"lease_owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+}
 
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
@@ -62,76 +90,16 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func initDB() {
-	// Baca config dari environment variables atau gunakan default
-	dbHost := getEnv("DB_HOST", "127.0.0.1")
-	dbPort := getEnv("DB_PORT", "3306")
-	dbUser := getEnv("DB_USER", "dfs_user")
-	dbPassword := getEnv("DB_PASSWORD", "admin123")
-	dbName := getEnv("DB_NAME", "dfs_meta")
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPassword, dbHost, dbPort, dbName)
-
-	var err error
-	db, err = sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatalf("gagal buka koneksi ke MySQL: %v", err)
-	}
-
-	// Retry connection (untuk Docker - MySQL mungkin belum siap)
-	for i := 0; i < 30; i++ {
-		if err := db.Ping(); err == nil {
-			break
-		}
-		log.Printf("Menunggu MySQL... (%d/30)\n", i+1)
-		time.Sleep(2 * time.Second)
-	}
-
-	if err := db.Ping(); err != nil {
-		log.Fatalf("gagal ping MySQL: %v", err)
-	}
-
-	log.Println("Terhubung ke MySQL dfs_meta")
-}
-
 func getAllNodes() ([]Node, error) {
-	rows, err := db.Query(`
-        SELECT id, address, status, role, last_heartbeat, COALESCE(latency_ms, 0) as latency_ms
-        FROM nodes
-    `)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var nodes []Node
-	for rows.Next() {
-		var n Node
-		if err := rows.Scan(&n.ID, &n.Address, &n.Status, &n.Role, &n.LastHeartbeat, &n.LatencyMs); err != nil {
-			return nil, err
-		}
-		nodes = append(nodes, n)
-	}
-
-	return nodes, rows.Err()
+	return metadata.ListNodes()
 }
 
 func updateNodeStatus(nodeID string, status string) error {
-	_, err := db.Exec(`
-		UPDATE nodes 
-		SET status = ?, last_heartbeat = NOW() 
-		WHERE id = ?
-	`, status, nodeID)
-	return err
+	return metadata.SetNodeStatus(nodeID, status)
 }
 
 func updateNodeLatency(nodeID string, latencyMs int64) error {
-	_, err := db.Exec(`
-		UPDATE nodes 
-		SET latency_ms = ?
-		WHERE id = ?
-	`, latencyMs, nodeID)
-	return err
+	return metadata.SetNodeLatency(nodeID, latencyMs)
 }
 
 func measureNodeLatency(nodeAddr string) int64 {
@@ -142,146 +110,124 @@ func measureNodeLatency(nodeAddr string) int64 {
 	elapsed := time.Since(start).Milliseconds()
 
 	if err != nil {
-		return 9999 // Return high latency if node is unreachable
+		return unreachableLatencyMs // Return high latency if node is unreachable
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 9999
+		return unreachableLatencyMs
 	}
 
 	return elapsed
 }
 
-func selectBestNodeForUpload(nodes []Node) *Node {
-	// Semua node sekarang bisa handle upload dan replikasi
-	// Pilih node yang UP dengan latency terendah
-
-	var bestNode *Node
-	lowestLatency := int64(9999)
+func addToReplicationQueue(fileKey, targetNodeID, sourceNodeID string) error {
+	return enqueueReplication(fileKey, nil, nil, targetNodeID, sourceNodeID)
+}
 
-	for i := range nodes {
-		node := &nodes[i]
-		if node.Status == "UP" {
-			if node.LatencyMs < lowestLatency {
-				lowestLatency = node.LatencyMs
-				bestNode = node
-			}
-		}
-	}
+// addChunkToReplicationQueue mengantrikan replikasi satu chunk saja
+func addChunkToReplicationQueue(fileKey string, chunkIndex int, targetNodeID, sourceNodeID string) error {
+	return enqueueReplication(fileKey, &chunkIndex, nil, targetNodeID, sourceNodeID)
+}
 
-	return bestNode
+// addShardToReplicationQueue mengantrikan penyalinan satu shard erasure ke node lain
+func addShardToReplicationQueue(fileKey string, shardIndex int, targetNodeID, sourceNodeID string) error {
+	return enqueueReplication(fileKey, nil, &shardIndex, targetNodeID, sourceNodeID)
 }
 
-func selectBestNodeForDownload(fileKey string, nodes []Node) *Node {
-	// Get nodes that have the file
-	nodeIDs, err := getFileLocations(fileKey)
-	if err != nil || len(nodeIDs) == 0 {
-		return nil
+func enqueueReplication(fileKey string, chunkIndex, shardIndex *int, targetNodeID, sourceNodeID string) error {
+	err := metadata.EnqueueReplication(fileKey, chunkIndex, shardIndex, targetNodeID, sourceNodeID)
+	if err == nil {
+		wakeReplicationWorkers()
 	}
+	return err
+}
 
-	// Create map for quick lookup
-	hasFileMap := make(map[string]bool)
-	for _, nodeID := range nodeIDs {
-		hasFileMap[nodeID] = true
+// registerFile mendaftarkan upload replicated secara atomik lalu membangunkan
+// worker jika ada node yang perlu disusul
+func registerFile(reg FileRegistration) (bool, error) {
+	replayed, err := metadata.RegisterFile(reg)
+	if err == nil && !replayed && len(reg.FailedNodeIDs) > 0 {
+		wakeReplicationWorkers()
 	}
-
-	// Find best node among those that have the file
-	var bestNode *Node
-	lowestLatency := int64(9999)
-
-	for i := range nodes {
-		node := &nodes[i]
-		if node.Status == "UP" && hasFileMap[node.ID] {
-			if node.LatencyMs < lowestLatency {
-				lowestLatency = node.LatencyMs
-				bestNode = node
-			}
-		}
+	if err == nil && !replayed && reg.objectKey() != reg.FileKey {
+		go pruneFileVersions(reg.FileKey)
 	}
-
-	return bestNode
+	return replayed, err
 }
 
-func addToReplicationQueue(fileKey, targetNodeID, sourceNodeID string) error {
-	_, err := db.Exec(`
-		INSERT INTO replication_queue (file_key, target_node_id, source_node_id, status)
-		VALUES (?, ?, ?, 'PENDING')
-	`, fileKey, targetNodeID, sourceNodeID)
-	return err
+func markReplicationCompleted(queueID int) error {
+	return metadata.CompleteReplication(queueID)
 }
 
-func getPendingReplications(targetNodeID string) ([]ReplicationQueueItem, error) {
-	rows, err := db.Query(`
-		SELECT id, file_key, target_node_id, source_node_id, status, retry_count, last_attempt, created_at
-		FROM replication_queue
-		WHERE target_node_id = ? AND status = 'PENDING'
-		ORDER BY created_at ASC
-		LIMIT 100
-	`, targetNodeID)
-	if err != nil {
-		return nil, err
+// markReplicationFailed menjadwalkan retry dengan exponential backoff. Setelah
+// REPLICATION_MAX_RETRIES percobaan, item dipindah ke DEAD (dead-letter).
+func markReplicationFailed(item ReplicationQueueItem, errorMsg string) error {
+	status := "FAILED"
+	if item.RetryCount+1 >= replicationMaxRetries {
+		status = "DEAD"
+		log.Printf("☠️ Replication %d (%s -> %s) moved to dead-letter after %d attempts\n", item.ID, item.FileKey, item.TargetNodeID, item.RetryCount+1)
 	}
-	defer rows.Close()
 
-	var items []ReplicationQueueItem
-	for rows.Next() {
-		var item ReplicationQueueItem
-		if err := rows.Scan(&item.ID, &item.FileKey, &item.TargetNodeID, &item.SourceNodeID,
-			&item.Status, &item.RetryCount, &item.LastAttempt, &item.CreatedAt); err != nil {
-			return nil, err
-		}
-		items = append(items, item)
-	}
+	return metadata.FailReplication(item.ID, status, errorMsg, replicationBackoff(item.RetryCount))
+}
 
-	return items, rows.Err()
+func getFileLocations(fileKey string) ([]string, error) {
+	return metadata.GetFileLocations(fileKey)
 }
 
-func markReplicationCompleted(queueID int) error {
-	_, err := db.Exec(`
-		UPDATE replication_queue 
-		SET status = 'COMPLETED', completed_at = NOW()
-		WHERE id = ?
-	`, queueID)
-	return err
+// getFileRecord mengambil satu row files. Replicas tidak diisi.
+func getFileRecord(fileKey string) (*FileMetadata, error) {
+	return metadata.GetFile(fileKey)
 }
 
-func markReplicationFailed(queueID int, errorMsg string) error {
-	_, err := db.Exec(`
-		UPDATE replication_queue 
-		SET status = 'FAILED', retry_count = retry_count + 1, last_attempt = NOW(), error_message = ?
-		WHERE id = ?
-	`, errorMsg, queueID)
-	return err
+func markFileLocationActive(fileKey, nodeID string) error {
+	return metadata.SetFileLocationStatus(fileKey, nodeID, "ACTIVE")
 }
 
-func getFileLocations(fileKey string) ([]string, error) {
-	rows, err := db.Query(`
-		SELECT node_id FROM file_locations 
-		WHERE file_key = ? AND status = 'ACTIVE'
-	`, fileKey)
+// uploadFileToNode mengirim file ke satu storage node tanpa replikasi otomatis
+// (is_replica=true), karena placement ditentukan oleh naming service.
+// Isi file di-stream, tidak dibaca penuh ke memory.
+func uploadFileToNode(nodeAddr, fileKey string, file *multipart.FileHeader, checksum string) (map[string]interface{}, error) {
+	fileContent, err := file.Open()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("gagal baca file: %v", err)
 	}
-	defer rows.Close()
+	defer fileContent.Close()
 
-	var nodeIDs []string
-	for rows.Next() {
-		var nodeID string
-		if err := rows.Scan(&nodeID); err != nil {
-			return nil, err
-		}
-		nodeIDs = append(nodeIDs, nodeID)
+	return sendObjectToNode(nodeAddr, fileKey, file.Filename, fileContent, checksum)
+}
+
+// deleteFileFromNode menghapus blob dari storage node dan mengembalikan status HTTP-nya
+func deleteFileFromNode(nodeAddr, fileKey string) (int, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/files/%s", nodeAddr, fileKey), nil)
+	if err != nil {
+		return 0, err
 	}
 
-	return nodeIDs, rows.Err()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
 }
 
-func replicateFileToNode(fileKey, sourceNodeAddr, targetNodeAddr string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
+// replicateFileToNode menyalin object dari source ke target secara streaming.
+// Data yang lewat di-hash dan harus sama dengan checksum di target dan
+// expectedChecksum (checksum yang tercatat di metadata, boleh kosong).
+func replicateFileToNode(fileKey, expectedChecksum, sourceNodeAddr, targetNodeAddr string) error {
+	return replicateFileToNodeThrottled(fileKey, expectedChecksum, sourceNodeAddr, targetNodeAddr, 0)
+}
 
+// replicateFileToNodeThrottled sama dengan replicateFileToNode dengan batas
+// bandwidth bytesPerSec (0 = tanpa batas), dipakai rebalancer
+func replicateFileToNodeThrottled(fileKey, expectedChecksum, sourceNodeAddr, targetNodeAddr string, bytesPerSec int64) error {
 	// Download dari source node
-	resp, err := client.Get(fmt.Sprintf("%s/files/%s", sourceNodeAddr, fileKey))
+	resp, err := streamClient.Get(fmt.Sprintf("%s/files/%s", sourceNodeAddr, fileKey))
 	if err != nil {
 		return fmt.Errorf("gagal download dari source: %v", err)
 	}
@@ -291,16 +237,6 @@ func replicateFileToNode(fileKey, sourceNodeAddr, targetNodeAddr string) error {
 		return fmt.Errorf("source node return status %d", resp.StatusCode)
 	}
 
-	// Baca file content
-	fileContent, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("gagal baca file: %v", err)
-	}
-
-	// Upload ke target node
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
 	// Ambil filename dari header jika ada
 	filename := fileKey
 	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
@@ -311,41 +247,70 @@ func replicateFileToNode(fileKey, sourceNodeAddr, targetNodeAddr string) error {
 		}
 	}
 
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return fmt.Errorf("gagal create form file: %v", err)
+	// Stream langsung dari response source ke target. is_replica=true supaya
+	// target tidak ikut mereplikasi ke node lain di luar placement.
+	if _, err := sendObjectToNode(targetNodeAddr, fileKey, filename, newThrottledReader(resp.Body, bytesPerSec), expectedChecksum); err != nil {
+		return fmt.Errorf("gagal upload ke target: %w", err)
 	}
 
-	if _, err := part.Write(fileContent); err != nil {
-		return fmt.Errorf("gagal write file content: %v", err)
-	}
+	return nil
+}
 
-	writer.Close()
+// replicateQueueItem menjalankan satu item replication_queue: seluruh file,
+// satu chunk jika chunk_index terisi, atau satu shard jika shard_index terisi,
+// lalu menandai lokasinya ACTIVE
+func replicateQueueItem(item ReplicationQueueItem, sourceAddr, targetAddr string) error {
+	// Checksum shard tidak disimpan; verifikasi cukup antara data terkirim dan node
+	if item.ShardIndex != nil {
+		if err := replicateFileToNode(shardObjectKey(item.FileKey, *item.ShardIndex), "", sourceAddr, targetAddr); err != nil {
+			return err
+		}
+		return markShardLocationActive(item.FileKey, *item.ShardIndex, item.TargetNodeID)
+	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/files?file_id=%s", targetNodeAddr, fileKey), body)
+	checksum, err := getObjectChecksum(item.FileKey, item.ChunkIndex)
 	if err != nil {
-		return fmt.Errorf("gagal create request: %v", err)
+		return err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	uploadResp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("gagal upload ke target: %v", err)
+	if item.ChunkIndex != nil {
+		if err := replicateFileToNode(chunkObjectKey(item.FileKey, *item.ChunkIndex), checksum, sourceAddr, targetAddr); err != nil {
+			return err
+		}
+		return markChunkLocationActive(item.FileKey, *item.ChunkIndex, item.TargetNodeID)
 	}
-	defer uploadResp.Body.Close()
 
-	if uploadResp.StatusCode != 200 {
-		return fmt.Errorf("target node return status %d", uploadResp.StatusCode)
+	if err := replicateFileToNode(item.FileKey, checksum, sourceAddr, targetAddr); err != nil {
+		return err
 	}
+	return markFileLocationActive(item.FileKey, item.TargetNodeID)
+}
 
-	return nil
+// autoRecoverNode dipanggil saat node kembali UP: item yang berkaitan dengan
+// node ini langsung dijadwalkan ulang dan worker replikasi dibangunkan
+func autoRecoverNode(node Node, nodes []Node) {
+	log.Printf("🔄 Triggering recovery for node %s\n", node.ID)
+
+	if err := resetReplicationBackoff(node.ID); err != nil {
+		log.Println("error reset replication backoff:", err)
+	}
+	wakeReplicationWorkers()
+	// Delete yang tertunda selama node DOWN dijalankan sekarang
+	wakeTombstoneReplayer()
 }
 
 func main() {
-	initDB()
-	defer db.Close()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
+	initMetadataStore()
+	defer metadata.Close()
 
 	r := gin.Default()
+	// Upload besar di-spool ke temp file oleh parser multipart, bukan ke memory
+	r.MaxMultipartMemory = int64(getEnvInt("UPLOAD_MEMORY_LIMIT_MB", 8)) << 20
 
 	// Health naming service sendiri
 	r.GET("/health", func(c *gin.Context) {
@@ -354,9 +319,19 @@ func main() {
 			"status":   "UP",
 			"service":  "naming-service",
 			"hostname": hostname,
+
+			"placement_policy": placementPolicy.Name(),
+			"metadata_store":   metadata.Name(),
+			"instance_id":      instanceID,
+			"is_leader":        isLeader(),
 		})
 	})
 
+	// Leader naming service saat ini (hanya leader yang menjalankan job background)
+	r.GET("/cluster/leader", func(c *gin.Context) {
+		c.JSON(http.StatusOK, getLeaderStatus())
+	})
+
 	// List node dari database
 	r.GET("/nodes", func(c *gin.Context) {
 		nodes, err := getAllNodes()
@@ -384,6 +359,10 @@ func main() {
 		statuses := make([]NodeStatus, 0, len(nodes))
 
 		for _, n := range nodes {
+			if n.Status == "RETIRED" {
+				continue
+			}
+
 			resp, err := client.Get(n.Address + "/health")
 
 			nodeStatus := NodeStatus{
@@ -392,8 +371,11 @@ func main() {
 				Status:  "DOWN",
 			}
 
-			if err == nil && resp.StatusCode == http.StatusOK {
-				nodeStatus.Status = "UP"
+			if err == nil {
+				if resp.StatusCode == http.StatusOK {
+					nodeStatus.Status = "UP"
+				}
+				resp.Body.Close()
 			}
 
 			statuses = append(statuses, nodeStatus)
@@ -405,15 +387,14 @@ func main() {
 		})
 	})
 
-	// Endpoint untuk register file metadata setelah upload
-	r.POST("/files/register", func(c *gin.Context) {
+	// Registrasi storage node baru tanpa perlu edit schema.sql
+	r.POST("/nodes/register", func(c *gin.Context) {
 		var req struct {
-			FileKey          string   `json:"file_key"`
-			OriginalFilename string   `json:"original_filename"`
-			SizeBytes        int64    `json:"size_bytes"`
-			ChecksumSHA256   string   `json:"checksum_sha256"`
-			NodeID           string   `json:"node_id"`
-			FailedNodes      []string `json:"failed_nodes"`
+			ID      string `json:"id"`
+			Address string `json:"address"`
+			Role    string `json:"role"`
+			Zone    string `json:"zone"`
+			Rack    string `json:"rack"`
 		}
 
 		if err := c.BindJSON(&req); err != nil {
@@ -421,461 +402,1548 @@ func main() {
 			return
 		}
 
-		// Simpan metadata file
-		_, err := db.Exec(`
-			INSERT INTO files (file_key, original_filename, size_bytes, checksum_sha256)
-			VALUES (?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE 
-				original_filename = VALUES(original_filename),
-				size_bytes = VALUES(size_bytes),
-				checksum_sha256 = VALUES(checksum_sha256)
-		`, req.FileKey, req.OriginalFilename, req.SizeBytes, req.ChecksumSHA256)
+		if req.Role == "" {
+			req.Role = "REPLICA"
+		}
 
-		if err != nil {
-			log.Println("error insert file metadata:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
+		if err := validateNodeID(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Simpan lokasi file
-		_, err = db.Exec(`
-			INSERT INTO file_locations (file_key, node_id, status)
-			VALUES (?, ?, 'ACTIVE')
-			ON DUPLICATE KEY UPDATE status = 'ACTIVE'
-		`, req.FileKey, req.NodeID)
-
+		if err := validateNodeRole(req.Role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		address, err := normalizeNodeAddress(req.Address)
 		if err != nil {
-			log.Println("error insert file location:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateNodeLabels(req.Zone, req.Rack); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		// Tambahkan ke replication queue untuk node yang gagal
-		for _, failedNodeID := range req.FailedNodes {
-			if err := addToReplicationQueue(req.FileKey, failedNodeID, req.NodeID); err != nil {
-				log.Printf("error add to replication queue for node %s: %v\n", failedNodeID, err)
+		if err := registerNode(req.ID, address, req.Role, req.Zone, req.Rack); err != nil {
+			if err == errNodeExists {
+				c.JSON(http.StatusConflict, gin.H{"error": "node sudah terdaftar"})
+				return
 			}
+			log.Println("error register node:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal register node"})
+			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "file metadata registered",
-		})
-	})
-
-	// Endpoint untuk register lokasi file tambahan (untuk replikasi)
-	r.POST("/files/register-location", func(c *gin.Context) {
-		var req struct {
-			FileKey string `json:"file_key"`
-			NodeID  string `json:"node_id"`
+		// Hanya boleh ada satu MAIN, MAIN lama turun menjadi REPLICA
+		if req.Role == "MAIN" {
+			if err := promoteNode(req.ID); err != nil {
+				log.Println("error promote node:", err)
+			}
 		}
 
-		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-			return
+		// Langsung cek health supaya node bisa dipakai tanpa menunggu ticker
+		latency := measureNodeLatency(address)
+		updateNodeLatency(req.ID, latency)
+		if latency < unreachableLatencyMs {
+			updateNodeStatus(req.ID, "UP")
 		}
 
-		_, err := db.Exec(`
-			INSERT INTO file_locations (file_key, node_id, status)
-			VALUES (?, ?, 'ACTIVE')
-			ON DUPLICATE KEY UPDATE status = 'ACTIVE'
-		`, req.FileKey, req.NodeID)
-
+		node, err := getNode(req.ID)
 		if err != nil {
-			log.Println("error insert file location:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan lokasi"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data node"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true})
+		log.Printf("➕ Node %s registered at %s (role: %s, status: %s)\n", node.ID, node.Address, node.Role, node.Status)
+
+		c.JSON(http.StatusCreated, node)
 	})
 
-	// Endpoint untuk recovery - sync file yang pending ke node yang baru UP
-	r.POST("/nodes/:nodeId/recover", func(c *gin.Context) {
+	// Update address / role node
+	r.PUT("/nodes/:nodeId", func(c *gin.Context) {
 		nodeID := c.Param("nodeId")
 
-		// Ambil pending replications untuk node ini
-		items, err := getPendingReplications(nodeID)
-		if err != nil {
-			log.Println("error get pending replications:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil pending replications"})
-			return
+		// zone/rack: null = tidak diubah, "" = hapus label
+		var req struct {
+			Address string  `json:"address"`
+			Role    string  `json:"role"`
+			Zone    *string `json:"zone"`
+			Rack    *string `json:"rack"`
 		}
 
-		if len(items) == 0 {
-			c.JSON(http.StatusOK, gin.H{
-				"message": "no pending replications",
-				"count":   0,
-			})
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
 
-		// Ambil info node target dan source
-		nodes, err := getAllNodes()
+		node, err := getNode(nodeID)
+		if err == errNodeNotFound || (err == nil && node.Status == "RETIRED") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data node"})
 			return
 		}
 
-		nodeMap := make(map[string]string)
-		for _, n := range nodes {
-			nodeMap[n.ID] = n.Address
+		// Field yang kosong tidak diubah
+		address := node.Address
+		if req.Address != "" {
+			address, err = normalizeNodeAddress(req.Address)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 		}
-
-		// Proses setiap item
-		successCount := 0
-		failCount := 0
-
-		for _, item := range items {
-			sourceAddr, sourceOk := nodeMap[item.SourceNodeID]
-			targetAddr, targetOk := nodeMap[item.TargetNodeID]
-
-			if !sourceOk || !targetOk {
-				markReplicationFailed(item.ID, "node not found")
-				failCount++
-				continue
+		role := node.Role
+		if req.Role != "" {
+			if err := validateNodeRole(req.Role); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
+			role = req.Role
+		}
+		zone, rack := node.Zone, node.Rack
+		if req.Zone != nil {
+			zone = *req.Zone
+		}
+		if req.Rack != nil {
+			rack = *req.Rack
+		}
+		if err := validateNodeLabels(zone, rack); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-			// Lakukan replikasi
-			if err := replicateFileToNode(item.FileKey, sourceAddr, targetAddr); err != nil {
-				log.Printf("replication failed for queue %d: %v\n", item.ID, err)
-				markReplicationFailed(item.ID, err.Error())
-				failCount++
-			} else {
-				markReplicationCompleted(item.ID)
-
-				// Update file_locations
-				db.Exec(`
-					INSERT INTO file_locations (file_key, node_id, status)
-					VALUES (?, ?, 'ACTIVE')
-					ON DUPLICATE KEY UPDATE status = 'ACTIVE'
-				`, item.FileKey, item.TargetNodeID)
-
-				successCount++
-				log.Printf("✅ Replicated %s to %s\n", item.FileKey, item.TargetNodeID)
+		if err := updateNode(nodeID, address, role, zone, rack); err != nil {
+			if err == errNodeNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+				return
+			}
+			log.Println("error update node:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal update node"})
+			return
+		}
+		if role == "MAIN" && node.Role != "MAIN" {
+			if err := promoteNode(nodeID); err != nil {
+				log.Println("error promote node:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal promote node"})
+				return
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "recovery completed",
-			"total":         len(items),
-			"success":       successCount,
-			"failed":        failCount,
-			"pending_items": items,
-		})
-	})
+		node, err = getNode(nodeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data node"})
+			return
+		}
 
-	// Endpoint untuk melihat replication queue
-	r.GET("/replication-queue", func(c *gin.Context) {
-		nodeID := c.Query("node_id")
-		status := c.Query("status")
+		c.JSON(http.StatusOK, node)
+	})
 
-		query := "SELECT id, file_key, target_node_id, source_node_id, status, retry_count, last_attempt, created_at FROM replication_queue WHERE 1=1"
-		args := []interface{}{}
+	// Retire node: node tidak lagi dipakai untuk upload, download maupun health check.
+	// Ditolak jika masih ada file ACTIVE di node tersebut, kecuali ?force=true.
+	r.DELETE("/nodes/:nodeId", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+		force := c.Query("force") == "true"
 
-		if nodeID != "" {
-			query += " AND target_node_id = ?"
-			args = append(args, nodeID)
+		node, err := getNode(nodeID)
+		if err == errNodeNotFound || (err == nil && node.Status == "RETIRED") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
 		}
-
-		if status != "" {
-			query += " AND status = ?"
-			args = append(args, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data node"})
+			return
 		}
 
-		query += " ORDER BY created_at DESC LIMIT 100"
-
-		rows, err := db.Query(query, args...)
+		activeFiles, err := countActiveLocationsOnNode(nodeID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal query queue"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal cek file locations"})
 			return
 		}
-		defer rows.Close()
 
-		var items []ReplicationQueueItem
-		for rows.Next() {
-			var item ReplicationQueueItem
-			if err := rows.Scan(&item.ID, &item.FileKey, &item.TargetNodeID, &item.SourceNodeID,
-				&item.Status, &item.RetryCount, &item.LastAttempt, &item.CreatedAt); err != nil {
-				continue
-			}
-			items = append(items, item)
+		if activeFiles > 0 && !force {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":        "node masih menyimpan file aktif, gunakan ?force=true untuk tetap retire",
+				"active_files": activeFiles,
+			})
+			return
 		}
 
+		if err := retireNode(nodeID); err != nil {
+			log.Println("error retire node:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal retire node"})
+			return
+		}
+
+		log.Printf("➖ Node %s retired (%d active files dropped)\n", nodeID, activeFiles)
+
 		c.JSON(http.StatusOK, gin.H{
-			"items": items,
-			"count": len(items),
+			"success":       true,
+			"node_id":       nodeID,
+			"status":        "RETIRED",
+			"dropped_files": activeFiles,
 		})
 	})
 
-	// Endpoint untuk upload file via naming service
-	r.POST("/upload", func(c *gin.Context) {
-		// Get file from request
-		file, err := c.FormFile("file")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+	// Drain: berhenti menerima upload baru, pindahkan semua salinan ke node lain,
+	// lalu decommission otomatis setelah node kosong
+	r.POST("/nodes/:nodeId/drain", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+
+		if err := startDrain(nodeID); err != nil {
+			if err == errNodeNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+				return
+			}
+			log.Println("error start drain:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal memulai drain"})
 			return
 		}
 
-		// Get all nodes
-		nodes, err := getAllNodes()
+		status, err := getDrainStatus(nodeID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil status drain"})
 			return
 		}
+		c.JSON(http.StatusAccepted, status)
+	})
 
-		// Select best node based on latency
-		bestNode := selectBestNodeForUpload(nodes)
-		if bestNode == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no available nodes"})
+	r.GET("/nodes/:nodeId/drain", func(c *gin.Context) {
+		status, err := getDrainStatus(c.Param("nodeId"))
+		if err == errNodeNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
 			return
 		}
-
-		log.Printf("📤 Routing upload to %s (latency: %dms)\n", bestNode.ID, bestNode.LatencyMs)
-
-		// Forward file to selected node
-		fileContent, err := file.Open()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal baca file"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil status drain"})
 			return
 		}
-		defer fileContent.Close()
+		c.JSON(http.StatusOK, status)
+	})
 
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("file", file.Filename)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal create form"})
+	// Jadikan node MAIN; MAIN lama turun menjadi REPLICA
+	r.POST("/nodes/:nodeId/promote", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+
+		if err := promoteNode(nodeID); err != nil {
+			if err == errNodeNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+				return
+			}
+			log.Println("error promote node:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal promote node"})
 			return
 		}
 
-		if _, err := io.Copy(part, fileContent); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal copy file"})
+		log.Printf("👑 Node %s promoted to MAIN\n", nodeID)
+		c.JSON(http.StatusOK, gin.H{"success": true, "node_id": nodeID, "role": "MAIN"})
+	})
+
+	// Turunkan node ke REPLICA (default) atau BACKUP. Jika node ini MAIN,
+	// REPLICA terbaik langsung dipromosikan menggantikannya.
+	r.POST("/nodes/:nodeId/demote", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+
+		var req struct {
+			Role string `json:"role"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+				return
+			}
+		}
+		if req.Role == "" {
+			req.Role = "REPLICA"
+		}
+		if req.Role != "REPLICA" && req.Role != "BACKUP" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role harus REPLICA atau BACKUP"})
 			return
 		}
-		writer.Close()
 
-		// Send to storage node
-		client := &http.Client{Timeout: 60 * time.Second}
-		req, err := http.NewRequest("POST", bestNode.Address+"/files", body)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal create request"})
+		node, err := getNode(nodeID)
+		if err == errNodeNotFound || (err == nil && node.Status == "RETIRED") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
 			return
 		}
-		req.Header.Set("Content-Type", writer.FormDataContentType())
-
-		resp, err := client.Do(req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("gagal upload ke node: %v", err)})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal mengambil data node"})
 			return
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != 200 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("node return status %d", resp.StatusCode)})
-			return
+		promoted := ""
+		if node.Role == "MAIN" {
+			nodes, err := getAllNodes()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+				return
+			}
+			candidate := pickPromotionCandidate(nodes, nodeID)
+			if candidate == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "tidak ada REPLICA UP untuk menggantikan MAIN"})
+				return
+			}
+			if err := promoteNode(candidate.ID); err != nil {
+				log.Println("error promote node:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal promote node pengganti"})
+				return
+			}
+			promoted = candidate.ID
 		}
 
-		// Parse response from storage node
-		var uploadResp map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal parse response"})
+		if err := setNodeRole(nodeID, req.Role); err != nil {
+			log.Println("error demote node:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal demote node"})
 			return
 		}
 
-		// Add routing info to response
-		uploadResp["routed_via"] = "naming-service"
-		uploadResp["selected_node"] = bestNode.ID
-		uploadResp["node_latency_ms"] = bestNode.LatencyMs
-
-		c.JSON(http.StatusOK, uploadResp)
+		log.Printf("⬇️ Node %s demoted to %s\n", nodeID, req.Role)
+		resp := gin.H{"success": true, "node_id": nodeID, "role": req.Role}
+		if promoted != "" {
+			resp["promoted_main"] = promoted
+		}
+		c.JSON(http.StatusOK, resp)
 	})
 
-	// Endpoint untuk download file via naming service
-	r.GET("/download/:fileKey", func(c *gin.Context) {
-		fileKey := c.Param("fileKey")
+	// Batalkan drain; salinan yang sudah dipindah tetap di node baru
+	r.DELETE("/nodes/:nodeId/drain", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
 
-		// Get all nodes
-		nodes, err := getAllNodes()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+		if err := cancelDrain(nodeID); err != nil {
+			if err == errNodeNotDraining {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal membatalkan drain"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "node_id": nodeID, "drain_state": "NONE"})
+	})
+
+	// Heartbeat push dari storage node: perpanjang lease dan simpan kapasitas/load
+	r.POST("/nodes/:nodeId/heartbeat", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+
+		var report HeartbeatReport
+		if c.Request.ContentLength != 0 {
+			if err := c.BindJSON(&report); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+				return
+			}
+		}
+
+		previousStatus, err := recordHeartbeat(nodeID, report)
+		if err == errNodeNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
+		if err != nil {
+			log.Println("error record heartbeat:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan heartbeat"})
+			return
+		}
+
+		// Node yang baru kembali UP lewat heartbeat juga perlu di-recover
+		if previousStatus != "UP" {
+			log.Printf("Node %s status changed: %s -> UP (heartbeat)\n", nodeID, previousStatus)
+			if nodes, err := getAllNodes(); err == nil {
+				if node, err := getNode(nodeID); err == nil {
+					go autoRecoverNode(*node, nodes)
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":       true,
+			"node_id":       nodeID,
+			"lease_seconds": int(heartbeatLease.Seconds()),
+		})
+	})
+
+	// Endpoint untuk register file metadata setelah upload
+	r.POST("/files/register", func(c *gin.Context) {
+		var req struct {
+			FileKey          string   `json:"file_key"`
+			OriginalFilename string   `json:"original_filename"`
+			SizeBytes        int64    `json:"size_bytes"`
+			ChecksumSHA256   string   `json:"checksum_sha256"`
+			ObjectKey        string   `json:"object_key"`
+			NodeID           string   `json:"node_id"`
+			ReplicaNodes     []string `json:"replica_nodes"`
+			FailedNodes      []string `json:"failed_nodes"`
+			IdempotencyKey   string   `json:"idempotency_key"`
+		}
+
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if req.FileKey == "" || req.NodeID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file_key dan node_id wajib diisi"})
+			return
+		}
+
+		// Header Idempotency-Key didahulukan, field JSON untuk client lama
+		idempotencyKey := c.GetHeader("Idempotency-Key")
+		if idempotencyKey == "" {
+			idempotencyKey = req.IdempotencyKey
+		}
+		if len(idempotencyKey) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "idempotency key maksimal 100 karakter"})
+			return
+		}
+
+		// Metadata, lokasi dan antrian replikasi untuk node yang gagal disimpan
+		// sekaligus. object_key yang belum tercatat menjadi versi baru file_key.
+		replayed, err := registerFile(FileRegistration{
+			FileKey:          req.FileKey,
+			ObjectKey:        req.ObjectKey,
+			OriginalFilename: req.OriginalFilename,
+			SizeBytes:        req.SizeBytes,
+			Checksum:         req.ChecksumSHA256,
+			NodeIDs:          append([]string{req.NodeID}, req.ReplicaNodes...),
+			FailedNodeIDs:    req.FailedNodes,
+			SourceNodeID:     req.NodeID,
+			IdempotencyKey:   idempotencyKey,
+		})
+		if err == errIdempotencyKeyReused {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("error register file:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
+			return
+		}
+		if replayed {
+			log.Printf("🔁 Register %s replayed (idempotency key %s)\n", req.FileKey, idempotencyKey)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":           true,
+			"message":           "file metadata registered",
+			"idempotent_replay": replayed,
+		})
+	})
+
+	// Endpoint untuk register lokasi file tambahan (untuk replikasi)
+	r.POST("/files/register-location", func(c *gin.Context) {
+		var req struct {
+			FileKey string `json:"file_key"`
+			NodeID  string `json:"node_id"`
+		}
+
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		if err := markFileLocationActive(req.FileKey, req.NodeID); err != nil {
+			log.Println("error insert file location:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan lokasi"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	// Endpoint untuk recovery - sync file yang pending ke node yang baru UP
+	r.POST("/nodes/:nodeId/recover", func(c *gin.Context) {
+		nodeID := c.Param("nodeId")
+
+		// Lease semua item PENDING/FAILED untuk node ini (tanpa menunggu backoff)
+		// supaya tidak diproses bersamaan oleh worker replikasi
+		items, err := leaseReplications("manual-recover-"+nodeID, nodeID, 100)
+		if err != nil {
+			log.Println("error get pending replications:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil pending replications"})
+			return
+		}
+
+		if len(items) == 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "no pending replications",
+				"count":   0,
+			})
+			return
+		}
+
+		// Proses setiap item
+		successCount := 0
+		failCount := 0
+
+		for _, item := range items {
+			if err := runReplicationItem(item); err != nil {
+				failCount++
+			} else {
+				successCount++
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "recovery completed",
+			"total":         len(items),
+			"success":       successCount,
+			"failed":        failCount,
+			"pending_items": items,
+		})
+	})
+
+	// Endpoint untuk melihat replication queue
+	r.GET("/replication-queue", func(c *gin.Context) {
+		nodeID := c.Query("node_id")
+		status := c.Query("status")
+
+		items, err := metadata.ListReplications(nodeID, status, 100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal query queue"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items": items,
+			"count": len(items),
+		})
+	})
+
+	// Delete tombstone yang menunggu node kembali UP
+	r.GET("/tombstones", func(c *gin.Context) {
+		tombstones, err := metadata.ListTombstones(c.Query("node_id"), 100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil tombstone"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"tombstones": tombstones,
+			"count":      len(tombstones),
+		})
+	})
+
+	// Hasil putaran terakhir re-replication planner, termasuk rebuild shard erasure
+	r.GET("/rereplication/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":          rereplicationInterval > 0,
+			"grace_minutes":    int(rereplicationGrace.Minutes()),
+			"interval_seconds": int(rereplicationInterval.Seconds()),
+			"last_pass":        rereplication.snapshot(),
+		})
+	})
+
+	// Progress scrubber: putaran yang sedang berjalan dan hasil putaran terakhir
+	r.GET("/scrubber/status", func(c *gin.Context) {
+		current, lastPass := scrubber.snapshot()
+
+		progress := 0.0
+		if current != nil && current.TotalFiles > 0 {
+			progress = float64(current.FilesScanned) / float64(current.TotalFiles) * 100
+			if progress > 100 {
+				progress = 100
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":          scrubFilesPerMinute > 0,
+			"files_per_minute": scrubFilesPerMinute,
+			"running":          current != nil,
+			"progress_percent": progress,
+			"current":          current,
+			"last_pass":        lastPass,
+		})
+	})
+
+	// Laporan file/chunk yang punya beberapa salinan di failure domain yang sama
+	r.GET("/placement/violations", func(c *gin.Context) {
+		violations, err := findPlacementViolations()
+		if err != nil {
+			log.Println("error cek failure domain:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal cek failure domain"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"failure_domain_level": failureDomainLevel,
+			"strict":               failureDomainStrict,
+			"violations":           violations,
+			"count":                len(violations),
+		})
+	})
+
+	// Rebalance: ?dry_run=true hanya mengembalikan plan, selain itu plan dijalankan
+	// di background. ?tolerance_percent override REBALANCE_TOLERANCE_PERCENT.
+	r.POST("/rebalance", func(c *gin.Context) {
+		tolerance := rebalanceTolerancePercent
+		if raw := c.Query("tolerance_percent"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tolerance_percent harus angka >= 0"})
+				return
+			}
+			tolerance = n
+		}
+
+		plan, err := buildRebalancePlan(tolerance)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal menyusun rencana rebalance"})
+			return
+		}
+
+		if c.Query("dry_run") == "true" || len(plan.Moves) == 0 {
+			c.JSON(http.StatusOK, gin.H{"dry_run": c.Query("dry_run") == "true", "started": false, "plan": plan})
+			return
+		}
+
+		// Rebalance berjalan di proses ini, jadi hanya leader yang boleh menjalankannya
+		if !isLeader() {
+			status := getLeaderStatus()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "instance ini bukan leader", "leader": status.Leader})
+			return
+		}
+
+		if err := startRebalance(plan); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"dry_run": false, "started": true, "plan": plan})
+	})
+
+	r.GET("/rebalance/status", func(c *gin.Context) {
+		current, last := rebalancer.snapshot()
+		c.JSON(http.StatusOK, gin.H{
+			"running":        current != nil,
+			"bandwidth_mbps": rebalanceBandwidthMBps,
+			"current":        current,
+			"last_run":       last,
+		})
+	})
+
+	// Orphan GC: default dry run (hanya laporan orphan dan missing);
+	// ?dry_run=false menghapus orphan yang lebih tua dari ORPHAN_GC_MIN_AGE_HOURS
+	r.POST("/gc/orphans", func(c *gin.Context) {
+		dryRun := c.Query("dry_run") != "false"
+
+		// Delete object di node hanya boleh dari leader, seperti rebalance
+		if !dryRun && !isLeader() {
+			status := getLeaderStatus()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "instance ini bukan leader", "leader": status.Leader})
+			return
+		}
+
+		report, err := runOrphanGC(dryRun)
+		if errors.Is(err, errOrphanGCRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal menjalankan orphan GC"})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	r.GET("/gc/orphans", func(c *gin.Context) {
+		running, last := orphanGC.snapshot()
+		c.JSON(http.StatusOK, gin.H{
+			"running":          running,
+			"min_age_hours":    int(orphanGCMinAge.Hours()),
+			"interval_hours":   int(orphanGCInterval.Hours()),
+			"scheduled_delete": orphanGCDelete,
+			"last_run":         last,
+		})
+	})
+
+	// Kembalikan item dead-letter ke antrian setelah penyebabnya diperbaiki
+	r.POST("/replication-queue/:id/retry", func(c *gin.Context) {
+		queueID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id tidak valid"})
+			return
+		}
+
+		ok, err := retryDeadReplication(queueID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal update replication queue"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "item DEAD tidak ditemukan"})
+			return
+		}
+
+		wakeReplicationWorkers()
+		c.JSON(http.StatusOK, gin.H{"success": true, "id": queueID, "status": "PENDING"})
+	})
+
+	// Endpoint untuk upload file via naming service
+	r.POST("/upload", func(c *gin.Context) {
+		// Get file from request
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+			return
+		}
+
+		replicationFactor, err := parseReplicationFactor(c.DefaultPostForm("replication_factor", c.Query("replication_factor")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Checksum dihitung sendiri oleh naming service lalu dicocokkan dengan
+		// digest dari client (jika dikirim) sebelum data disimpan ke node
+		clientChecksum, err := parseClientDigest(c.Request.Header, c.PostForm("checksum_sha256"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		checksum, err := hashMultipartFile(file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("gagal hitung checksum: %v", err)})
+			return
+		}
+		if clientChecksum != "" && clientChecksum != checksum {
+			log.Printf("❌ Upload %s rejected: checksum mismatch (client %s, received %s)\n", file.Filename, clientChecksum, checksum)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "checksum tidak cocok",
+				"expected_checksum": clientChecksum,
+				"received_checksum": checksum,
+			})
+			return
+		}
+
+		// Storage class: REPLICATED (default) atau EC (Reed-Solomon k+m)
+		storageClass := strings.ToUpper(c.DefaultPostForm("storage_class", c.Query("storage_class")))
+		var erasure ErasureParams
+		switch storageClass {
+		case "", "REPLICATED":
+			storageClass = "REPLICATED"
+		case "EC", "ERASURE":
+			storageClass = "ERASURE"
+			erasure, err = parseErasureParams(
+				c.DefaultPostForm("ec_data_shards", c.Query("ec_data_shards")),
+				c.DefaultPostForm("ec_parity_shards", c.Query("ec_parity_shards")),
+			)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			erasure.BlockSize = ecBlockSizeFor(file.Size, erasure.DataShards)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "storage_class harus REPLICATED atau EC"})
+			return
+		}
+
+		// Get all nodes
+		nodes, err := getAllNodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			return
+		}
+
+		// Kandidat placement, urut dari latency terendah
+		candidates := selectNodesForUpload(nodes, nil)
+		if len(candidates) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no available nodes"})
+			return
+		}
+		if storageClass == "REPLICATED" && len(candidates) < replicationFactor {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":              "not enough available nodes for replication factor",
+				"replication_factor": replicationFactor,
+				"available_nodes":    len(candidates),
+			})
+			return
+		}
+
+		// Upload ke file_key yang sudah ada menjadi versi baru dengan object
+		// sendiri, sehingga versi lama tidak tertimpa
+		fileKey := c.DefaultPostForm("file_key", c.Query("file_key"))
+		objectKey := fileKey
+		if fileKey == "" {
+			if fileKey, err = newFileKey(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal generate file key"})
+				return
+			}
+			objectKey = fileKey
+		} else {
+			if !fileKeyPattern.MatchString(fileKey) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "file_key hanya boleh huruf, angka, '-' dan '_' (maksimal 80 karakter)"})
+				return
+			}
+			// Versi di trash ikut dihitung supaya object-nya tidak tertimpa
+			versions, err := metadata.ListFileVersions(fileKey)
+			var existing *FileMetadata
+			if err == nil && len(versions) == 0 {
+				existing, err = getFileRecord(fileKey)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil versi file"})
+				return
+			}
+			if len(versions) > 0 || existing != nil {
+				if objectKey, err = newVersionObjectKey(fileKey); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal generate file key"})
+					return
+				}
+			}
+		}
+
+		// Erasure coding: setiap shard di node berbeda
+		if storageClass == "ERASURE" {
+			nodeIDs, err := storeErasureCodedFile(objectKey, file, candidates, erasure)
+			if errors.Is(err, errErasurePlacementFailed) {
+				log.Printf("⚠️ Erasure coded upload %s rolled back: %v\n", objectKey, err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error":            err.Error(),
+					"ec_data_shards":   erasure.DataShards,
+					"ec_parity_shards": erasure.ParityShards,
+				})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Row files, manifest shard dan versi disimpan dalam satu transaksi
+			if _, err := registerFile(FileRegistration{
+				FileKey:          fileKey,
+				ObjectKey:        objectKey,
+				OriginalFilename: file.Filename,
+				SizeBytes:        file.Size,
+				Checksum:         checksum,
+				NodeIDs:          nodeIDs,
+				Erasure:          &erasure,
+			}); err != nil {
+				log.Println("error register erasure coded file:", err)
+				// Tanpa metadata shard di node menjadi yatim, jadi ikut di-rollback
+				deleteErasureShards(objectKey, nodeIDs, nodes)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
+				return
+			}
+			version := 0
+			if latest, err := resolveFileVersion(fileKey, 0); err == nil && latest != nil {
+				version = latest.Version
+			}
+
+			log.Printf("🧩 Stored %s v%d as %d+%d erasure coded shards\n", fileKey, version, erasure.DataShards, erasure.ParityShards)
+
+			c.JSON(http.StatusOK, gin.H{
+				"success":           true,
+				"file_id":           fileKey,
+				"object_key":        objectKey,
+				"version":           version,
+				"original_filename": file.Filename,
+				"size_bytes":        file.Size,
+				"checksum_sha256":   checksum,
+				"storage_mode":      "ERASURE",
+				"ec_data_shards":    erasure.DataShards,
+				"ec_parity_shards":  erasure.ParityShards,
+				"shard_size_bytes":  erasureShardSize(file.Size, erasure),
+				"placement":         nodeIDs,
+				"routed_via":        "naming-service",
+			})
+			return
+		}
+
+		// File besar dipecah menjadi chunk yang ditempatkan terpisah per chunk
+		if shouldChunk(file.Size) {
+			chunks, err := storeChunkedFile(objectKey, file, candidates, replicationFactor)
+			if errors.Is(err, errChunkReplicationNotReached) {
+				log.Printf("⚠️ Chunked upload %s rolled back: %v\n", objectKey, err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error":              err.Error(),
+					"replication_factor": replicationFactor,
+				})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Row files, manifest chunk dan versi disimpan dalam satu transaksi
+			if _, err := registerFile(FileRegistration{
+				FileKey:           fileKey,
+				ObjectKey:         objectKey,
+				OriginalFilename:  file.Filename,
+				SizeBytes:         file.Size,
+				Checksum:          checksum,
+				ReplicationFactor: &replicationFactor,
+				Chunks:            chunks,
+				ChunkSizeBytes:    chunkSizeBytes,
+			}); err != nil {
+				log.Println("error register chunked file:", err)
+				// Tanpa metadata chunk di node menjadi yatim, jadi ikut di-rollback
+				deleteChunkObjects(objectKey, chunks, nodes)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
+				return
+			}
+			version := 0
+			if latest, err := resolveFileVersion(fileKey, 0); err == nil && latest != nil {
+				version = latest.Version
+			}
+
+			log.Printf("📦 Stored %s v%d as %d chunks (replication factor %d)\n", fileKey, version, len(chunks), replicationFactor)
+
+			c.JSON(http.StatusOK, gin.H{
+				"success":            true,
+				"file_id":            fileKey,
+				"object_key":         objectKey,
+				"version":            version,
+				"original_filename":  file.Filename,
+				"size_bytes":         file.Size,
+				"checksum_sha256":    checksum,
+				"storage_mode":       "CHUNKED",
+				"chunk_size_bytes":   chunkSizeBytes,
+				"chunk_count":        len(chunks),
+				"chunks":             chunks,
+				"replication_factor": replicationFactor,
+				"routed_via":         "naming-service",
+			})
+			return
+		}
+
+		// Upload ke primary. Jika gagal, kandidat berikutnya dijadikan primary.
+		var primary *Node
+		var uploadResp map[string]interface{}
+		next := 0
+		for ; next < len(candidates) && primary == nil; next++ {
+			node := &candidates[next]
+			log.Printf("📤 Routing upload to %s (latency: %dms)\n", node.ID, node.LatencyMs)
+
+			resp, err := uploadFileToNode(node.Address, objectKey, file, checksum)
+			if err != nil {
+				log.Printf("❌ Upload to %s failed: %v\n", node.ID, err)
+				continue
+			}
+			primary = node
+			uploadResp = resp
+		}
+
+		if primary == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal upload ke semua node"})
+			return
+		}
+
+		// Replikasi dari primary sampai jumlah salinan sesuai replication factor
+		stored := []string{primary.ID}
+		failed := []string{}
+		for ; next < len(candidates) && len(stored) < replicationFactor; next++ {
+			node := candidates[next]
+			if err := replicateFileToNode(objectKey, checksum, primary.Address, node.Address); err != nil {
+				log.Printf("❌ Replication of %s to %s failed: %v\n", objectKey, node.ID, err)
+				failed = append(failed, node.ID)
+				continue
+			}
+			stored = append(stored, node.ID)
+		}
+
+		nodeMap := make(map[string]string)
+		for _, n := range nodes {
+			nodeMap[n.ID] = n.Address
+		}
 
-		// Select best node that has the file
-		bestNode := selectBestNodeForDownload(fileKey, nodes)
-		if bestNode == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "file not found or no available nodes"})
+		if len(stored) < replicationFactor {
+			// Jangan laporkan file tersimpan jika salinannya kurang dari target
+			log.Printf("⚠️ Upload %s only reached %d/%d replicas, rolling back\n", objectKey, len(stored), replicationFactor)
+			for _, nodeID := range stored {
+				if _, err := deleteFileFromNode(nodeMap[nodeID], objectKey); err != nil {
+					log.Printf("⚠️ Rollback delete %s from %s failed: %v\n", objectKey, nodeID, err)
+				}
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":              "replication factor not reached",
+				"replication_factor": replicationFactor,
+				"stored":             stored,
+				"failed":             failed,
+			})
+			return
+		}
+
+		// Simpan metadata dan lokasi sesuai placement dalam satu transaksi
+		if _, err := registerFile(FileRegistration{
+			FileKey:           fileKey,
+			ObjectKey:         objectKey,
+			OriginalFilename:  file.Filename,
+			SizeBytes:         file.Size,
+			Checksum:          checksum,
+			ReplicationFactor: &replicationFactor,
+			NodeIDs:           stored,
+		}); err != nil {
+			log.Println("error register file:", err)
+			// Tanpa metadata salinan di node menjadi yatim, jadi ikut di-rollback
+			for _, nodeID := range stored {
+				if _, err := deleteFileFromNode(nodeMap[nodeID], objectKey); err != nil {
+					log.Printf("⚠️ Rollback delete %s from %s failed: %v\n", objectKey, nodeID, err)
+				}
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
 			return
 		}
 
-		log.Printf("📥 Routing download from %s (latency: %dms)\n", bestNode.ID, bestNode.LatencyMs)
+		// Add routing info to response
+		uploadResp["file_id"] = fileKey
+		uploadResp["object_key"] = objectKey
+		if latest, err := resolveFileVersion(fileKey, 0); err == nil && latest != nil {
+			uploadResp["version"] = latest.Version
+		}
+		uploadResp["is_replica"] = false
+		uploadResp["routed_via"] = "naming-service"
+		uploadResp["selected_node"] = primary.ID
+		uploadResp["node_latency_ms"] = primary.LatencyMs
+		uploadResp["replication_factor"] = replicationFactor
+		uploadResp["placement"] = stored
+		uploadResp["replication"] = gin.H{
+			"successful": stored[1:],
+			"failed":     failed,
+		}
 
-		// Forward request to selected node
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, err := client.Get(fmt.Sprintf("%s/files/%s", bestNode.Address, fileKey))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("gagal download dari node: %v", err)})
+		c.JSON(http.StatusOK, uploadResp)
+	})
+
+	// Multipart upload: initiate session, upload part, complete / abort
+	r.POST("/uploads", func(c *gin.Context) {
+		var req struct {
+			Filename          string `json:"filename" binding:"required"`
+			ReplicationFactor string `json:"replication_factor"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "request tidak valid", "details": err.Error()})
 			return
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != 200 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		replicationFactor, err := parseReplicationFactor(req.ReplicationFactor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Copy headers
-		for key, values := range resp.Header {
-			for _, value := range values {
-				c.Header(key, value)
-			}
+		session, err := createUploadSession(req.Filename, replicationFactor)
+		if err != nil {
+			log.Println("error create upload session:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal membuat upload session"})
+			return
 		}
 
-		// Add custom header to indicate routing
-		c.Header("X-Routed-From", bestNode.ID)
-		c.Header("X-Node-Latency-Ms", fmt.Sprintf("%d", bestNode.LatencyMs))
+		log.Printf("📝 Upload session %s started for %s\n", session.UploadID, req.Filename)
 
-		// Stream file to client
-		c.Status(resp.StatusCode)
-		io.Copy(c.Writer, resp.Body)
+		c.JSON(http.StatusCreated, gin.H{
+			"upload_id":           session.UploadID,
+			"file_key":            session.FileKey,
+			"replication_factor":  session.ReplicationFactor,
+			"expires_at":          session.ExpiresAt,
+			"max_part_size_bytes": maxPartSizeBytes,
+			"max_part_number":     maxPartNumber,
+		})
 	})
 
-	// Endpoint untuk delete file via naming service
-	r.DELETE("/files/:fileKey", func(c *gin.Context) {
-		fileKey := c.Param("fileKey")
+	r.GET("/uploads/:uploadId", func(c *gin.Context) {
+		session, err := getUploadSession(c.Param("uploadId"))
+		if errors.Is(err, errUploadSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil upload session"})
+			return
+		}
 
-		log.Printf("🗑️ Delete request for file: %s\n", fileKey)
+		c.JSON(http.StatusOK, session)
+	})
+
+	// Body request adalah isi part (raw bytes). Upload ulang part yang sama menimpa part lama.
+	r.PUT("/uploads/:uploadId/parts/:partNumber", func(c *gin.Context) {
+		uploadID := c.Param("uploadId")
+		partNumber, err := strconv.Atoi(c.Param("partNumber"))
+		if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("part number harus 1-%d", maxPartNumber)})
+			return
+		}
+
+		session, err := getUploadSession(uploadID)
+		if errors.Is(err, errUploadSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil upload session"})
+			return
+		}
+		if session.Status != "ACTIVE" {
+			c.JSON(http.StatusConflict, gin.H{"error": errUploadSessionClosed.Error(), "status": session.Status})
+			return
+		}
 
-		// Get all nodes that have the file
-		nodeIDs, err := getFileLocations(fileKey)
+		tmp, size, checksum, err := spoolPart(http.MaxBytesReader(c.Writer, c.Request.Body, maxPartSizeBytes))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "part terlalu besar", "max_part_size_bytes": maxPartSizeBytes})
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil file locations"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("gagal baca part: %v", err)})
 			return
 		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
 
-		// Jika tidak ada di file_locations, coba cari di semua nodes
-		if len(nodeIDs) == 0 {
-			// Coba delete dari semua nodes (fallback)
-			nodeIDs = []string{"node-1", "node-2", "node-3"}
-			log.Printf("⚠️ File %s not found in file_locations, trying all nodes\n", fileKey)
+		if size == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "part kosong"})
+			return
+		}
+
+		clientChecksum, err := parseClientDigest(c.Request.Header, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if clientChecksum != "" && clientChecksum != checksum {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "checksum part tidak cocok",
+				"expected_checksum": clientChecksum,
+				"received_checksum": checksum,
+			})
+			return
 		}
 
-		// Get all nodes info
 		nodes, err := getAllNodes()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
 			return
 		}
+		candidates := selectNodesForUpload(nodes, nil)
+		if len(candidates) < session.ReplicationFactor {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":              "not enough available nodes for replication factor",
+				"replication_factor": session.ReplicationFactor,
+				"available_nodes":    len(candidates),
+			})
+			return
+		}
+
+		// Part yang diupload ulang: salinan lamanya tidak boleh ikut di-rollback
+		var recorded []string
+		for _, p := range session.Parts {
+			if p.PartNumber == partNumber {
+				recorded = p.Locations
+			}
+		}
+
+		nodeIDs, err := storePart(session.FileKey, partNumber, tmp, size, checksum, candidates, session.ReplicationFactor, recorded)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
 
+		part := UploadPart{PartNumber: partNumber, SizeBytes: size, ChecksumSHA256: checksum, Locations: nodeIDs}
 		nodeMap := make(map[string]string)
 		for _, n := range nodes {
 			nodeMap[n.ID] = n.Address
 		}
 
-		// Delete from all nodes
-		client := &http.Client{Timeout: 10 * time.Second}
-		successCount := 0
-		failCount := 0
-		deletedNodes := []string{}
+		previous, err := recordPart(uploadID, part)
+		if err != nil {
+			// Session sudah ditutup saat part dikirim, part yang baru tersimpan dibuang
+			deletePartObjects(session.FileKey, partNumber, nodeIDs, nodeMap)
+			if errors.Is(err, errUploadSessionClosed) || errors.Is(err, errUploadSessionNotFound) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			log.Println("error insert upload part:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan part"})
+			return
+		}
 
-		for _, nodeID := range nodeIDs {
-			nodeAddr, ok := nodeMap[nodeID]
-			if !ok {
-				log.Printf("⚠️ Node %s not found in nodeMap\n", nodeID)
-				failCount++
-				continue
+		// Hapus salinan part lama di node yang tidak dipakai lagi
+		var stale []string
+		for _, nodeID := range previous {
+			if !slices.Contains(nodeIDs, nodeID) {
+				stale = append(stale, nodeID)
+			}
+		}
+		deletePartObjects(session.FileKey, partNumber, stale, nodeMap)
+
+		log.Printf("📤 Upload session %s: part %d (%d bytes) stored on %v\n", uploadID, partNumber, size, nodeIDs)
+
+		c.JSON(http.StatusOK, gin.H{
+			"upload_id":       uploadID,
+			"part_number":     partNumber,
+			"size_bytes":      size,
+			"checksum_sha256": checksum,
+			"placement":       nodeIDs,
+		})
+	})
+
+	// Complete: part 1..N disusun menjadi file CHUNKED. Body opsional
+	// {"parts":[{"part_number":1,"checksum_sha256":"..."}]} untuk verifikasi.
+	r.POST("/uploads/:uploadId/complete", func(c *gin.Context) {
+		uploadID := c.Param("uploadId")
+
+		var req struct {
+			Parts []UploadPart `json:"parts"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "request tidak valid", "details": err.Error()})
+				return
+			}
+		}
+
+		// ACTIVE -> COMPLETING supaya tidak ada part baru atau complete lain yang berjalan bersamaan
+		if err := setUploadSessionStatus(uploadID, "ACTIVE", "COMPLETING"); err != nil {
+			switch {
+			case errors.Is(err, errUploadSessionNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, errUploadSessionClosed):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal update upload session"})
+			}
+			return
+		}
+
+		reopen := func() {
+			if err := setUploadSessionStatus(uploadID, "COMPLETING", "ACTIVE"); err != nil {
+				log.Println("error reopen upload session:", err)
+			}
+		}
+
+		session, err := getUploadSession(uploadID)
+		if err != nil {
+			reopen()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil upload session"})
+			return
+		}
+
+		if err := validateCompleteParts(session.Parts, req.Parts); err != nil {
+			reopen()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		nodes, err := getAllNodes()
+		if err != nil {
+			reopen()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			return
+		}
+
+		chunks, size := partsToChunks(session.FileKey, session.Parts)
+		checksum, err := checksumAssembledFile(session.FileKey, chunks, nodes)
+		if err != nil {
+			reopen()
+			log.Printf("❌ Upload session %s: cannot read back parts: %v\n", uploadID, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("gagal membaca ulang part: %v", err)})
+			return
+		}
+
+		// Row files, manifest chunk dan versi disimpan dalam satu transaksi;
+		// jika gagal session dibuka lagi dan part tetap bisa dipakai retry
+		if _, err := registerFile(FileRegistration{
+			FileKey:           session.FileKey,
+			OriginalFilename:  session.OriginalFilename,
+			SizeBytes:         size,
+			Checksum:          checksum,
+			ReplicationFactor: &session.ReplicationFactor,
+			Chunks:            chunks,
+			ChunkSizeBytes:    chunks[0].SizeBytes,
+		}); err != nil {
+			reopen()
+			log.Println("error register multipart file:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal simpan metadata"})
+			return
+		}
+		if err := setUploadSessionStatus(uploadID, "COMPLETING", "COMPLETED"); err != nil {
+			log.Println("error update upload session:", err)
+		}
+
+		log.Printf("✅ Upload session %s completed: %s (%d parts, %d bytes)\n", uploadID, session.FileKey, len(chunks), size)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":            true,
+			"file_id":            session.FileKey,
+			"original_filename":  session.OriginalFilename,
+			"size_bytes":         size,
+			"checksum_sha256":    checksum,
+			"storage_mode":       "CHUNKED",
+			"chunk_count":        len(chunks),
+			"replication_factor": session.ReplicationFactor,
+			"routed_via":         "naming-service",
+		})
+	})
+
+	r.DELETE("/uploads/:uploadId", func(c *gin.Context) {
+		uploadID := c.Param("uploadId")
+
+		err := abortUploadSession(uploadID, "ACTIVE")
+		if errors.Is(err, errUploadSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errUploadSessionClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("error abort upload session:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal abort upload session"})
+			return
+		}
+
+		log.Printf("🗑️ Upload session %s aborted\n", uploadID)
+		c.JSON(http.StatusOK, gin.H{"success": true, "upload_id": uploadID, "status": "ABORTED"})
+	})
+
+	// Endpoint untuk download file via naming service
+	r.GET("/download/:fileKey", func(c *gin.Context) {
+		fileKey := c.Param("fileKey")
+
+		// Get all nodes
+		nodes, err := getAllNodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			return
+		}
+
+		// ?version=N membaca versi lama, default versi terbaru
+		version, err := parseFileVersion(c.Query("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fileVersion, err := resolveFileVersion(fileKey, version)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil metadata file"})
+			return
+		}
+		var record *FileMetadata
+		if fileVersion != nil {
+			if record, err = getFileRecord(fileVersion.ObjectKey); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil metadata file"})
+				return
 			}
+		}
+
+		if record == nil && version > 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+		c.Header("X-File-Version", strconv.Itoa(fileVersion.Version))
+
+		// Sumber baca sesuai storage_mode: replica utuh, chunk, atau shard erasure
+		source, err := newFileSource(record, nodes, c.Writer.Header())
+		if errors.Is(err, errNoSourceAvailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "file not found or no available nodes"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil lokasi file"})
+			return
+		}
+
+		log.Printf("📥 Download %s v%d (%s, range: %q)\n", fileKey, fileVersion.Version, record.StorageMode, c.GetHeader("Range"))
+
+		contentType := mime.TypeByExtension(filepath.Ext(record.OriginalFilename))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": record.OriginalFilename}))
+		if record.ChecksumSHA256 != "" {
+			c.Header("ETag", fmt.Sprintf("%q", record.ChecksumSHA256))
+			c.Header("X-Checksum-SHA256", record.ChecksumSHA256)
+			c.Header("Digest", digestHeader(record.ChecksumSHA256))
+		}
+
+		// ServeContent menangani Range (single & multipart/byteranges), If-Range,
+		// If-None-Match, If-Modified-Since dan HEAD berdasarkan ETag di atas
+		content := &remoteReadSeeker{source: source, size: record.SizeBytes, checksum: record.ChecksumSHA256}
+		defer content.Close()
+		http.ServeContent(c.Writer, c.Request, record.OriginalFilename, parseUploadedAt(record.UploadedAt), content)
+	})
+
+	// Endpoint untuk delete file via naming service
+	r.DELETE("/files/:fileKey", func(c *gin.Context) {
+		fileKey := c.Param("fileKey")
+
+		log.Printf("🗑️ Delete request for file: %s\n", fileKey)
+
+		version, err := parseFileVersion(c.Query("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Get all nodes info
+		nodes, err := getAllNodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil nodes"})
+			return
+		}
 
-			req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/files/%s", nodeAddr, fileKey), nil)
+		// Tanpa ?version semua versi dihapus; key tanpa versi dihapus sebagai object
+		var objectKeys []string
+		if version > 0 {
+			fileVersion, err := resolveFileVersion(fileKey, version)
 			if err != nil {
-				log.Printf("❌ Failed to create delete request for %s: %v\n", nodeID, err)
-				failCount++
-				continue
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil versi file"})
+				return
+			}
+			if fileVersion == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+				return
 			}
+			objectKeys = []string{fileVersion.ObjectKey}
+		} else if objectKeys, err = fileObjectKeys(fileKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil versi file"})
+			return
+		}
 
-			resp, err := client.Do(req)
+		// Soft delete: seluruh file pindah ke trash, blob dihapus oleh purge worker.
+		// ?version=N dan ?permanent=true langsung menghapus permanen.
+		if version == 0 && trashRetention > 0 && c.Query("permanent") != "true" {
+			trashed, known, err := trashObjects(objectKeys)
 			if err != nil {
-				log.Printf("❌ Failed to delete from %s: %v\n", nodeID, err)
-				failCount++
-				continue
+				log.Printf("❌ Failed to trash %s: %v\n", fileKey, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal memindahkan file ke trash"})
+				return
 			}
-			resp.Body.Close()
+			// Key yang tidak dikenal metadata tetap dibersihkan dari node di bawah
+			if known > 0 {
+				if trashed == 0 {
+					c.JSON(http.StatusNotFound, gin.H{"error": "file sudah ada di trash, pakai ?permanent=true untuk hapus permanen"})
+					return
+				}
 
-			if resp.StatusCode == 200 {
-				successCount++
-				deletedNodes = append(deletedNodes, nodeID)
-				log.Printf("✅ Deleted from %s\n", nodeID)
-
-				// Update file_locations
-				db.Exec(`
-					UPDATE file_locations 
-					SET status = 'DELETED' 
-					WHERE file_key = ? AND node_id = ?
-				`, fileKey, nodeID)
-			} else if resp.StatusCode == 404 {
-				// File not found on this node, not an error
-				log.Printf("ℹ️ File not found on %s (already deleted or never existed)\n", nodeID)
-			} else {
-				log.Printf("❌ Delete from %s returned status %d\n", nodeID, resp.StatusCode)
-				failCount++
+				log.Printf("🗑️ Moved %s to trash (%d versions)\n", fileKey, trashed)
+				c.JSON(http.StatusOK, gin.H{
+					"success":          true,
+					"file_key":         fileKey,
+					"trashed":          true,
+					"versions_trashed": trashed,
+					"purge_at":         time.Now().Add(trashRetention),
+					"message":          "File moved to trash",
+				})
+				return
 			}
 		}
 
-		// Delete from replication_queue
-		result, err := db.Exec(`DELETE FROM replication_queue WHERE file_key = ?`, fileKey)
-		if err != nil {
-			log.Printf("⚠️ Failed to delete from replication_queue: %v\n", err)
-		} else {
-			rowsAffected, _ := result.RowsAffected()
-			if rowsAffected > 0 {
-				log.Printf("✅ Deleted %d entries from replication_queue\n", rowsAffected)
+		// Blob dihapus dari semua node, lalu metadata per object (replication_queue,
+		// file_locations, chunk manifest dan files) dalam satu transaksi; jika gagal
+		// client bisa mengulang DELETE
+		var result objectDeleteResult
+		for _, objectKey := range objectKeys {
+			objectResult, err := deleteStoredObject(objectKey, nodes)
+			result.add(objectResult)
+			if err != nil {
+				log.Printf("❌ Failed to delete %s: %v\n", objectKey, err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":         "gagal hapus metadata file",
+					"deleted_from":  result.Deleted,
+					"failed":        result.Failed,
+					"deleted_nodes": result.DeletedNodes,
+				})
+				return
 			}
 		}
 
-		// Delete from file_locations
-		db.Exec(`DELETE FROM file_locations WHERE file_key = ?`, fileKey)
+		log.Printf("🗑️ Delete completed for %s: %d success, %d failed, %d deferred\n",
+			fileKey, result.Deleted, result.Failed, result.Deferred)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":          true,
+			"file_key":         fileKey,
+			"versions_deleted": len(objectKeys),
+			"deleted_from":     result.Deleted,
+			"failed":           result.Failed,
+			"deferred":         result.Deferred,
+			"total_nodes":      result.TotalNodes,
+			"deleted_nodes":    result.DeletedNodes,
+			"message":          "File deleted from all nodes and database",
+		})
+	})
 
-		// Delete from files table
-		db.Exec(`DELETE FROM files WHERE file_key = ?`, fileKey)
+	// Mengembalikan file dari trash selama masa retensi belum lewat
+	r.POST("/files/:fileKey/restore", func(c *gin.Context) {
+		fileKey := c.Param("fileKey")
 
-		log.Printf("🗑️ Delete completed for %s: %d success, %d failed\n", fileKey, successCount, failCount)
+		restored, err := restoreTrashedFile(fileKey)
+		if err != nil {
+			log.Printf("❌ Failed to restore %s: %v\n", fileKey, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal restore file"})
+			return
+		}
+		if restored == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file tidak ada di trash atau masa retensi sudah lewat"})
+			return
+		}
 
+		log.Printf("♻️ Restored %s from trash (%d versions)\n", fileKey, restored)
 		c.JSON(http.StatusOK, gin.H{
-			"success":       true,
-			"file_key":      fileKey,
-			"deleted_from":  successCount,
-			"failed":        failCount,
-			"total_nodes":   len(nodeIDs),
-			"deleted_nodes": deletedNodes,
-			"message":       "File deleted from all nodes and database",
+			"success":           true,
+			"file_key":          fileKey,
+			"versions_restored": restored,
+		})
+	})
+
+	// Isi trash, terbaru di depan
+	r.GET("/trash", func(c *gin.Context) {
+		trashed, err := metadata.ListTrash(100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil isi trash"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"files":           trashed,
+			"count":           len(trashed),
+			"retention_hours": int(trashRetention.Hours()),
+		})
+	})
+
+	// Riwayat versi sebuah file_key, terbaru di depan
+	r.GET("/files/:fileKey/versions", func(c *gin.Context) {
+		fileKey := c.Param("fileKey")
+
+		versions, err := metadata.ListFileVersions(fileKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal ambil versi file"})
+			return
+		}
+		if len(versions) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"file_key":  fileKey,
+			"versions":  versions,
+			"count":     len(versions),
+			"retention": fileVersionRetention,
 		})
 	})
 
 	// Endpoint untuk list files
 	r.GET("/files", func(c *gin.Context) {
-		rows, err := db.Query(`
-			SELECT f.file_key, f.original_filename, f.size_bytes, f.checksum_sha256, f.uploaded_at
-			FROM files f
-			ORDER BY f.uploaded_at DESC
-			LIMIT 100
-		`)
+		files, err := metadata.ListFiles(100)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal query files"})
 			return
 		}
-		defer rows.Close()
 
-		var files []FileMetadata
-		for rows.Next() {
-			var f FileMetadata
-			if err := rows.Scan(&f.FileKey, &f.OriginalFilename, &f.SizeBytes, &f.ChecksumSHA256, &f.UploadedAt); err != nil {
-				continue
+		// Ambil replicas (untuk file chunked: node yang menyimpan minimal satu chunk)
+		for i := range files {
+			if files[i].StorageMode == "CHUNKED" {
+				files[i].Replicas, _ = getChunkedFileNodes(files[i].ObjectKey)
+			} else {
+				files[i].Replicas, _ = getFileLocations(files[i].ObjectKey)
 			}
-
-			// Ambil replicas
-			replicas, _ := getFileLocations(f.FileKey)
-			f.Replicas = replicas
-
-			files = append(files, f)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -884,12 +1952,18 @@ func main() {
 		})
 	})
 
-	// Background job untuk auto-recovery dan latency measurement (cek setiap 30 detik)
+	// Background job untuk auto-recovery dan latency measurement (cek setiap 30 detik).
+	// Node yang mengirim heartbeat sendiri (lease masih berlaku) hanya diukur latency-nya,
+	// status UP/DOWN mereka ditentukan oleh failure detector berbasis lease.
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
 
 		for range ticker.C {
+			if !isLeader() {
+				continue
+			}
+
 			nodes, err := getAllNodes()
 			if err != nil {
 				continue
@@ -898,15 +1972,27 @@ func main() {
 			client := &http.Client{Timeout: 2 * time.Second}
 
 			for _, node := range nodes {
+				if node.Status == "RETIRED" {
+					continue
+				}
+
 				// Measure latency
 				latency := measureNodeLatency(node.Address)
 				updateNodeLatency(node.ID, latency)
 
+				if hasActiveLease(node, time.Now()) {
+					continue
+				}
+
 				// Cek health
 				resp, err := client.Get(node.Address + "/health")
 				newStatus := "DOWN"
-				if err == nil && resp.StatusCode == http.StatusOK {
-					newStatus = "UP"
+				// Body ditutup untuk status apa pun supaya koneksi bisa dipakai ulang;
+				// defer di loop ticker tidak akan pernah jalan
+				if err == nil {
+					if resp.StatusCode == http.StatusOK {
+						newStatus = "UP"
+					}
 					resp.Body.Close()
 				}
 
@@ -917,48 +2003,36 @@ func main() {
 
 					// Jika node baru UP, trigger recovery
 					if newStatus == "UP" {
-						log.Printf("🔄 Triggering recovery for node %s\n", node.ID)
-
-						// Panggil recovery endpoint secara internal
-						items, err := getPendingReplications(node.ID)
-						if err != nil || len(items) == 0 {
-							continue
-						}
-
-						nodeMap := make(map[string]string)
-						for _, n := range nodes {
-							nodeMap[n.ID] = n.Address
-						}
-
-						for _, item := range items {
-							sourceAddr, sourceOk := nodeMap[item.SourceNodeID]
-							targetAddr := node.Address
-
-							if !sourceOk {
-								continue
-							}
-
-							if err := replicateFileToNode(item.FileKey, sourceAddr, targetAddr); err != nil {
-								markReplicationFailed(item.ID, err.Error())
-							} else {
-								markReplicationCompleted(item.ID)
-								db.Exec(`
-									INSERT INTO file_locations (file_key, node_id, status)
-									VALUES (?, ?, 'ACTIVE')
-									ON DUPLICATE KEY UPDATE status = 'ACTIVE'
-								`, item.FileKey, item.TargetNodeID)
-								log.Printf("✅ Auto-recovered %s to %s\n", item.FileKey, node.ID)
-							}
-						}
+						autoRecoverNode(node, nodes)
 					}
 				}
 			}
 		}
 	}()
 
-	log.Println("🚀 Naming service berjalan di :8080")
+	go runLeaseFailureDetector()
+	go runReplicationWorkers()
+	go runRoleMonitor()
+	go runIdempotencyKeyGC()
+	go runTrashPurger()
+	go runTombstoneReplayer()
+	go runOrphanCollector()
+	go runUploadSessionGC()
+	go runScrubber()
+	go runReplicationPlanner()
+	go runDrainMonitor()
+	if store, ok := metadata.(leaderLeaseStore); ok {
+		go runLeaderElection(store)
+	} else {
+		// File bolt hanya bisa dibuka satu proses, jadi instance ini selalu leader
+		becomeStandaloneLeader()
+	}
+
+	// Port bisa diganti supaya beberapa instance bisa jalan di satu mesin
+	port := getEnv("PORT", "8080")
+	log.Printf("🚀 Naming service %s berjalan di :%s\n", instanceID, port)
 	log.Println("📊 Auto-recovery background job started")
-	if err := r.Run(":8080"); err != nil {
+	if err := r.Run(":" + port); err != nil {
 		log.Fatalf("gagal menjalankan server: %v", err)
 	}
 }
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrasi skema berversi. Setiap perubahan skema ditulis sebagai pasangan
+// migrations/NNNN_nama.up.sql dan NNNN_nama.down.sql, di-embed ke binary dan
+// dicatat di tabel schema_migrations. schema.sql / init.sql hanya dipakai
+// MySQL saat volume pertama kali dibuat, jadi perubahan baru cukup ditambahkan
+// sebagai migrasi di sini.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Nama lock MySQL supaya beberapa instance tidak menjalankan migrasi bersamaan
+const migrationLockName = "naming-service-migrate"
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type migrationStatus struct {
+	migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+// loadMigrations membaca migrasi yang di-embed, urut berdasarkan versi
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("nama file migrasi tidak valid: %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := migrationFiles.ReadFile("migrations/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("versi migrasi %d dipakai dua nama: %s dan %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	var migrations []migration
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migrasi %04d_%s harus punya file up dan down", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements memecah script per statement. Baris komentar dibuang dan
+// statement diakhiri ';' di akhir baris, cukup untuk DDL di folder migrations.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// withMigrationLock menjalankan fn di satu koneksi yang memegang GET_LOCK
+func (s *mysqlStore) withMigrationLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrationLockName).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return fmt.Errorf("gagal ambil lock migrasi %s", migrationLockName)
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func migrationStatuses(conn *sql.Conn) ([]migrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, dirty, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type appliedRow struct {
+		dirty     bool
+		appliedAt sql.NullTime
+	}
+	applied := make(map[int]appliedRow)
+	for rows.Next() {
+		var version int
+		var row appliedRow
+		if err := rows.Scan(&version, &row.dirty, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]migrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		st := migrationStatus{migration: mig}
+		if row, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			st.Dirty = row.dirty
+			if row.appliedAt.Valid {
+				st.AppliedAt = &row.appliedAt.Time
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// checkNotDirty menolak lanjut jika ada migrasi yang berhenti di tengah jalan.
+// DDL MySQL tidak transaksional, jadi skema harus diperbaiki manual dulu.
+func checkNotDirty(statuses []migrationStatus) error {
+	for _, st := range statuses {
+		if st.Dirty {
+			return fmt.Errorf("migrasi %04d_%s dirty (gagal di tengah jalan); perbaiki skema lalu hapus barisnya dari schema_migrations",
+				st.Version, st.Name)
+		}
+	}
+	return nil
+}
+
+func runMigrationScript(conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// migrateUp menjalankan semua migrasi yang belum diterapkan
+func (s *mysqlStore) migrateUp() (int, error) {
+	applied := 0
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		statuses, err := migrationStatuses(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkNotDirty(statuses); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		for _, st := range statuses {
+			if st.Applied {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, TRUE)",
+				st.Version, st.Name); err != nil {
+				return err
+			}
+			if err := runMigrationScript(conn, st.Up); err != nil {
+				return fmt.Errorf("migrasi %04d_%s gagal: %w", st.Version, st.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				"UPDATE schema_migrations SET dirty = FALSE, applied_at = NOW() WHERE version = ?",
+				st.Version); err != nil {
+				return err
+			}
+			log.Printf("⬆️ Applied migration %04d_%s\n", st.Version, st.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// migrateDown membatalkan sejumlah migrasi terakhir yang sudah diterapkan
+func (s *mysqlStore) migrateDown(steps int) (int, error) {
+	reverted := 0
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		statuses, err := migrationStatuses(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkNotDirty(statuses); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		for i := len(statuses) - 1; i >= 0 && reverted < steps; i-- {
+			st := statuses[i]
+			if !st.Applied {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx,
+				"UPDATE schema_migrations SET dirty = TRUE WHERE version = ?", st.Version); err != nil {
+				return err
+			}
+			if err := runMigrationScript(conn, st.Down); err != nil {
+				return fmt.Errorf("rollback %04d_%s gagal: %w", st.Version, st.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				"DELETE FROM schema_migrations WHERE version = ?", st.Version); err != nil {
+				return err
+			}
+			log.Printf("⬇️ Reverted migration %04d_%s\n", st.Version, st.Name)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// runMigrateCommand menangani `naming-service migrate status|up|down [steps]`
+func runMigrateCommand(args []string) {
+	if strings.ToLower(getEnv("METADATA_STORE", "mysql")) != "mysql" {
+		log.Fatal("migrate hanya untuk METADATA_STORE=mysql")
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: naming-service migrate status|up|down [steps]")
+		os.Exit(2)
+	}
+
+	store := openMySQLStore()
+	defer store.Close()
+
+	switch args[0] {
+	case "status":
+		err := store.withMigrationLock(func(conn *sql.Conn) error {
+			statuses, err := migrationStatuses(conn)
+			if err != nil {
+				return err
+			}
+			for _, st := range statuses {
+				state, appliedAt := "pending", ""
+				if st.Dirty {
+					state = "dirty"
+				} else if st.Applied {
+					state = "applied"
+				}
+				if st.AppliedAt != nil {
+					appliedAt = st.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Printf("%04d  %-30s  %-8s  %s\n", st.Version, st.Name, state, appliedAt)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("gagal ambil status migrasi: %v", err)
+		}
+	case "up":
+		applied, err := store.migrateUp()
+		if err != nil {
+			log.Fatalf("gagal menjalankan migrasi: %v", err)
+		}
+		log.Printf("✅ %d migration(s) applied\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("steps tidak valid: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := store.migrateDown(steps)
+		if err != nil {
+			log.Fatalf("gagal rollback migrasi: %v", err)
+		}
+		log.Printf("✅ %d migration(s) reverted\n", reverted)
+	default:
+		fmt.Fprintf(os.Stderr, "subcommand migrate tidak dikenal: %s\n", args[0])
+		os.Exit(2)
+	}
+}
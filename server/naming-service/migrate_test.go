@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "empty", script: "", want: nil},
+		{name: "only comments", script: "-- komentar\n\n  -- lagi\n", want: nil},
+		{
+			name:   "single statement",
+			script: "ALTER TABLE files ADD COLUMN x INT;\n",
+			want:   []string{"ALTER TABLE files ADD COLUMN x INT"},
+		},
+		{
+			name:   "multi line statements with comments",
+			script: "-- header\nCREATE TABLE t (\n  id INT\n);\n\n-- kedua\nDROP TABLE u;\n",
+			want:   []string{"CREATE TABLE t (\n  id INT\n)", "DROP TABLE u"},
+		},
+		{
+			name:   "trailing statement without semicolon",
+			script: "DROP TABLE a;\nDROP TABLE b\n",
+			want:   []string{"DROP TABLE a", "DROP TABLE b"},
+		},
+		{
+			name:   "crlf line endings",
+			script: "DROP TABLE a;\r\nDROP TABLE b;\r\n",
+			want:   []string{"DROP TABLE a", "DROP TABLE b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Migrasi yang di-embed harus bisa dimuat dan versinya berurutan tanpa lubang,
+// supaya migrateDown selalu tahu versi sebelumnya
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("tidak ada migrasi yang di-embed")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migrasi ke-%d punya versi %d", i, m.Version)
+		}
+		if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+			t.Errorf("migrasi %04d_%s punya script kosong", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+-- Menghapus seluruh skema baseline, urutan mengikuti foreign key
+DROP TABLE IF EXISTS replication_queue;
+DROP TABLE IF EXISTS file_locations;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS nodes;
@@ -0,0 +1,3 @@
+-- Gagal jika masih ada node RETIRED; hapus atau ubah statusnya dulu
+ALTER TABLE nodes
+    MODIFY COLUMN status ENUM('UP', 'DOWN') DEFAULT 'DOWN';
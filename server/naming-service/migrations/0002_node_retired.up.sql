@@ -0,0 +1,3 @@
+-- Node yang di-retire lewat DELETE /nodes/:id tetap disimpan dengan status RETIRED
+ALTER TABLE nodes
+    MODIFY COLUMN status ENUM('UP', 'DOWN', 'RETIRED') DEFAULT 'DOWN';
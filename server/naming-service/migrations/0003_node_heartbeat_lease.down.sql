@@ -0,0 +1,5 @@
+ALTER TABLE nodes
+    DROP COLUMN inflight_requests,
+    DROP COLUMN free_bytes,
+    DROP COLUMN capacity_bytes,
+    DROP COLUMN lease_expires_at;
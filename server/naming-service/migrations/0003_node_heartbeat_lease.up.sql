@@ -0,0 +1,6 @@
+-- Diisi oleh heartbeat push dari storage node
+ALTER TABLE nodes
+    ADD COLUMN lease_expires_at TIMESTAMP NULL AFTER last_heartbeat,
+    ADD COLUMN capacity_bytes BIGINT NULL AFTER lease_expires_at,
+    ADD COLUMN free_bytes BIGINT NULL AFTER capacity_bytes,
+    ADD COLUMN inflight_requests INT NULL AFTER free_bytes;
@@ -0,0 +1,3 @@
+-- Target jumlah salinan ACTIVE; NULL = REPLICATION_FACTOR global
+ALTER TABLE files
+    ADD COLUMN replication_factor INT NULL AFTER checksum_sha256;
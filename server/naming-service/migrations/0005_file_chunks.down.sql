@@ -0,0 +1,10 @@
+-- Gagal jika masih ada file CHUNKED; hapus file tersebut dulu
+ALTER TABLE files
+    MODIFY COLUMN storage_mode ENUM('REPLICATED') NOT NULL DEFAULT 'REPLICATED';
+ALTER TABLE replication_queue
+    DROP COLUMN chunk_index;
+DROP TABLE IF EXISTS chunk_locations;
+DROP TABLE IF EXISTS file_chunks;
+ALTER TABLE files
+    DROP COLUMN chunk_size_bytes,
+    DROP COLUMN storage_mode;
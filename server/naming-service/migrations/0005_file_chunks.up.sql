@@ -0,0 +1,32 @@
+-- REPLICATED: satu blob utuh per node, CHUNKED: lihat file_chunks
+ALTER TABLE files
+    ADD COLUMN storage_mode ENUM('REPLICATED', 'CHUNKED') NOT NULL DEFAULT 'REPLICATED' AFTER replication_factor,
+    ADD COLUMN chunk_size_bytes BIGINT NULL AFTER storage_mode;
+
+-- Tabel file_chunks: manifest chunk untuk file dengan storage_mode CHUNKED
+CREATE TABLE IF NOT EXISTS file_chunks (
+    file_key VARCHAR(100) NOT NULL,
+    chunk_index INT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum_sha256 VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_key, chunk_index),
+    FOREIGN KEY (file_key) REFERENCES files(file_key) ON DELETE CASCADE
+);
+
+-- Tabel chunk_locations: lokasi setiap chunk pada node
+CREATE TABLE IF NOT EXISTS chunk_locations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    file_key VARCHAR(100) NOT NULL,
+    chunk_index INT NOT NULL,
+    node_id VARCHAR(50) NOT NULL,
+    status ENUM('ACTIVE', 'DELETED') DEFAULT 'ACTIVE',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_key, chunk_index) REFERENCES file_chunks(file_key, chunk_index) ON DELETE CASCADE,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_chunk_node (file_key, chunk_index, node_id)
+);
+
+-- NULL = replikasi seluruh file, terisi = replikasi satu chunk saja
+ALTER TABLE replication_queue
+    ADD COLUMN chunk_index INT NULL AFTER source_node_id;
@@ -0,0 +1,9 @@
+-- Gagal jika masih ada file ERASURE; hapus file tersebut dulu
+ALTER TABLE files
+    MODIFY COLUMN storage_mode ENUM('REPLICATED', 'CHUNKED') NOT NULL DEFAULT 'REPLICATED';
+ALTER TABLE file_locations
+    DROP COLUMN shard_index;
+ALTER TABLE files
+    DROP COLUMN ec_block_size,
+    DROP COLUMN ec_parity_shards,
+    DROP COLUMN ec_data_shards;
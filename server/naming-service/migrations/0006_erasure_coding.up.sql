@@ -0,0 +1,10 @@
+-- ERASURE: k data + m parity shard, satu shard per node di file_locations
+ALTER TABLE files
+    MODIFY COLUMN storage_mode ENUM('REPLICATED', 'CHUNKED', 'ERASURE') NOT NULL DEFAULT 'REPLICATED',
+    ADD COLUMN ec_data_shards INT NULL AFTER chunk_size_bytes,
+    ADD COLUMN ec_parity_shards INT NULL AFTER ec_data_shards,
+    ADD COLUMN ec_block_size BIGINT NULL AFTER ec_parity_shards;
+
+-- Index shard untuk file ERASURE, NULL untuk salinan utuh
+ALTER TABLE file_locations
+    ADD COLUMN shard_index INT NULL AFTER status;
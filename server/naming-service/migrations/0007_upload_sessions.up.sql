@@ -0,0 +1,25 @@
+-- Tabel upload_sessions: multipart upload yang bisa dilanjutkan
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    upload_id VARCHAR(100) PRIMARY KEY,
+    -- file_key tujuan, dibuat di awal supaya part bisa langsung disimpan sebagai chunk
+    file_key VARCHAR(100) NOT NULL UNIQUE,
+    original_filename VARCHAR(255) NOT NULL,
+    replication_factor INT NOT NULL,
+    status ENUM('ACTIVE', 'COMPLETING', 'COMPLETED', 'ABORTED') DEFAULT 'ACTIVE',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX idx_status_expires (status, expires_at)
+);
+
+-- Tabel upload_parts: part yang sudah diterima untuk sebuah upload session
+CREATE TABLE IF NOT EXISTS upload_parts (
+    upload_id VARCHAR(100) NOT NULL,
+    part_number INT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    checksum_sha256 VARCHAR(64) NOT NULL,
+    -- Daftar node yang menyimpan part, dipisah koma
+    node_ids VARCHAR(255) NOT NULL,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (upload_id, part_number),
+    FOREIGN KEY (upload_id) REFERENCES upload_sessions(upload_id) ON DELETE CASCADE
+);
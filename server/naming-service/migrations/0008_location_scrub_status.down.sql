@@ -0,0 +1,5 @@
+-- Gagal jika masih ada lokasi CORRUPT / MISSING; perbaiki atau hapus dulu
+ALTER TABLE chunk_locations
+    MODIFY COLUMN status ENUM('ACTIVE', 'DELETED') DEFAULT 'ACTIVE';
+ALTER TABLE file_locations
+    MODIFY COLUMN status ENUM('ACTIVE', 'DELETED') DEFAULT 'ACTIVE';
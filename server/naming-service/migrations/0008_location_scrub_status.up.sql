@@ -0,0 +1,5 @@
+-- CORRUPT / MISSING ditandai oleh scrubber sampai salinan diperbaiki
+ALTER TABLE file_locations
+    MODIFY COLUMN status ENUM('ACTIVE', 'DELETED', 'CORRUPT', 'MISSING') DEFAULT 'ACTIVE';
+ALTER TABLE chunk_locations
+    MODIFY COLUMN status ENUM('ACTIVE', 'DELETED', 'CORRUPT', 'MISSING') DEFAULT 'ACTIVE';
@@ -0,0 +1,8 @@
+-- Gagal jika masih ada item DEAD; retry atau hapus dulu
+ALTER TABLE replication_queue
+    MODIFY COLUMN status ENUM('PENDING', 'IN_PROGRESS', 'COMPLETED', 'FAILED') DEFAULT 'PENDING';
+ALTER TABLE replication_queue
+    DROP INDEX idx_status_next_attempt,
+    DROP COLUMN lease_expires_at,
+    DROP COLUMN lease_owner,
+    DROP COLUMN next_attempt_at;
@@ -0,0 +1,8 @@
+-- FAILED dicoba lagi setelah next_attempt_at, DEAD = retry habis (dead-letter).
+-- lease_owner / lease_expires_at: worker yang sedang memproses item IN_PROGRESS.
+ALTER TABLE replication_queue
+    MODIFY COLUMN status ENUM('PENDING', 'IN_PROGRESS', 'COMPLETED', 'FAILED', 'DEAD') DEFAULT 'PENDING',
+    ADD COLUMN next_attempt_at TIMESTAMP NULL AFTER last_attempt,
+    ADD COLUMN lease_owner VARCHAR(100) NULL AFTER next_attempt_at,
+    ADD COLUMN lease_expires_at TIMESTAMP NULL AFTER lease_owner,
+    ADD INDEX idx_status_next_attempt (status, next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE nodes
+    DROP COLUMN down_since;
@@ -0,0 +1,3 @@
+-- Sejak kapan node DOWN, dipakai planner re-replikasi
+ALTER TABLE nodes
+    ADD COLUMN down_since TIMESTAMP NULL AFTER inflight_requests;
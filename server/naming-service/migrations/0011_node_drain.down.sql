@@ -0,0 +1,6 @@
+ALTER TABLE replication_queue
+    DROP COLUMN shard_index;
+ALTER TABLE nodes
+    DROP COLUMN drain_total_objects,
+    DROP COLUMN drain_started_at,
+    DROP COLUMN drain_state;
@@ -0,0 +1,10 @@
+-- DRAINING: tidak menerima upload baru dan datanya sedang dipindah,
+-- DECOMMISSIONED: drain selesai dan node sudah RETIRED
+ALTER TABLE nodes
+    ADD COLUMN drain_state ENUM('NONE', 'DRAINING', 'DECOMMISSIONED') NOT NULL DEFAULT 'NONE' AFTER down_since,
+    ADD COLUMN drain_started_at TIMESTAMP NULL AFTER drain_state,
+    ADD COLUMN drain_total_objects INT NULL AFTER drain_started_at;
+
+-- Terisi = replikasi satu shard erasure (object <file_key>_shard_NN)
+ALTER TABLE replication_queue
+    ADD COLUMN shard_index INT NULL AFTER chunk_index;
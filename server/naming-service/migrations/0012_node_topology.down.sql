@@ -0,0 +1,3 @@
+ALTER TABLE nodes
+    DROP COLUMN rack,
+    DROP COLUMN zone;
@@ -0,0 +1,4 @@
+-- Label topologi untuk failure domain; kosong = node dianggap domain sendiri
+ALTER TABLE nodes
+    ADD COLUMN zone VARCHAR(50) NULL AFTER role,
+    ADD COLUMN rack VARCHAR(50) NULL AFTER zone;
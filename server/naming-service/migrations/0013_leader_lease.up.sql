@@ -0,0 +1,8 @@
+-- Tabel leader_lease: lease leader antar instance naming service, hanya
+-- pemegang lease yang menjalankan job background
+CREATE TABLE IF NOT EXISTS leader_lease (
+    name VARCHAR(50) PRIMARY KEY,
+    holder VARCHAR(100) NOT NULL,
+    acquired_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Tabel idempotency_keys: Idempotency-Key dari POST /files/register yang sudah
+-- diproses, supaya retry dari storage node tidak mendaftarkan ulang
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    idempotency_key VARCHAR(100) PRIMARY KEY,
+    file_key VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_created (created_at)
+);
@@ -0,0 +1,12 @@
+-- Tabel file_versions: riwayat versi per file_key. Setiap versi adalah object
+-- sendiri (row files + file_locations dengan key object_key). File tanpa row
+-- di sini dianggap versi 1 dengan object_key = file_key.
+CREATE TABLE IF NOT EXISTS file_versions (
+    file_key VARCHAR(100) NOT NULL,
+    version INT NOT NULL,
+    object_key VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_key, version),
+    UNIQUE KEY unique_object (object_key),
+    FOREIGN KEY (object_key) REFERENCES files(file_key) ON DELETE CASCADE
+);
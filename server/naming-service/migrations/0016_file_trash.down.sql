@@ -0,0 +1,3 @@
+ALTER TABLE files
+    DROP INDEX idx_trashed,
+    DROP COLUMN trashed_at;
@@ -0,0 +1,5 @@
+-- Soft delete: object yang di-trash disembunyikan dari GET /files dan dihapus
+-- permanen oleh purge worker setelah TRASH_RETENTION_HOURS
+ALTER TABLE files
+    ADD COLUMN trashed_at TIMESTAMP NULL,
+    ADD INDEX idx_trashed (trashed_at);
@@ -0,0 +1,15 @@
+-- Tabel delete_tombstones: blob yang masih harus dihapus dari node yang DOWN
+-- atau gagal saat delete, diulang oleh tombstone replayer saat node UP
+CREATE TABLE IF NOT EXISTS delete_tombstones (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    node_id VARCHAR(50) NOT NULL,
+    -- Object di metadata; blob_key bisa berupa object chunk / shard-nya
+    file_key VARCHAR(100) NOT NULL,
+    blob_key VARCHAR(150) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_attempt TIMESTAMP NULL,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_node_blob (node_id, blob_key)
+);
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	errNodeNotFound = errors.New("node not found")
+	errNodeExists   = errors.New("node already exists")
+)
+
+// Role yang diterima kolom nodes.role
+var validNodeRoles = map[string]bool{
+	"MAIN":    true,
+	"REPLICA": true,
+	"BACKUP":  true,
+}
+
+var nodeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+var nodeLabelPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{0,50}$`)
+
+func validateNodeID(id string) error {
+	if !nodeIDPattern.MatchString(id) {
+		return fmt.Errorf("id harus 1-50 karakter (huruf, angka, '-' atau '_')")
+	}
+	return nil
+}
+
+// validateNodeLabels memvalidasi label zone/rack; string kosong berarti tanpa label
+func validateNodeLabels(zone, rack string) error {
+	if !nodeLabelPattern.MatchString(zone) || !nodeLabelPattern.MatchString(rack) {
+		return fmt.Errorf("zone dan rack maksimal 50 karakter (huruf, angka, '.', '-' atau '_')")
+	}
+	return nil
+}
+
+func validateNodeRole(role string) error {
+	if !validNodeRoles[role] {
+		return fmt.Errorf("role harus salah satu dari MAIN, REPLICA, BACKUP")
+	}
+	return nil
+}
+
+// normalizeNodeAddress memvalidasi address storage node dan membuang trailing slash
+// supaya path seperti address + "/health" tetap valid.
+func normalizeNodeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("address tidak valid: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("address harus diawali http:// atau https://")
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return "", fmt.Errorf("address harus menyertakan host")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("address tidak boleh mengandung query atau fragment")
+	}
+	return strings.TrimRight(address, "/"), nil
+}
+
+func getNode(nodeID string) (*Node, error) {
+	return metadata.GetNode(nodeID)
+}
+
+// registerNode membuat row node baru. Node yang sebelumnya RETIRED boleh
+// didaftarkan ulang dengan id yang sama.
+func registerNode(nodeID, address, role, zone, rack string) error {
+	existing, err := getNode(nodeID)
+	if err != nil && err != errNodeNotFound {
+		return err
+	}
+	if existing != nil && existing.Status != "RETIRED" {
+		return errNodeExists
+	}
+	return metadata.UpsertNode(nodeID, address, role, zone, rack)
+}
+
+func updateNode(nodeID, address, role, zone, rack string) error {
+	return metadata.UpdateNode(nodeID, address, role, zone, rack)
+}
+
+func countActiveLocationsOnNode(nodeID string) (int, error) {
+	return metadata.CountActiveLocationsOnNode(nodeID)
+}
+
+// retireNode menonaktifkan node secara permanen: lokasi file di node tersebut
+// ditandai DELETED dan antrian replikasi ke node itu dibuang
+func retireNode(nodeID string) error {
+	return metadata.RetireNode(nodeID)
+}
+
+// getActiveNodeIDs mengembalikan semua node yang belum RETIRED
+func getActiveNodeIDs(nodes []Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Status != "RETIRED" {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
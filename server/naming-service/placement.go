@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+)
+
+// Jumlah salinan default untuk setiap file. Bisa di-override per upload
+// lewat field/query replication_factor.
+var defaultReplicationFactor = getEnvInt("REPLICATION_FACTOR", 3)
+
+// parseReplicationFactor membaca override replication factor dari request.
+// String kosong berarti pakai default global.
+func parseReplicationFactor(raw string) (int, error) {
+	if raw == "" {
+		return defaultReplicationFactor, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("replication_factor harus angka >= 1")
+	}
+	return n, nil
+}
+
+// selectNodesForUpload mengurutkan node UP memakai PLACEMENT_POLICY dan
+// mengembalikan seluruh kandidat placement. Caller mengambil target dari depan
+// sebanyak yang dibutuhkan dan lanjut ke node berikutnya jika ada target yang
+// gagal. Node yang sedang drain atau disk-nya penuh tidak menerima data baru,
+// node overload/unreachable hanya menjadi cadangan di belakang. MAIN
+// didahulukan dan BACKUP diakhirkan di dalam masing-masing kelompok, lalu node
+// pertama dari setiap failure domain yang belum ada di used (boleh nil)
+// didahulukan supaya salinan tidak berkumpul di satu domain.
+func selectNodesForUpload(nodes []Node, used map[string]bool) []Node {
+	candidates := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Status == "UP" && node.DrainState != "DRAINING" {
+			candidates = append(candidates, node)
+		}
+	}
+
+	ready, fallback := splitForWrite(candidates)
+	return spreadWriteTiers(ready, fallback, used)
+}
+
+// newFileKey membuat UUID v4, format yang sama dengan file_id dari storage node
+func newFileKey() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func nodeIDs(nodes []Node) []string {
+	var ids []string
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestSelectNodesForUploadKeepsFallbackLast(t *testing.T) {
+	policy, maxInflight := placementPolicy, placementMaxInflight
+	level, strict := failureDomainLevel, failureDomainStrict
+	placementPolicy, placementMaxInflight = latencyPolicy{}, 10
+	failureDomainLevel, failureDomainStrict = "zone", false
+	t.Cleanup(func() {
+		placementPolicy, placementMaxInflight = policy, maxInflight
+		failureDomainLevel, failureDomainStrict = level, strict
+	})
+
+	busy := int64(50)
+	tests := []struct {
+		name  string
+		nodes []Node
+		want  []string
+	}{
+		{
+			name: "overloaded main after ready replica",
+			nodes: []Node{
+				{ID: "main", Role: "MAIN", Status: "UP", LatencyMs: 1, InflightRequests: &busy},
+				{ID: "replica", Role: "REPLICA", Status: "UP", LatencyMs: 5},
+			},
+			want: []string{"replica", "main"},
+		},
+		{
+			name: "unreachable main after ready replica",
+			nodes: []Node{
+				{ID: "main", Role: "MAIN", Status: "UP", LatencyMs: unreachableLatencyMs},
+				{ID: "replica", Role: "REPLICA", Status: "UP", LatencyMs: 5},
+			},
+			want: []string{"replica", "main"},
+		},
+		{
+			name: "ready main first",
+			nodes: []Node{
+				{ID: "backup", Role: "BACKUP", Status: "UP", LatencyMs: 1},
+				{ID: "replica", Role: "REPLICA", Status: "UP", LatencyMs: 2},
+				{ID: "main", Role: "MAIN", Status: "UP", LatencyMs: 9},
+			},
+			want: []string{"main", "replica", "backup"},
+		},
+		{
+			name: "fallback in a new domain stays behind ready nodes",
+			nodes: []Node{
+				{ID: "main", Role: "MAIN", Status: "UP", Zone: "z2", InflightRequests: &busy},
+				{ID: "r1", Role: "REPLICA", Status: "UP", Zone: "z1", LatencyMs: 1},
+				{ID: "r2", Role: "REPLICA", Status: "UP", Zone: "z1", LatencyMs: 2},
+			},
+			want: []string{"r1", "r2", "main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodeIDs(selectNodesForUpload(tt.nodes, nil)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectNodesForUpload = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Rotasi per chunk hanya memutar node ready
+	busyMain := Node{ID: "main", Role: "MAIN", Status: "UP", InflightRequests: &busy}
+	candidates := selectNodesForUpload([]Node{busyMain, {ID: "a", Status: "UP", LatencyMs: 1}, {ID: "b", Status: "UP", LatencyMs: 2}}, nil)
+	for start := 0; start < 3; start++ {
+		if order := nodeIDs(placementOrder(candidates, start)); order[len(order)-1] != "main" {
+			t.Errorf("placementOrder(start=%d) = %v, overloaded main should be last", start, order)
+		}
+	}
+}
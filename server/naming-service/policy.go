@@ -0,0 +1,239 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+)
+
+// PlacementPolicy menentukan urutan node untuk menulis (upload, replikasi)
+// dan untuk membaca (download). Input sudah difilter: hanya node UP, dan untuk
+// write juga tanpa node yang sedang drain.
+type PlacementPolicy interface {
+	Name() string
+	RankForWrite(nodes []Node) []Node
+	RankForRead(nodes []Node) []Node
+}
+
+// Latency yang dicatat jika node tidak bisa dihubungi
+const unreachableLatencyMs = 9999
+
+var (
+	// Node dengan sisa disk di bawah ini tidak menerima upload baru
+	placementMinFreeBytes = int64(getEnvInt("PLACEMENT_MIN_FREE_MB", 512)) << 20
+	// Node dengan in-flight request >= ini dipindah ke akhir daftar write (0 = tanpa batas)
+	placementMaxInflight = int64(getEnvInt("PLACEMENT_MAX_INFLIGHT", 0))
+)
+
+var placementPolicies = map[string]PlacementPolicy{
+	"latency":          latencyPolicy{},
+	"weighted-latency": weightedLatencyPolicy{},
+	"free-space":       freeSpacePolicy{},
+	"least-inflight":   leastInflightPolicy{},
+	"p2c":              powerOfTwoPolicy{},
+}
+
+var placementPolicy = loadPlacementPolicy(getEnv("PLACEMENT_POLICY", "latency"))
+
+func loadPlacementPolicy(name string) PlacementPolicy {
+	if policy, ok := placementPolicies[strings.ToLower(name)]; ok {
+		return policy
+	}
+	log.Printf("⚠️ PLACEMENT_POLICY=%q tidak dikenal, pakai latency\n", name)
+	return latencyPolicy{}
+}
+
+func latencyOf(n Node) int64 {
+	if n.LatencyMs <= 0 {
+		return 1
+	}
+	return n.LatencyMs
+}
+
+func inflightOf(n Node) int64 {
+	if n.InflightRequests == nil {
+		return 0
+	}
+	return *n.InflightRequests
+}
+
+// freeRatioOf mengembalikan sisa disk relatif; node yang belum melapor
+// dianggap setengah penuh supaya tidak selalu kalah atau menang
+func freeRatioOf(n Node) float64 {
+	if n.FreeBytes == nil || n.CapacityBytes == nil || *n.CapacityBytes <= 0 {
+		return 0.5
+	}
+	return float64(*n.FreeBytes) / float64(*n.CapacityBytes)
+}
+
+func isFull(n Node) bool {
+	return n.FreeBytes != nil && *n.FreeBytes < placementMinFreeBytes
+}
+
+func isOverloaded(n Node) bool {
+	return placementMaxInflight > 0 && inflightOf(n) >= placementMaxInflight
+}
+
+func sortedBy(nodes []Node, less func(a, b Node) bool) []Node {
+	out := append([]Node(nil), nodes...)
+	sort.SliceStable(out, func(i, j int) bool { return less(out[i], out[j]) })
+	return out
+}
+
+func byLatency(a, b Node) bool {
+	return latencyOf(a) < latencyOf(b)
+}
+
+// weightedShuffle mengacak urutan node dengan peluang sebanding weight(n),
+// sehingga node terbaik paling sering di depan tapi beban tetap menyebar
+func weightedShuffle(nodes []Node, weight func(Node) float64) []Node {
+	pool := append([]Node(nil), nodes...)
+	out := make([]Node, 0, len(pool))
+	for len(pool) > 0 {
+		total := 0.0
+		for _, n := range pool {
+			total += weight(n)
+		}
+		pick := len(pool) - 1
+		r := rand.Float64() * total
+		for i, n := range pool {
+			if r -= weight(n); r < 0 {
+				pick = i
+				break
+			}
+		}
+		out = append(out, pool[pick])
+		pool = append(pool[:pick], pool[pick+1:]...)
+	}
+	return out
+}
+
+// latencyPolicy: latency terendah selalu di depan (perilaku awal naming service)
+type latencyPolicy struct{}
+
+func (latencyPolicy) Name() string { return "latency" }
+
+func (latencyPolicy) RankForWrite(nodes []Node) []Node { return sortedBy(nodes, byLatency) }
+
+func (latencyPolicy) RankForRead(nodes []Node) []Node { return sortedBy(nodes, byLatency) }
+
+// weightedLatencyPolicy: acak berbobot 1/latency, node lambat tetap kebagian sedikit
+type weightedLatencyPolicy struct{}
+
+func (weightedLatencyPolicy) Name() string { return "weighted-latency" }
+
+func inverseLatency(n Node) float64 {
+	return 1 / float64(latencyOf(n))
+}
+
+func (weightedLatencyPolicy) RankForWrite(nodes []Node) []Node {
+	return weightedShuffle(nodes, inverseLatency)
+}
+
+func (weightedLatencyPolicy) RankForRead(nodes []Node) []Node {
+	return weightedShuffle(nodes, inverseLatency)
+}
+
+// freeSpacePolicy: upload ke node dengan sisa disk relatif terbesar
+type freeSpacePolicy struct{}
+
+func (freeSpacePolicy) Name() string { return "free-space" }
+
+func (freeSpacePolicy) RankForWrite(nodes []Node) []Node {
+	return sortedBy(nodes, func(a, b Node) bool {
+		if fa, fb := freeRatioOf(a), freeRatioOf(b); fa != fb {
+			return fa > fb
+		}
+		return byLatency(a, b)
+	})
+}
+
+func (freeSpacePolicy) RankForRead(nodes []Node) []Node { return sortedBy(nodes, byLatency) }
+
+// leastInflightPolicy: node dengan request berjalan paling sedikit
+type leastInflightPolicy struct{}
+
+func (leastInflightPolicy) Name() string { return "least-inflight" }
+
+func byInflight(a, b Node) bool {
+	if ia, ib := inflightOf(a), inflightOf(b); ia != ib {
+		return ia < ib
+	}
+	return byLatency(a, b)
+}
+
+func (leastInflightPolicy) RankForWrite(nodes []Node) []Node { return sortedBy(nodes, byInflight) }
+
+func (leastInflightPolicy) RankForRead(nodes []Node) []Node { return sortedBy(nodes, byInflight) }
+
+// powerOfTwoPolicy: ambil dua node acak, pakai yang bebannya lebih ringan.
+// Diulang sampai semua node terurut; murah dan menghindari herd ke satu node.
+type powerOfTwoPolicy struct{}
+
+func (powerOfTwoPolicy) Name() string { return "p2c" }
+
+func powerOfTwoChoices(nodes []Node) []Node {
+	pool := append([]Node(nil), nodes...)
+	out := make([]Node, 0, len(pool))
+	for len(pool) > 1 {
+		i := rand.IntN(len(pool))
+		j := rand.IntN(len(pool) - 1)
+		if j >= i {
+			j++
+		}
+		if byInflight(pool[j], pool[i]) {
+			i = j
+		}
+		out = append(out, pool[i])
+		pool = append(pool[:i], pool[i+1:]...)
+	}
+	return append(out, pool...)
+}
+
+func (powerOfTwoPolicy) RankForWrite(nodes []Node) []Node { return powerOfTwoChoices(nodes) }
+
+func (powerOfTwoPolicy) RankForRead(nodes []Node) []Node { return powerOfTwoChoices(nodes) }
+
+// isWriteFallback true untuk node yang hanya dipakai jika node lain gagal:
+// in-flight request melewati batas atau tidak bisa dihubungi
+func isWriteFallback(n Node) bool {
+	return isOverloaded(n) || n.LatencyMs >= unreachableLatencyMs
+}
+
+// splitForWrite menjalankan policy lalu menerapkan batas bersama: node penuh
+// dibuang, node overload dan unreachable menjadi cadangan. Di dalam kedua
+// kelompok MAIN didahulukan dan BACKUP diakhirkan.
+func splitForWrite(nodes []Node) (ready, fallback []Node) {
+	for _, n := range sortByRole(placementPolicy.RankForWrite(nodes), writeRoleRank) {
+		switch {
+		case isFull(n):
+			continue
+		case isWriteFallback(n):
+			fallback = append(fallback, n)
+		default:
+			ready = append(ready, n)
+		}
+	}
+	return ready, fallback
+}
+
+// rankForWrite mengurutkan node untuk write, cadangan selalu di belakang
+func rankForWrite(nodes []Node) []Node {
+	ready, fallback := splitForWrite(nodes)
+	return append(ready, fallback...)
+}
+
+// rankForRead menjalankan policy; BACKUP di belakang REPLICA/MAIN dan node
+// unreachable selalu terakhir
+func rankForRead(nodes []Node) []Node {
+	var ready, fallback []Node
+	for _, n := range sortByRole(placementPolicy.RankForRead(nodes), readRoleRank) {
+		if n.LatencyMs >= unreachableLatencyMs {
+			fallback = append(fallback, n)
+		} else {
+			ready = append(ready, n)
+		}
+	}
+	return append(ready, fallback...)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rebalancer memindahkan salinan dari node yang paling penuh ke node yang
+// paling kosong sampai pemakaian semua node UP berada dalam toleransi dari
+// rata-rata. Pemakaian dihitung dari metadata (file_locations x files.size_bytes
+// dan chunk_locations x file_chunks.size_bytes), bukan dari disk node.
+var (
+	rebalanceTolerancePercent = getEnvInt("REBALANCE_TOLERANCE_PERCENT", 10)
+	rebalanceMaxMoves         = getEnvInt("REBALANCE_MAX_MOVES", 100)
+	rebalanceBandwidthMBps    = getEnvInt("REBALANCE_BANDWIDTH_MBPS", 20)
+)
+
+var errRebalanceRunning = errors.New("rebalance sedang berjalan")
+
+// rebalanceObject adalah satu salinan yang bisa dipindah: file REPLICATED utuh
+// (ChunkIndex nil) atau satu chunk file CHUNKED
+type rebalanceObject struct {
+	FileKey    string `json:"file_key"`
+	ChunkIndex *int   `json:"chunk_index,omitempty"`
+	SizeBytes  int64  `json:"size_bytes"`
+}
+
+func (o rebalanceObject) objectKey() string {
+	if o.ChunkIndex != nil {
+		return chunkObjectKey(o.FileKey, *o.ChunkIndex)
+	}
+	return o.FileKey
+}
+
+type RebalanceMove struct {
+	rebalanceObject
+	FromNodeID string `json:"from_node_id"`
+	ToNodeID   string `json:"to_node_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type RebalancePlan struct {
+	MeanBytes        int64            `json:"mean_bytes"`
+	TolerancePercent int              `json:"tolerance_percent"`
+	UsageBefore      map[string]int64 `json:"usage_before"`
+	UsageAfter       map[string]int64 `json:"usage_after"`
+	Moves            []RebalanceMove  `json:"moves"`
+}
+
+type RebalanceRun struct {
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Plan        *RebalancePlan `json:"plan"`
+	Moved       int            `json:"moved"`
+	Failed      int            `json:"failed"`
+	BytesMoved  int64          `json:"bytes_moved"`
+}
+
+type rebalanceState struct {
+	mu      sync.Mutex
+	current *RebalanceRun
+	last    *RebalanceRun
+}
+
+var rebalancer rebalanceState
+
+// clone menyalin run beserta status setiap move, dipanggil dengan mu terkunci
+func (r *RebalanceRun) clone() *RebalanceRun {
+	c := *r
+	plan := *r.Plan
+	plan.Moves = append([]RebalanceMove(nil), r.Plan.Moves...)
+	c.Plan = &plan
+	return &c
+}
+
+func (s *rebalanceState) snapshot() (current, last *RebalanceRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		current = s.current.clone()
+	}
+	if s.last != nil {
+		last = s.last.clone()
+	}
+	return current, last
+}
+
+// getNodeObjects mengembalikan salinan ACTIVE per node. File ERASURE tidak
+// ikut karena shard tidak bisa dipindah ke node yang sudah punya shard lain.
+func getNodeObjects() (map[string][]rebalanceObject, error) {
+	copies, err := metadata.ListObjectCopies("")
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]rebalanceObject)
+	for _, c := range copies {
+		if c.ChunkIndex == nil && (c.StorageMode != "REPLICATED" || c.ShardIndex != nil) {
+			continue
+		}
+		objects[c.NodeID] = append(objects[c.NodeID], rebalanceObject{FileKey: c.FileKey, ChunkIndex: c.ChunkIndex, SizeBytes: c.SizeBytes})
+	}
+	return objects, nil
+}
+
+// planRebalance menyusun daftar perpindahan secara greedy: selalu dari node
+// terpenuh ke node terkosong, memilih object terbesar yang masih memperkecil
+// selisih keduanya, sampai semua node dalam toleransi. Object tidak dipindah ke
+// failure domain yang sudah punya salinan lain dari object tersebut.
+func planRebalance(nodes []Node, objects map[string][]rebalanceObject, tolerancePercent, maxMoves int) *RebalancePlan {
+	plan := &RebalancePlan{
+		TolerancePercent: tolerancePercent,
+		UsageBefore:      make(map[string]int64),
+		UsageAfter:       make(map[string]int64),
+	}
+
+	var eligible []string
+	domainOf := make(map[string]string)
+	for _, n := range nodes {
+		domainOf[n.ID] = failureDomain(n)
+		if n.Status == "UP" && n.DrainState != "DRAINING" {
+			eligible = append(eligible, n.ID)
+		}
+	}
+	if len(eligible) < 2 {
+		return plan
+	}
+
+	// holders[object][node] termasuk node yang tidak ikut rebalance, supaya
+	// target tidak menerima salinan kedua atau domain yang sama
+	holders := make(map[string]map[string]bool)
+	for nodeID, list := range objects {
+		for _, obj := range list {
+			if holders[obj.objectKey()] == nil {
+				holders[obj.objectKey()] = make(map[string]bool)
+			}
+			holders[obj.objectKey()][nodeID] = true
+		}
+	}
+	canReceive := func(obj rebalanceObject, from, to string) bool {
+		for nodeID := range holders[obj.objectKey()] {
+			if nodeID == to || (nodeID != from && domainOf[nodeID] == domainOf[to]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var total int64
+	for _, nodeID := range eligible {
+		plan.UsageBefore[nodeID] = 0
+		for _, obj := range objects[nodeID] {
+			plan.UsageBefore[nodeID] += obj.SizeBytes
+		}
+		plan.UsageAfter[nodeID] = plan.UsageBefore[nodeID]
+		total += plan.UsageBefore[nodeID]
+	}
+	plan.MeanBytes = total / int64(len(eligible))
+	slack := plan.MeanBytes * int64(tolerancePercent) / 100
+
+	// Object terbesar dicoba duluan supaya jumlah perpindahan sedikit
+	candidates := make(map[string][]rebalanceObject)
+	for _, nodeID := range eligible {
+		list := append([]rebalanceObject(nil), objects[nodeID]...)
+		sort.SliceStable(list, func(i, j int) bool { return list[i].SizeBytes > list[j].SizeBytes })
+		candidates[nodeID] = list
+	}
+
+	for len(plan.Moves) < maxMoves {
+		sort.SliceStable(eligible, func(i, j int) bool {
+			return plan.UsageAfter[eligible[i]] > plan.UsageAfter[eligible[j]]
+		})
+		from, to := eligible[0], eligible[len(eligible)-1]
+		if plan.UsageAfter[from] <= plan.MeanBytes+slack && plan.UsageAfter[to] >= plan.MeanBytes-slack {
+			break
+		}
+
+		gap := plan.UsageAfter[from] - plan.UsageAfter[to]
+		picked := -1
+		for i, obj := range candidates[from] {
+			if obj.SizeBytes > 0 && obj.SizeBytes < gap && canReceive(obj, from, to) {
+				picked = i
+				break
+			}
+		}
+		if picked < 0 {
+			break
+		}
+
+		obj := candidates[from][picked]
+		candidates[from] = append(candidates[from][:picked], candidates[from][picked+1:]...)
+		delete(holders[obj.objectKey()], from)
+		holders[obj.objectKey()][to] = true
+		plan.UsageAfter[from] -= obj.SizeBytes
+		plan.UsageAfter[to] += obj.SizeBytes
+		plan.Moves = append(plan.Moves, RebalanceMove{rebalanceObject: obj, FromNodeID: from, ToNodeID: to, Status: "PLANNED"})
+	}
+
+	return plan
+}
+
+func buildRebalancePlan(tolerancePercent int) (*RebalancePlan, error) {
+	nodes, err := getAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := getNodeObjects()
+	if err != nil {
+		return nil, err
+	}
+	return planRebalance(nodes, objects, tolerancePercent, rebalanceMaxMoves), nil
+}
+
+// throttledReader membatasi laju baca rata-rata ke bytesPerSec
+type throttledReader struct {
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+func newThrottledReader(r io.Reader, bytesPerSec int64) io.Reader {
+	if bytesPerSec <= 0 {
+		return r
+	}
+	return &throttledReader{r: r, bytesPerSec: bytesPerSec, start: time.Now()}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Potong buffer supaya satu Read tidak melebihi jatah ~100ms
+	if limit := t.bytesPerSec / 10; limit > 0 && int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSec) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// executeRebalanceMove menyalin object ke node baru, memverifikasi salinan baru
+// lewat checksum di node, baru kemudian lokasi lama dipensiunkan
+func executeRebalanceMove(move RebalanceMove, nodeByID map[string]Node) error {
+	from, ok := nodeByID[move.FromNodeID]
+	if !ok || from.Status != "UP" {
+		return fmt.Errorf("source node %s tidak UP", move.FromNodeID)
+	}
+	to, ok := nodeByID[move.ToNodeID]
+	if !ok || to.Status != "UP" {
+		return fmt.Errorf("target node %s tidak UP", move.ToNodeID)
+	}
+
+	checksum, err := getObjectChecksum(move.FileKey, move.ChunkIndex)
+	if err != nil {
+		return err
+	}
+
+	objectKey := move.objectKey()
+	bytesPerSec := int64(rebalanceBandwidthMBps) << 20
+	if err := replicateFileToNodeThrottled(objectKey, checksum, from.Address, to.Address, bytesPerSec); err != nil {
+		return err
+	}
+
+	stored, size, err := scrubObject(to, objectKey)
+	if err != nil {
+		return fmt.Errorf("gagal verifikasi salinan baru: %v", err)
+	}
+	if size != move.SizeBytes || (checksum != "" && !strings.EqualFold(stored, checksum)) {
+		deleteFileFromNode(to.Address, objectKey)
+		return fmt.Errorf("%w: salinan baru %s di %s", errChecksumMismatch, objectKey, to.ID)
+	}
+
+	if move.ChunkIndex != nil {
+		err = markChunkLocationActive(move.FileKey, *move.ChunkIndex, to.ID)
+	} else {
+		err = markFileLocationActive(move.FileKey, to.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Lokasi lama ditandai DELETED dulu supaya download tidak diarahkan ke sana
+	if err := markLocationStatus(move.FileKey, move.ChunkIndex, from.ID, "DELETED"); err != nil {
+		return err
+	}
+	if status, err := deleteFileFromNode(from.Address, objectKey); err != nil || (status != 200 && status != 404) {
+		log.Printf("⚠️ Rebalance: salinan lama %s di %s belum terhapus (status %d, err %v)\n", objectKey, from.ID, status, err)
+	}
+	return nil
+}
+
+// startRebalance menjalankan plan di background. Hanya satu rebalance boleh
+// berjalan dalam satu waktu.
+func startRebalance(plan *RebalancePlan) error {
+	rebalancer.mu.Lock()
+	if rebalancer.current != nil {
+		rebalancer.mu.Unlock()
+		return errRebalanceRunning
+	}
+	// Plan milik caller tidak diubah; status move dicatat di salinan milik run
+	run := (&RebalanceRun{StartedAt: time.Now(), Plan: plan}).clone()
+	plan = run.Plan
+	rebalancer.current = run
+	rebalancer.mu.Unlock()
+
+	go func() {
+		log.Printf("⚖️ Rebalance started: %d moves planned\n", len(plan.Moves))
+
+		for i := range plan.Moves {
+			nodes, err := getAllNodes()
+			nodeByID := make(map[string]Node)
+			for _, n := range nodes {
+				nodeByID[n.ID] = n
+			}
+			if err == nil {
+				err = executeRebalanceMove(plan.Moves[i], nodeByID)
+			}
+
+			rebalancer.mu.Lock()
+			if err != nil {
+				plan.Moves[i].Status = "FAILED"
+				plan.Moves[i].Error = err.Error()
+				run.Failed++
+			} else {
+				plan.Moves[i].Status = "DONE"
+				run.Moved++
+				run.BytesMoved += plan.Moves[i].SizeBytes
+			}
+			rebalancer.mu.Unlock()
+
+			if err != nil {
+				log.Printf("❌ Rebalance %s %s -> %s failed: %v\n", plan.Moves[i].objectKey(), plan.Moves[i].FromNodeID, plan.Moves[i].ToNodeID, err)
+			} else {
+				log.Printf("⚖️ Rebalanced %s %s -> %s\n", plan.Moves[i].objectKey(), plan.Moves[i].FromNodeID, plan.Moves[i].ToNodeID)
+			}
+		}
+
+		rebalancer.mu.Lock()
+		now := time.Now()
+		run.CompletedAt = &now
+		rebalancer.last = run
+		rebalancer.current = nil
+		rebalancer.mu.Unlock()
+
+		log.Printf("⚖️ Rebalance done: %d moved, %d failed, %d bytes\n", run.Moved, run.Failed, run.BytesMoved)
+	}()
+
+	return nil
+}
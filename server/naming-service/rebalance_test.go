@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func rebalanceObj(key string, size int64) rebalanceObject {
+	return rebalanceObject{FileKey: key, SizeBytes: size}
+}
+
+// checkRebalancePlan membandingkan move yang direncanakan dan memastikan total
+// pemakaian sebelum dan sesudah rencana tetap sama
+func checkRebalancePlan(t *testing.T, plan *RebalancePlan, want ...string) {
+	t.Helper()
+	var got []string
+	for _, m := range plan.Moves {
+		got = append(got, fmt.Sprintf("%s %s->%s", m.objectKey(), m.FromNodeID, m.ToNodeID))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("moves = %v, want %v", got, want)
+	}
+
+	var before, after int64
+	for nodeID, used := range plan.UsageBefore {
+		before += used
+		after += plan.UsageAfter[nodeID]
+	}
+	if before != after {
+		t.Errorf("usage before %d != after %d", before, after)
+	}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	twoNodes := []Node{{ID: "n1", Status: "UP"}, {ID: "n2", Status: "UP"}}
+
+	t.Run("balanced within tolerance", func(t *testing.T) {
+		objects := map[string][]rebalanceObject{"n1": {rebalanceObj("a", 100)}, "n2": {rebalanceObj("b", 100)}}
+		checkRebalancePlan(t, planRebalance(twoNodes, objects, 10, 100))
+	})
+
+	t.Run("largest object that closes the gap", func(t *testing.T) {
+		objects := map[string][]rebalanceObject{"n1": {rebalanceObj("a", 100), rebalanceObj("b", 50)}}
+		checkRebalancePlan(t, planRebalance(twoNodes, objects, 10, 100), "a n1->n2")
+	})
+
+	t.Run("target already holds a copy", func(t *testing.T) {
+		objects := map[string][]rebalanceObject{
+			"n1": {rebalanceObj("a", 60), rebalanceObj("b", 50), rebalanceObj("d", 30)},
+			"n2": {rebalanceObj("a", 60)},
+		}
+		checkRebalancePlan(t, planRebalance(twoNodes, objects, 10, 100), "b n1->n2")
+	})
+
+	t.Run("chunks are moved individually", func(t *testing.T) {
+		chunk := func(i int, size int64) rebalanceObject {
+			return rebalanceObject{FileKey: "big", ChunkIndex: &i, SizeBytes: size}
+		}
+		objects := map[string][]rebalanceObject{"n1": {chunk(0, 80), chunk(1, 80)}}
+		checkRebalancePlan(t, planRebalance(twoNodes, objects, 10, 100), chunkObjectKey("big", 0)+" n1->n2")
+	})
+
+	t.Run("copy on a down node blocks its failure domain", func(t *testing.T) {
+		level := failureDomainLevel
+		failureDomainLevel = "rack"
+		t.Cleanup(func() { failureDomainLevel = level })
+
+		nodes := []Node{{ID: "n1", Status: "UP", Rack: "r1"}, {ID: "n2", Status: "UP", Rack: "r2"}, {ID: "n3", Status: "DOWN", Rack: "r2"}}
+		objects := map[string][]rebalanceObject{
+			"n1": {rebalanceObj("a", 100), rebalanceObj("b", 40)},
+			"n3": {rebalanceObj("a", 100)},
+		}
+		checkRebalancePlan(t, planRebalance(nodes, objects, 10, 100), "b n1->n2")
+	})
+
+	t.Run("draining node is not a target", func(t *testing.T) {
+		nodes := []Node{{ID: "n1", Status: "UP"}, {ID: "n2", Status: "UP", DrainState: "DRAINING"}, {ID: "n3", Status: "UP"}}
+		objects := map[string][]rebalanceObject{"n1": {rebalanceObj("a", 100), rebalanceObj("b", 100)}}
+		checkRebalancePlan(t, planRebalance(nodes, objects, 10, 100), "a n1->n3")
+	})
+
+	t.Run("max moves", func(t *testing.T) {
+		objects := map[string][]rebalanceObject{"n1": {rebalanceObj("a", 30), rebalanceObj("b", 30), rebalanceObj("c", 30), rebalanceObj("d", 30)}}
+		checkRebalancePlan(t, planRebalance(twoNodes, objects, 10, 1), "a n1->n2")
+	})
+
+	t.Run("single eligible node", func(t *testing.T) {
+		nodes := []Node{{ID: "n1", Status: "UP"}, {ID: "n2", Status: "DOWN"}}
+		objects := map[string][]rebalanceObject{"n1": {rebalanceObj("a", 100)}}
+		checkRebalancePlan(t, planRebalance(nodes, objects, 10, 100))
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// Worker pool yang terus memproses replication_queue. Item di-lease (IN_PROGRESS
+// dengan lease_expires_at) supaya tidak diproses dua worker sekaligus; lease
+// yang habis karena worker mati dikembalikan ke PENDING oleh reclaimer.
+var (
+	replicationWorkers     = getEnvInt("REPLICATION_WORKERS", 4)
+	replicationMaxRetries  = getEnvInt("REPLICATION_MAX_RETRIES", 5)
+	replicationBackoffBase = time.Duration(getEnvInt("REPLICATION_BACKOFF_BASE_SECONDS", 5)) * time.Second
+	replicationBackoffMax  = time.Duration(getEnvInt("REPLICATION_BACKOFF_MAX_SECONDS", 600)) * time.Second
+	replicationLease       = time.Duration(getEnvInt("REPLICATION_LEASE_SECONDS", 300)) * time.Second
+)
+
+const replicationPollInterval = 2 * time.Second
+
+// replicationWake membangunkan worker tanpa menunggu poll berikutnya
+var replicationWake = make(chan struct{}, 1)
+
+func wakeReplicationWorkers() {
+	select {
+	case replicationWake <- struct{}{}:
+	default:
+	}
+}
+
+// replicationBackoff: base * 2^retry, dibatasi replicationBackoffMax
+func replicationBackoff(retryCount int) time.Duration {
+	delay := replicationBackoffBase
+	for i := 0; i < retryCount && delay < replicationBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > replicationBackoffMax {
+		delay = replicationBackoffMax
+	}
+	return delay
+}
+
+// leaseReplications mengambil item PENDING/FAILED dan menandainya IN_PROGRESS
+// atas nama owner. Tanpa targetNodeID hanya item yang sudah lewat backoff dan
+// target-nya UP yang diambil; dengan targetNodeID (recover manual) backoff diabaikan.
+func leaseReplications(owner, targetNodeID string, limit int) ([]ReplicationQueueItem, error) {
+	return metadata.LeaseReplications(owner, targetNodeID, limit, replicationLease)
+}
+
+// reclaimExpiredLeases mengembalikan item IN_PROGRESS yang lease-nya habis ke PENDING
+func reclaimExpiredLeases() (int64, error) {
+	return metadata.ReclaimExpiredReplications()
+}
+
+// resetReplicationBackoff membuat item yang berkaitan dengan node langsung
+// dicoba lagi, dipakai saat node kembali UP
+func resetReplicationBackoff(nodeID string) error {
+	return metadata.ResetReplicationBackoff(nodeID)
+}
+
+// retryDeadReplication mengembalikan item DEAD ke PENDING dengan retry_count baru
+func retryDeadReplication(queueID int) (bool, error) {
+	return metadata.RetryDeadReplication(queueID)
+}
+
+// getReplicationHolders mengembalikan node yang punya salinan ACTIVE dari object item
+func getReplicationHolders(item ReplicationQueueItem) ([]string, error) {
+	switch {
+	case item.ShardIndex != nil:
+		shards, err := getShardLocations(item.FileKey)
+		if err != nil {
+			return nil, err
+		}
+		var nodeIDs []string
+		for nodeID, index := range shards {
+			if index == *item.ShardIndex {
+				nodeIDs = append(nodeIDs, nodeID)
+			}
+		}
+		return nodeIDs, nil
+	case item.ChunkIndex != nil:
+		chunk, err := metadata.GetChunk(item.FileKey, *item.ChunkIndex)
+		if chunk == nil || err != nil {
+			return nil, err
+		}
+		return chunk.Locations, nil
+	default:
+		return getFileLocations(item.FileKey)
+	}
+}
+
+// processReplicationItem menjalankan satu item. Jika source awal tidak UP,
+// salinan ACTIVE lain yang UP dipakai sebagai source. Shard erasure yang tidak
+// punya salinan ACTIVE di node UP dibangun ulang dari k shard lain.
+func processReplicationItem(item ReplicationQueueItem) error {
+	nodes, err := getAllNodes()
+	if err != nil {
+		return err
+	}
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	target, ok := nodeByID[item.TargetNodeID]
+	if !ok {
+		return fmt.Errorf("target node %s not found", item.TargetNodeID)
+	}
+
+	source, ok := nodeByID[item.SourceNodeID]
+	usable := ok && source.Status == "UP"
+	if usable && item.ShardIndex != nil {
+		// Source item shard bisa berupa node yang menyimpan shard lain (rebuild
+		// dari scrubber / planner), shard-nya sendiri tidak punya salinan identik
+		holders, err := getReplicationHolders(item)
+		if err != nil {
+			return err
+		}
+		usable = slices.Contains(holders, source.ID) && source.ID != item.TargetNodeID
+	}
+	if !usable {
+		holders, err := getReplicationHolders(item)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, nodeID := range holders {
+			if n, ok := nodeByID[nodeID]; ok && n.Status == "UP" && nodeID != item.TargetNodeID {
+				source, found = n, true
+				break
+			}
+		}
+		if !found && item.ShardIndex != nil {
+			return rebuildQueuedShard(item.FileKey, *item.ShardIndex, target, nodeByID)
+		}
+		if !found {
+			return fmt.Errorf("no UP source for %s", item.FileKey)
+		}
+	}
+
+	return replicateQueueItem(item, source.Address, target.Address)
+}
+
+// extendReplicationLease memperpanjang lease item yang masih dikerjakan owner
+func extendReplicationLease(queueID int, owner string) error {
+	return metadata.ExtendReplicationLease(queueID, owner, replicationLease)
+}
+
+// runReplicationItem memproses item yang sudah di-lease dan mencatat hasilnya.
+// Selama transfer berjalan lease diperpanjang supaya file besar tidak di-reclaim.
+func runReplicationItem(item ReplicationQueueItem) error {
+	done := make(chan struct{})
+	if item.LeaseOwner != nil {
+		go func() {
+			ticker := time.NewTicker(replicationLease / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if err := extendReplicationLease(item.ID, *item.LeaseOwner); err != nil {
+						log.Println("error extend replication lease:", err)
+					}
+				}
+			}
+		}()
+	}
+
+	err := processReplicationItem(item)
+	close(done)
+	if err != nil {
+		log.Printf("❌ Replication %d (%s -> %s) failed: %v\n", item.ID, item.FileKey, item.TargetNodeID, err)
+		if markErr := markReplicationFailed(item, err.Error()); markErr != nil {
+			log.Println("error update replication queue:", markErr)
+		}
+		return err
+	}
+
+	if err := markReplicationCompleted(item.ID); err != nil {
+		log.Println("error update replication queue:", err)
+	}
+	log.Printf("✅ Replicated %s to %s\n", item.FileKey, item.TargetNodeID)
+	return nil
+}
+
+func replicationWorker(owner string) {
+	for {
+		waitUntilLeader()
+
+		items, err := leaseReplications(owner, "", 1)
+		if err != nil {
+			log.Println("error lease replication queue:", err)
+		}
+		if len(items) > 0 {
+			runReplicationItem(items[0])
+			continue
+		}
+
+		select {
+		case <-replicationWake:
+		case <-time.After(replicationPollInterval):
+		}
+	}
+}
+
+// runReplicationWorkers menjalankan worker pool dan reclaimer lease
+func runReplicationWorkers() {
+	if replicationWorkers <= 0 {
+		log.Println("🔁 Replication workers disabled (REPLICATION_WORKERS=0)")
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	for i := 0; i < replicationWorkers; i++ {
+		go replicationWorker(fmt.Sprintf("%s-%d-w%d", hostname, os.Getpid(), i))
+	}
+	log.Printf("🔁 Started %d replication workers\n", replicationWorkers)
+
+	interval := replicationLease / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		reclaimed, err := reclaimExpiredLeases()
+		if err != nil {
+			log.Println("error reclaim replication lease:", err)
+			continue
+		}
+		if reclaimed > 0 {
+			log.Printf("♻️ Reclaimed %d replication items with expired lease\n", reclaimed)
+			wakeReplicationWorkers()
+		}
+	}
+}
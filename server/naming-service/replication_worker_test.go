@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicationBackoff(t *testing.T) {
+	base, max := replicationBackoffBase, replicationBackoffMax
+	replicationBackoffBase, replicationBackoffMax = 5*time.Second, time.Minute
+	t.Cleanup(func() { replicationBackoffBase, replicationBackoffMax = base, max })
+
+	tests := []struct {
+		retryCount int
+		want       time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := replicationBackoff(tt.retryCount); got != tt.want {
+			t.Errorf("replicationBackoff(%d) = %v, want %v", tt.retryCount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Planner re-replikasi: node yang DOWN lebih lama dari grace period dianggap
+// hilang. Setiap object yang punya salinan di node tersebut dihitung ulang
+// jumlah salinan sehatnya; jika di bawah target, job baru diantrikan dari
+// salinan yang sehat ke node lain yang belum menyimpan object itu. Shard
+// erasure tidak bisa disalin, jadi diantrikan untuk direkonstruksi replication
+// worker dari k shard yang tersisa. Setelah repair diantrikan, salinan di node
+// hilang ditandai MISSING supaya tidak dipindai ulang setiap putaran.
+var (
+	rereplicationGrace    = time.Duration(getEnvInt("REREPLICATION_GRACE_MINUTES", 10)) * time.Minute
+	rereplicationInterval = time.Duration(getEnvInt("REREPLICATION_INTERVAL_SECONDS", 60)) * time.Second
+)
+
+type ReReplicationStats struct {
+	StartedAt      time.Time  `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	LostNodes      []string   `json:"lost_nodes"`
+	ObjectsChecked int        `json:"objects_checked"`
+	JobsQueued     int        `json:"jobs_queued"`
+	// File ERASURE yang shard-nya ada di node hilang
+	ErasureFiles int `json:"erasure_files"`
+	// Rebuild shard yang diantrikan ke replication worker
+	ShardsQueued int `json:"shards_queued"`
+	// Shard yang tersisa kurang dari k, tidak bisa direkonstruksi
+	ErasureUnrecoverable int `json:"erasure_unrecoverable"`
+}
+
+type reReplicationState struct {
+	mu       sync.Mutex
+	lastPass *ReReplicationStats
+}
+
+var rereplication reReplicationState
+
+func (s *reReplicationState) snapshot() *ReReplicationStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastPass == nil {
+		return nil
+	}
+	l := *s.lastPass
+	return &l
+}
+
+// underReplicatedObject adalah satu file utuh (ChunkIndex nil) atau satu chunk
+// yang punya salinan di node yang hilang
+type underReplicatedObject struct {
+	FileKey     string
+	ChunkIndex  *int
+	StorageMode string
+	Target      int
+}
+
+// getLostNodes mengembalikan node yang sudah DOWN lebih lama dari grace period.
+// Node lama tanpa down_since memakai last_heartbeat sebagai patokan.
+func getLostNodes() (map[string]bool, error) {
+	nodeIDs, err := metadata.ListLostNodes(rereplicationGrace)
+	if err != nil {
+		return nil, err
+	}
+
+	lost := make(map[string]bool)
+	for _, id := range nodeIDs {
+		lost[id] = true
+	}
+	return lost, nil
+}
+
+// getObjectsOnLostNodes mencari file dan chunk yang masih punya salinan ACTIVE
+// di node yang hilang. Shard erasure dihitung sekali per file.
+func getObjectsOnLostNodes(lost map[string]bool) ([]underReplicatedObject, error) {
+	seen := make(map[string]bool)
+	var objects []underReplicatedObject
+	for nodeID := range lost {
+		copies, err := metadata.ListObjectCopies(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range copies {
+			key := c.FileKey
+			if c.ChunkIndex != nil {
+				key = chunkObjectKey(c.FileKey, *c.ChunkIndex)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			objects = append(objects, underReplicatedObject{
+				FileKey:     c.FileKey,
+				ChunkIndex:  c.ChunkIndex,
+				StorageMode: c.StorageMode,
+				Target:      c.target(),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// markLostCopiesMissing menandai salinan object di node hilang sebagai MISSING
+// setelah penggantinya diantrikan
+func markLostCopiesMissing(fileKey string, chunkIndex *int, nodeIDs []string, lost map[string]bool) error {
+	for _, nodeID := range nodeIDs {
+		if !lost[nodeID] {
+			continue
+		}
+		if err := markLocationStatus(fileKey, chunkIndex, nodeID, "MISSING"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getQueuedTargets mengembalikan target replikasi object yang masih ada di
+// antrian beserta statusnya (item COMPLETED diabaikan)
+func getQueuedTargets(fileKey string, chunkIndex, shardIndex *int) (map[string]string, error) {
+	return metadata.ListQueuedTargets(fileKey, chunkIndex, shardIndex)
+}
+
+// planReReplication menghitung salinan sehat satu object dan mengantrikan
+// replikasi baru sebanyak kekurangannya. Mengembalikan jumlah job yang diantrikan.
+func planReReplication(obj underReplicatedObject, nodes []Node, lost map[string]bool) (int, error) {
+	item := ReplicationQueueItem{FileKey: obj.FileKey, ChunkIndex: obj.ChunkIndex}
+	holders, err := getReplicationHolders(item)
+	if err != nil {
+		return 0, err
+	}
+	queued, err := getQueuedTargets(obj.FileKey, obj.ChunkIndex, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	// Salinan di node yang DOWN tapi belum lewat grace period masih dihitung
+	// supaya restart singkat tidak memicu replikasi massal
+	copies := 0
+	source := ""
+	exclude := make(map[string]bool)
+	var kept []string
+	for _, nodeID := range holders {
+		exclude[nodeID] = true
+		if lost[nodeID] {
+			continue
+		}
+		copies++
+		kept = append(kept, nodeID)
+		if source == "" && nodeByID[nodeID].Status == "UP" {
+			source = nodeID
+		}
+	}
+	for nodeID, status := range queued {
+		exclude[nodeID] = true
+		if status != "DEAD" && !lost[nodeID] && !slices.Contains(holders, nodeID) {
+			copies++
+			kept = append(kept, nodeID)
+		}
+	}
+
+	missing := obj.Target - copies
+	if missing <= 0 {
+		return 0, markLostCopiesMissing(obj.FileKey, obj.ChunkIndex, holders, lost)
+	}
+
+	objectKey := obj.FileKey
+	if obj.ChunkIndex != nil {
+		objectKey = chunkObjectKey(obj.FileKey, *obj.ChunkIndex)
+	}
+	if source == "" {
+		log.Printf("❌ Re-replication: no healthy UP copy of %s left\n", objectKey)
+		return 0, nil
+	}
+
+	// Target baru diutamakan dari failure domain yang belum punya salinan
+	enqueued := 0
+	for _, candidate := range selectNodesForUpload(nodes, domainsOf(kept, nodeByID)) {
+		if enqueued == missing {
+			break
+		}
+		if exclude[candidate.ID] {
+			continue
+		}
+
+		if obj.ChunkIndex != nil {
+			err = addChunkToReplicationQueue(obj.FileKey, *obj.ChunkIndex, candidate.ID, source)
+		} else {
+			err = addToReplicationQueue(obj.FileKey, candidate.ID, source)
+		}
+		if err != nil {
+			return enqueued, err
+		}
+		log.Printf("🧬 Re-replication: %s queued %s -> %s\n", objectKey, source, candidate.ID)
+		enqueued++
+	}
+
+	if enqueued < missing {
+		log.Printf("⚠️ Re-replication: %s still %d copies short, not enough UP nodes or failure domains\n", objectKey, missing-enqueued)
+		return enqueued, nil
+	}
+	return enqueued, markLostCopiesMissing(obj.FileKey, obj.ChunkIndex, holders, lost)
+}
+
+// planShardRebuild mengantrikan rebuild shard erasure yang hanya tersisa di
+// node hilang ke node yang belum menyimpan shard file tersebut (diutamakan
+// failure domain baru). Rekonstruksi dari k shard lain dijalankan replication
+// worker.
+func planShardRebuild(obj underReplicatedObject, nodes []Node, lost map[string]bool, stats *ReReplicationStats) error {
+	record, err := getFileRecord(obj.FileKey)
+	if err != nil || record == nil {
+		return err
+	}
+	params := ErasureParams{
+		DataShards:   record.ECDataShards,
+		ParityShards: record.ECParityShards,
+		BlockSize:    record.ECBlockSize,
+	}
+	shardByNode, err := getShardLocations(obj.FileKey)
+	if err != nil {
+		return err
+	}
+
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	// Seperti salinan replicated, shard di node DOWN yang belum lewat grace
+	// period masih dihitung ada
+	total := params.TotalShards()
+	present := make(map[int]bool)
+	exclude := make(map[string]bool)
+	readable := make(map[int]bool)
+	var kept, lostHolders []string
+	var source string
+	for nodeID, index := range shardByNode {
+		exclude[nodeID] = true
+		if lost[nodeID] {
+			lostHolders = append(lostHolders, nodeID)
+			continue
+		}
+		if index >= total {
+			continue
+		}
+		present[index] = true
+		kept = append(kept, nodeID)
+		if n, ok := nodeByID[nodeID]; ok && n.Status == "UP" {
+			readable[index] = true
+			if source == "" {
+				source = nodeID
+			}
+		}
+	}
+
+	var missing []int
+	for i := 0; i < total; i++ {
+		if present[i] {
+			continue
+		}
+		// Shard yang sedang disalin (mis. oleh drain) tidak dibangun ulang
+		queued, err := getQueuedTargets(obj.FileKey, nil, &i)
+		if err != nil {
+			return err
+		}
+		pending := false
+		for nodeID, status := range queued {
+			exclude[nodeID] = true
+			if status != "DEAD" && !lost[nodeID] {
+				pending = true
+			}
+		}
+		if !pending {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return markLostCopiesMissing(obj.FileKey, nil, lostHolders, lost)
+	}
+
+	if len(readable) < params.DataShards {
+		stats.ErasureUnrecoverable++
+		log.Printf("❌ Re-replication: %s has only %d/%d readable shards, cannot rebuild\n", obj.FileKey, len(readable), params.DataShards)
+		return nil
+	}
+
+	// Source item hanya node shard sehat; worker membaca k shard saat rebuild
+	queued := 0
+	for _, candidate := range selectNodesForUpload(nodes, domainsOf(kept, nodeByID)) {
+		if queued == len(missing) {
+			break
+		}
+		if exclude[candidate.ID] {
+			continue
+		}
+		index := missing[queued]
+		if err := addShardToReplicationQueue(obj.FileKey, index, candidate.ID, source); err != nil {
+			return err
+		}
+		stats.ShardsQueued++
+		log.Printf("🧩 Re-replication: rebuild of shard %d of %s queued on %s\n", index, obj.FileKey, candidate.ID)
+		queued++
+	}
+	if queued < len(missing) {
+		log.Printf("⚠️ Re-replication: %s still %d shards short, not enough UP nodes or failure domains\n", obj.FileKey, len(missing)-queued)
+		return nil
+	}
+	return markLostCopiesMissing(obj.FileKey, nil, lostHolders, lost)
+}
+
+// runReReplicationPass menjalankan satu putaran planner
+func runReReplicationPass() {
+	lost, err := getLostNodes()
+	if err != nil {
+		log.Println("error ambil lost nodes:", err)
+		return
+	}
+
+	stats := &ReReplicationStats{StartedAt: time.Now(), LostNodes: []string{}}
+	for nodeID := range lost {
+		stats.LostNodes = append(stats.LostNodes, nodeID)
+	}
+	sort.Strings(stats.LostNodes)
+	defer func() {
+		now := time.Now()
+		stats.CompletedAt = &now
+		rereplication.mu.Lock()
+		rereplication.lastPass = stats
+		rereplication.mu.Unlock()
+	}()
+
+	if len(lost) == 0 {
+		return
+	}
+
+	objects, err := getObjectsOnLostNodes(lost)
+	if err != nil {
+		log.Println("error cari object under-replicated:", err)
+		return
+	}
+
+	nodes, err := getAllNodes()
+	if err != nil {
+		log.Println("error ambil nodes untuk re-replication:", err)
+		return
+	}
+
+	for _, obj := range objects {
+		stats.ObjectsChecked++
+
+		// Shard erasure tidak bisa disalin dari node lain, harus direkonstruksi
+		if obj.StorageMode == "ERASURE" {
+			stats.ErasureFiles++
+			if err := planShardRebuild(obj, nodes, lost, stats); err != nil {
+				log.Printf("⚠️ Shard rebuild for %s failed: %v\n", obj.FileKey, err)
+			}
+			continue
+		}
+
+		n, err := planReReplication(obj, nodes, lost)
+		if err != nil {
+			log.Printf("⚠️ Re-replication plan for %s failed: %v\n", obj.FileKey, err)
+			continue
+		}
+		stats.JobsQueued += n
+	}
+
+	if stats.JobsQueued > 0 || stats.ShardsQueued > 0 {
+		log.Printf("🧬 Re-replication planner queued %d jobs and %d shard rebuilds for %d lost nodes\n", stats.JobsQueued, stats.ShardsQueued, len(lost))
+	}
+}
+
+func runReplicationPlanner() {
+	if rereplicationInterval <= 0 {
+		log.Println("🧬 Re-replication planner disabled (REREPLICATION_INTERVAL_SECONDS=0)")
+		return
+	}
+
+	ticker := time.NewTicker(rereplicationInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		runReReplicationPass()
+	}
+}
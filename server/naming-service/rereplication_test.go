@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlannerMarksLostCopiesMissing(t *testing.T) {
+	s := newTestBoltStore(t)
+	prev := metadata
+	metadata = s
+	t.Cleanup(func() { metadata = prev })
+
+	rf := 2
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "f", SizeBytes: 1, ReplicationFactor: &rf, NodeIDs: []string{"n1", "n2"}}); err != nil {
+		t.Fatal(err)
+	}
+	params := ErasureParams{DataShards: 2, ParityShards: 1, BlockSize: 64}
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "ec", SizeBytes: 100, NodeIDs: []string{"n1", "n2", "n3"}, Erasure: &params}); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []Node{
+		{ID: "n1", Status: "DOWN"},
+		{ID: "n2", Status: "UP"},
+		{ID: "n3", Status: "UP"},
+		{ID: "n4", Status: "UP"},
+	}
+	lost := map[string]bool{"n1": true}
+
+	objects, err := getObjectsOnLostNodes(lost)
+	if err != nil || len(objects) != 2 {
+		t.Fatalf("getObjectsOnLostNodes = %+v, %v", objects, err)
+	}
+	stats := &ReReplicationStats{}
+	for _, obj := range objects {
+		if obj.StorageMode == "ERASURE" {
+			err = planShardRebuild(obj, nodes, lost, stats)
+		} else {
+			_, err = planReReplication(obj, nodes, lost)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rebuild shard diserahkan ke replication worker, bukan dijalankan planner
+	if stats.ShardsQueued != 1 {
+		t.Errorf("ShardsQueued = %d, want 1", stats.ShardsQueued)
+	}
+	if queued, _ := getQueuedTargets("ec", nil, intPtr(0)); len(queued) != 1 {
+		t.Errorf("queued shard 0 targets = %v", queued)
+	}
+	if queued, _ := getQueuedTargets("f", nil, nil); len(queued) != 1 {
+		t.Errorf("queued f targets = %v", queued)
+	}
+
+	locations, err := s.ListNodeLocations("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"f": "MISSING", shardObjectKey("ec", 0): "MISSING"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("ListNodeLocations(n1) = %v, want %v", locations, want)
+	}
+	// Putaran berikutnya tidak memindai ulang salinan yang sudah ditangani
+	if objects, _ := getObjectsOnLostNodes(lost); len(objects) != 0 {
+		t.Errorf("objects after plan = %+v", objects)
+	}
+}
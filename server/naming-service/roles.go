@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Semantik role node:
+//   - MAIN: target write utama, didahulukan saat memilih node upload
+//   - REPLICA: salinan yang melayani read
+//   - BACKUP: salinan dingin, tidak dipakai untuk read kecuali semua node lain DOWN
+//
+// Hanya ada satu MAIN. Jika MAIN DOWN lebih lama dari grace period (atau
+// sedang drain / sudah RETIRED), REPLICA terbaik dipromosikan otomatis.
+var mainFailoverGrace = time.Duration(getEnvInt("MAIN_FAILOVER_GRACE_SECONDS", 60)) * time.Second
+
+const roleCheckInterval = 10 * time.Second
+
+// writeRoleRank: MAIN dulu, BACKUP terakhir
+func writeRoleRank(role string) int {
+	switch role {
+	case "MAIN":
+		return 0
+	case "BACKUP":
+		return 2
+	}
+	return 1
+}
+
+// readRoleRank: REPLICA dan MAIN setara, BACKUP terakhir
+func readRoleRank(role string) int {
+	if role == "BACKUP" {
+		return 1
+	}
+	return 0
+}
+
+func sortByRole(nodes []Node, rank func(string) int) []Node {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return rank(nodes[i].Role) < rank(nodes[j].Role)
+	})
+	return nodes
+}
+
+// coldBackupsLast memindahkan node BACKUP ke belakang tanpa membuangnya,
+// supaya tetap bisa dipakai failover jika semua salinan lain gagal dibaca
+func coldBackupsLast(nodes []Node) []Node {
+	var hot, cold []Node
+	for _, n := range nodes {
+		if n.Role == "BACKUP" {
+			cold = append(cold, n)
+		} else {
+			hot = append(hot, n)
+		}
+	}
+	return append(hot, cold...)
+}
+
+// promoteNode menjadikan node MAIN dan menurunkan MAIN lama menjadi REPLICA
+func promoteNode(nodeID string) error {
+	return metadata.PromoteNode(nodeID)
+}
+
+// setNodeRole mengubah role satu node. MAIN selalu lewat promoteNode supaya
+// tidak ada dua MAIN.
+func setNodeRole(nodeID, role string) error {
+	if role == "MAIN" {
+		return promoteNode(nodeID)
+	}
+	return metadata.SetNodeRole(nodeID, role)
+}
+
+// mainNeedsFailover true jika tidak ada MAIN yang bisa menerima write:
+// tidak ada MAIN sama sekali, MAIN sedang drain, atau MAIN DOWN melewati grace
+func mainNeedsFailover(nodes []Node, now time.Time) bool {
+	waiting := false
+	for _, n := range nodes {
+		if n.Role != "MAIN" || n.Status == "RETIRED" || n.DrainState == "DRAINING" {
+			continue
+		}
+		if n.Status == "UP" {
+			return false
+		}
+		downSince := n.DownSince
+		if downSince == nil {
+			downSince = n.LastHeartbeat
+		}
+		if downSince == nil || now.Sub(*downSince) < mainFailoverGrace {
+			waiting = true
+		}
+	}
+	return !waiting
+}
+
+// pickPromotionCandidate memilih REPLICA UP terbaik menurut placement policy
+func pickPromotionCandidate(nodes []Node, excludeID string) *Node {
+	var replicas []Node
+	for _, n := range nodes {
+		if n.Role == "REPLICA" && n.Status == "UP" && n.DrainState != "DRAINING" && n.ID != excludeID {
+			replicas = append(replicas, n)
+		}
+	}
+	ranked := rankForWrite(replicas)
+	if len(ranked) == 0 {
+		return nil
+	}
+	return &ranked[0]
+}
+
+// checkMainNode mempromosikan REPLICA jika MAIN tidak tersedia
+func checkMainNode() {
+	nodes, err := getAllNodes()
+	if err != nil {
+		log.Println("error ambil nodes untuk cek MAIN:", err)
+		return
+	}
+	if !mainNeedsFailover(nodes, time.Now()) {
+		return
+	}
+
+	candidate := pickPromotionCandidate(nodes, "")
+	if candidate == nil {
+		return
+	}
+	if err := promoteNode(candidate.ID); err != nil {
+		log.Printf("⚠️ Promote %s to MAIN failed: %v\n", candidate.ID, err)
+		return
+	}
+	log.Printf("👑 MAIN unavailable, promoted %s to MAIN\n", candidate.ID)
+}
+
+func runRoleMonitor() {
+	ticker := time.NewTicker(roleCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		checkMainNode()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMainNeedsFailover(t *testing.T) {
+	grace := mainFailoverGrace
+	mainFailoverGrace = time.Minute
+	t.Cleanup(func() { mainFailoverGrace = grace })
+
+	now := time.Now()
+	recent := now.Add(-10 * time.Second)
+	old := now.Add(-5 * time.Minute)
+
+	tests := []struct {
+		name  string
+		nodes []Node
+		want  bool
+	}{
+		{name: "no nodes", nodes: nil, want: true},
+		{name: "no main", nodes: []Node{{ID: "r1", Role: "REPLICA", Status: "UP"}}, want: true},
+		{name: "main up", nodes: []Node{{ID: "m", Role: "MAIN", Status: "UP"}}, want: false},
+		{name: "main down within grace", nodes: []Node{{ID: "m", Role: "MAIN", Status: "DOWN", DownSince: &recent}}, want: false},
+		{name: "main down past grace", nodes: []Node{{ID: "m", Role: "MAIN", Status: "DOWN", DownSince: &old}}, want: true},
+		{name: "heartbeat used without down_since", nodes: []Node{{ID: "m", Role: "MAIN", Status: "DOWN", LastHeartbeat: &recent}}, want: false},
+		{name: "down without timestamps", nodes: []Node{{ID: "m", Role: "MAIN", Status: "DOWN"}}, want: false},
+		{name: "main draining", nodes: []Node{{ID: "m", Role: "MAIN", Status: "UP", DrainState: "DRAINING"}}, want: true},
+		{name: "main retired", nodes: []Node{{ID: "m", Role: "MAIN", Status: "RETIRED"}}, want: true},
+		{
+			name: "one of two mains up",
+			nodes: []Node{
+				{ID: "m1", Role: "MAIN", Status: "DOWN", DownSince: &old},
+				{ID: "m2", Role: "MAIN", Status: "UP"},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mainNeedsFailover(tt.nodes, now); got != tt.want {
+				t.Errorf("mainNeedsFailover = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleOrdering(t *testing.T) {
+	ids := func(nodes []Node) string {
+		var out string
+		for _, n := range nodes {
+			out += n.ID
+		}
+		return out
+	}
+	nodes := func() []Node {
+		return []Node{{ID: "b", Role: "BACKUP"}, {ID: "r", Role: "REPLICA"}, {ID: "m", Role: "MAIN"}, {ID: "x", Role: "REPLICA"}}
+	}
+
+	if got := ids(sortByRole(nodes(), writeRoleRank)); got != "mrxb" {
+		t.Errorf("write order = %s, want mrxb", got)
+	}
+	if got := ids(sortByRole(nodes(), readRoleRank)); got != "rmxb" {
+		t.Errorf("read order = %s, want rmxb", got)
+	}
+	// BACKUP tetap dipakai sebagai sumber terakhir, juga setelah node unreachable
+	slow := []Node{{ID: "b", Role: "BACKUP"}, {ID: "u", Role: "REPLICA", LatencyMs: unreachableLatencyMs}, {ID: "r", Role: "REPLICA"}}
+	if got := ids(coldBackupsLast(rankForRead(slow))); got != "rub" {
+		t.Errorf("read sources = %s, want rub", got)
+	}
+	if got := ids(coldBackupsLast([]Node{{ID: "b", Role: "BACKUP"}})); got != "b" {
+		t.Errorf("coldBackupsLast(backup only) = %s, want b", got)
+	}
+}
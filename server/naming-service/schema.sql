@@ -1,4 +1,6 @@
 -- Schema untuk Mini Distributed File Storage System
+-- Baseline (migrations/0001_baseline). Kolom dan tabel sesudahnya ditambahkan
+-- oleh migrasi naming service saat startup, jangan ditambahkan di sini.
 
 -- Tabel nodes: menyimpan informasi storage nodes
 CREATE TABLE IF NOT EXISTS nodes (
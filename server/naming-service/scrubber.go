@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scrubber berjalan di background dan memeriksa setiap salinan file yang tercatat
+// di file_locations / chunk_locations: apakah object masih ada di node dan
+// checksum-nya masih sama dengan metadata. Salinan yang rusak ditandai CORRUPT,
+// yang hilang ditandai MISSING, lalu repair diantrikan ke replication_queue.
+var (
+	scrubFilesPerMinute = getEnvInt("SCRUB_FILES_PER_MINUTE", 30)
+	scrubPassInterval   = time.Duration(getEnvInt("SCRUB_PASS_INTERVAL_MINUTES", 60)) * time.Minute
+)
+
+// Menghitung checksum file besar di node bisa lama, jadi timeout-nya longgar
+var scrubClient = &http.Client{Timeout: 10 * time.Minute}
+
+var errObjectMissing = errors.New("object missing on node")
+
+type ScrubStats struct {
+	Pass            int        `json:"pass"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	TotalFiles      int        `json:"total_files"`
+	FilesScanned    int        `json:"files_scanned"`
+	ObjectsChecked  int        `json:"objects_checked"`
+	Corrupt         int        `json:"corrupt"`
+	Missing         int        `json:"missing"`
+	Skipped         int        `json:"skipped"`
+	RepairsEnqueued int        `json:"repairs_enqueued"`
+	Unrepairable    int        `json:"unrepairable"`
+	Cursor          string     `json:"cursor"`
+}
+
+type scrubState struct {
+	mu       sync.Mutex
+	current  *ScrubStats
+	lastPass *ScrubStats
+}
+
+var scrubber scrubState
+
+func (s *scrubState) update(fn func(stats *ScrubStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		fn(s.current)
+	}
+}
+
+// snapshot mengembalikan salinan progress untuk endpoint status
+func (s *scrubState) snapshot() (current, lastPass *ScrubStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		c := *s.current
+		current = &c
+	}
+	if s.lastPass != nil {
+		l := *s.lastPass
+		lastPass = &l
+	}
+	return current, lastPass
+}
+
+// scrubObject menanyakan checksum object ke node. Object yang tidak ada
+// mengembalikan errObjectMissing, error lain berarti node tidak bisa dicek.
+func scrubObject(node Node, objectKey string) (string, int64, error) {
+	resp, err := scrubClient.Get(fmt.Sprintf("%s/files/%s/checksum", node.Address, objectKey))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", 0, errObjectMissing
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("node return status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ChecksumSHA256 string `json:"checksum_sha256"`
+		SizeBytes      int64  `json:"size_bytes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("gagal parse response: %v", err)
+	}
+	return result.ChecksumSHA256, result.SizeBytes, nil
+}
+
+// checkCopy membandingkan satu salinan dengan checksum/ukuran yang diharapkan.
+// Mengembalikan status baru untuk lokasi: ACTIVE, CORRUPT, MISSING, atau "" jika
+// node tidak bisa dicek (DOWN / error jaringan).
+func checkCopy(node Node, objectKey, expectedChecksum string, expectedSize int64) string {
+	if node.Status != "UP" {
+		return ""
+	}
+
+	checksum, size, err := scrubObject(node, objectKey)
+	if errors.Is(err, errObjectMissing) {
+		return "MISSING"
+	}
+	if err != nil {
+		log.Printf("⚠️ Scrub %s on %s failed: %v\n", objectKey, node.ID, err)
+		return ""
+	}
+
+	if size != expectedSize || (expectedChecksum != "" && !strings.EqualFold(checksum, expectedChecksum)) {
+		return "CORRUPT"
+	}
+	return "ACTIVE"
+}
+
+func markLocationStatus(fileKey string, chunkIndex *int, nodeID, status string) error {
+	return metadata.UpdateLocationStatus(fileKey, chunkIndex, nodeID, status)
+}
+
+// hasPendingReplication mengecek apakah repair yang sama sudah ada di antrian
+// (item FAILED ikut dihitung karena masih akan dicoba ulang)
+func hasPendingReplication(fileKey string, chunkIndex *int, targetNodeID string) (bool, error) {
+	queued, err := getQueuedTargets(fileKey, chunkIndex, nil)
+	if err != nil {
+		return false, err
+	}
+	status, ok := queued[targetNodeID]
+	return ok && status != "DEAD", nil
+}
+
+// scrubCopies memeriksa semua salinan satu object (file utuh atau satu chunk),
+// menandai salinan yang rusak/hilang dan mengantrikan repair dari salinan sehat
+func scrubCopies(fileKey string, chunkIndex *int, objectKey, expectedChecksum string, expectedSize int64, nodeIDs []string, nodeByID map[string]Node) {
+	var healthy []string
+	var broken []string
+
+	for _, nodeID := range nodeIDs {
+		node, ok := nodeByID[nodeID]
+		if !ok {
+			continue
+		}
+
+		status := checkCopy(node, objectKey, expectedChecksum, expectedSize)
+		scrubber.update(func(stats *ScrubStats) {
+			stats.ObjectsChecked++
+			switch status {
+			case "":
+				stats.Skipped++
+			case "CORRUPT":
+				stats.Corrupt++
+			case "MISSING":
+				stats.Missing++
+			}
+		})
+
+		switch status {
+		case "ACTIVE":
+			healthy = append(healthy, nodeID)
+		case "CORRUPT", "MISSING":
+			log.Printf("🩺 Scrub: %s on %s is %s\n", objectKey, nodeID, status)
+			if err := markLocationStatus(fileKey, chunkIndex, nodeID, status); err != nil {
+				log.Println("error update location status:", err)
+				continue
+			}
+			broken = append(broken, nodeID)
+		}
+	}
+
+	for _, target := range broken {
+		if len(healthy) == 0 {
+			log.Printf("❌ Scrub: no healthy copy of %s to repair %s\n", objectKey, target)
+			scrubber.update(func(stats *ScrubStats) { stats.Unrepairable++ })
+			continue
+		}
+
+		pending, err := hasPendingReplication(fileKey, chunkIndex, target)
+		if err != nil || pending {
+			continue
+		}
+
+		if chunkIndex != nil {
+			err = addChunkToReplicationQueue(fileKey, *chunkIndex, target, healthy[0])
+		} else {
+			err = addToReplicationQueue(fileKey, target, healthy[0])
+		}
+		if err != nil {
+			log.Println("error enqueue repair:", err)
+			continue
+		}
+		log.Printf("🔧 Scrub: repair %s on %s queued from %s\n", objectKey, target, healthy[0])
+		scrubber.update(func(stats *ScrubStats) { stats.RepairsEnqueued++ })
+	}
+}
+
+// scrubFile memeriksa semua salinan satu file sesuai storage_mode-nya
+func scrubFile(fileKey string, nodeByID map[string]Node) error {
+	record, err := getFileRecord(fileKey)
+	if err != nil || record == nil {
+		return err
+	}
+
+	switch record.StorageMode {
+	case "CHUNKED":
+		chunks, err := getChunkManifest(fileKey)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			index := chunk.ChunkIndex
+			scrubCopies(fileKey, &index, chunkObjectKey(fileKey, index), chunk.ChecksumSHA256, chunk.SizeBytes, chunk.Locations, nodeByID)
+		}
+
+	case "ERASURE":
+		// Checksum per shard tidak disimpan, jadi yang dicek hanya keberadaan
+		// dan ukuran shard. Shard tidak punya salinan identik: shard yang rusak
+		// dibangun ulang oleh replication worker dari k shard lain.
+		params := ErasureParams{DataShards: record.ECDataShards, ParityShards: record.ECParityShards, BlockSize: record.ECBlockSize}
+		shardByNode, err := getShardLocations(fileKey)
+		if err != nil {
+			return err
+		}
+		var healthy []string
+		broken := make(map[string]int)
+		for nodeID, index := range shardByNode {
+			node, ok := nodeByID[nodeID]
+			if !ok {
+				continue
+			}
+			status := checkCopy(node, shardObjectKey(fileKey, index), "", erasureShardSize(record.SizeBytes, params))
+			scrubber.update(func(stats *ScrubStats) {
+				stats.ObjectsChecked++
+				switch status {
+				case "":
+					stats.Skipped++
+				case "CORRUPT":
+					stats.Corrupt++
+				case "MISSING":
+					stats.Missing++
+				}
+			})
+			switch status {
+			case "ACTIVE":
+				healthy = append(healthy, nodeID)
+			case "CORRUPT", "MISSING":
+				log.Printf("🩺 Scrub: shard %d of %s on %s is %s\n", index, fileKey, nodeID, status)
+				if err := markLocationStatus(fileKey, nil, nodeID, status); err != nil {
+					log.Println("error update location status:", err)
+					continue
+				}
+				broken[nodeID] = index
+			}
+		}
+
+		for target, index := range broken {
+			if len(healthy) < params.DataShards {
+				log.Printf("❌ Scrub: only %d healthy shards of %s, cannot rebuild shard %d\n", len(healthy), fileKey, index)
+				scrubber.update(func(stats *ScrubStats) { stats.Unrepairable++ })
+				continue
+			}
+
+			queued, err := getQueuedTargets(fileKey, nil, &index)
+			if err != nil {
+				log.Println("error cek replication queue:", err)
+				continue
+			}
+			if status, ok := queued[target]; ok && status != "DEAD" {
+				continue
+			}
+
+			// Shard ditulis ulang di node yang sama supaya sebaran failure domain
+			// tetap; source hanya node shard sehat, rebuild membaca k shard
+			if err := addShardToReplicationQueue(fileKey, index, target, healthy[0]); err != nil {
+				log.Println("error enqueue repair:", err)
+				continue
+			}
+			log.Printf("🔧 Scrub: rebuild of shard %d of %s on %s queued\n", index, fileKey, target)
+			scrubber.update(func(stats *ScrubStats) { stats.RepairsEnqueued++ })
+		}
+
+	default:
+		nodeIDs, err := getFileLocations(fileKey)
+		if err != nil {
+			return err
+		}
+		scrubCopies(fileKey, nil, fileKey, record.ChecksumSHA256, record.SizeBytes, nodeIDs, nodeByID)
+	}
+
+	return nil
+}
+
+// nextFileKey mengembalikan file_key berikutnya setelah cursor, "" jika sudah habis
+func nextFileKey(cursor string) (string, error) {
+	return metadata.NextFileKey(cursor)
+}
+
+// runScrubber menelusuri tabel files berurutan file_key dengan kecepatan
+// SCRUB_FILES_PER_MINUTE. Setelah satu putaran selesai, putaran berikutnya
+// dimulai SCRUB_PASS_INTERVAL_MINUTES kemudian.
+func runScrubber() {
+	if scrubFilesPerMinute <= 0 {
+		log.Println("🩺 Scrubber disabled (SCRUB_FILES_PER_MINUTE=0)")
+		return
+	}
+	delay := time.Minute / time.Duration(scrubFilesPerMinute)
+
+	for pass := 1; ; pass++ {
+		waitUntilLeader()
+
+		total, err := metadata.CountFiles()
+		if err != nil {
+			log.Println("error hitung files untuk scrub:", err)
+		}
+
+		scrubber.mu.Lock()
+		scrubber.current = &ScrubStats{Pass: pass, StartedAt: time.Now(), TotalFiles: total}
+		scrubber.mu.Unlock()
+		log.Printf("🩺 Scrub pass %d started (%d files)\n", pass, total)
+
+		cursor := ""
+		for {
+			// Pass yang terputus karena kehilangan leadership dilanjutkan dari cursor
+			waitUntilLeader()
+
+			fileKey, err := nextFileKey(cursor)
+			if err != nil {
+				log.Println("error ambil file untuk scrub:", err)
+				time.Sleep(delay)
+				continue
+			}
+			if fileKey == "" {
+				break
+			}
+
+			nodes, err := getAllNodes()
+			if err != nil {
+				log.Println("error ambil nodes untuk scrub:", err)
+				time.Sleep(delay)
+				continue
+			}
+			nodeByID := make(map[string]Node)
+			for _, n := range nodes {
+				nodeByID[n.ID] = n
+			}
+
+			if err := scrubFile(fileKey, nodeByID); err != nil {
+				log.Printf("⚠️ Scrub %s failed: %v\n", fileKey, err)
+			}
+
+			cursor = fileKey
+			scrubber.update(func(stats *ScrubStats) {
+				stats.FilesScanned++
+				stats.Cursor = cursor
+			})
+			time.Sleep(delay)
+		}
+
+		scrubber.mu.Lock()
+		now := time.Now()
+		scrubber.current.CompletedAt = &now
+		scrubber.lastPass = scrubber.current
+		scrubber.current = nil
+		stats := *scrubber.lastPass
+		scrubber.mu.Unlock()
+
+		log.Printf("🩺 Scrub pass %d done: %d files, %d corrupt, %d missing, %d repairs queued\n",
+			pass, stats.FilesScanned, stats.Corrupt, stats.Missing, stats.RepairsEnqueued)
+
+		time.Sleep(scrubPassInterval)
+	}
+}
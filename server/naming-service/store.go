@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// MetadataStore menyimpan semua metadata naming service: nodes, files,
+// file_locations, chunk, upload session dan replication_queue. Backend dipilih
+// lewat METADATA_STORE:
+//   - mysql (default): tabel dari migrations/, dijalankan saat startup
+//   - bolt: file bbolt lokal (METADATA_BOLT_PATH), untuk deployment kecil atau
+//     development tanpa MySQL server
+//
+// Hanya leader election (tabel leader_lease) yang query langsung ke db: backend
+// bolt selalu single instance sehingga tidak butuh election.
+type MetadataStore interface {
+	Name() string
+	Close() error
+
+	// nodes
+	ListNodes() ([]Node, error)
+	GetNode(nodeID string) (*Node, error)
+	// UpsertNode membuat node baru atau mengaktifkan ulang node RETIRED
+	UpsertNode(nodeID, address, role, zone, rack string) error
+	// UpdateNode mengembalikan errNodeNotFound jika node tidak ada atau RETIRED
+	UpdateNode(nodeID, address, role, zone, rack string) error
+	SetNodeStatus(nodeID, status string) error
+	SetNodeLatency(nodeID string, latencyMs int64) error
+	SetNodeRole(nodeID, role string) error
+	// PromoteNode menjadikan node MAIN dan MAIN lama REPLICA secara atomik
+	PromoteNode(nodeID string) error
+	RecordHeartbeat(nodeID string, report HeartbeatReport, lease time.Duration) error
+	// ExpireLeases menandai DOWN node UP yang lease heartbeat-nya habis
+	ExpireLeases() ([]string, error)
+	RetireNode(nodeID string) error
+	CountActiveLocationsOnNode(nodeID string) (int, error)
+
+	// files dan file_locations
+	// RegisterFile menyimpan files, file_versions, file_locations (termasuk
+	// manifest EC / chunk) dan replication_queue dalam satu transaksi.
+	// Idempotency key yang sudah pernah dipakai untuk file yang sama tidak
+	// mengubah apa pun dan mengembalikan replayed=true.
+	RegisterFile(reg FileRegistration) (replayed bool, err error)
+	PruneIdempotencyKeys(olderThan time.Duration) (int64, error)
+	// GetFile mengembalikan nil jika file tidak ada. Replicas tidak diisi.
+	GetFile(fileKey string) (*FileMetadata, error)
+	ListFiles(limit int) ([]FileMetadata, error)
+	// DeleteFile menghapus file beserta lokasi dan antrian replikasinya, dan
+	// menyimpan tombstone blob yang belum terhapus dalam transaksi yang sama
+	DeleteFile(fileKey string, tombstones []DeleteTombstone) error
+	GetFileLocations(fileKey string) ([]string, error)
+	SetFileLocationStatus(fileKey, nodeID, status string) error
+	// UpdateLocationStatus mengubah status salinan yang sudah tercatat (file
+	// utuh / shard jika chunkIndex nil, selain itu chunk); salinan yang tidak
+	// ada diabaikan
+	UpdateLocationStatus(fileKey string, chunkIndex *int, nodeID, status string) error
+	// ListNodeLocations: blob key (object, shard, chunk atau part multipart
+	// yang belum selesai) -> status semua salinan di satu node, dipakai orphan GC
+	ListNodeLocations(nodeID string) (map[string]string, error)
+	// ListObjectCopies mengembalikan salinan ACTIVE file utuh, shard dan chunk.
+	// nodeID kosong = semua node.
+	ListObjectCopies(nodeID string) ([]ObjectCopy, error)
+	// ListObjectLocations mengembalikan semua salinan satu object yang masih
+	// tercatat (file utuh, shard dan chunk) apa pun statusnya, dipakai delete
+	ListObjectLocations(fileKey string) ([]ObjectLocation, error)
+	// NextFileKey: file_key berikutnya setelah cursor (urut), "" jika habis
+	NextFileKey(cursor string) (string, error)
+	CountFiles() (int, error)
+
+	// file_chunks dan chunk_locations (storage_mode CHUNKED), manifest-nya
+	// disimpan oleh RegisterFile (FileRegistration.Chunks)
+	//
+	// GetChunkManifest urut chunk_index, Locations hanya yang ACTIVE
+	GetChunkManifest(fileKey string) ([]FileChunk, error)
+	// GetChunk mengembalikan nil jika chunk tidak ada
+	GetChunk(fileKey string, chunkIndex int) (*FileChunk, error)
+	SetChunkLocationActive(fileKey string, chunkIndex int, nodeID string) error
+	// GetChunkedFileNodes: node yang menyimpan minimal satu chunk ACTIVE
+	GetChunkedFileNodes(fileKey string) ([]string, error)
+
+	// erasure coding: shard dicatat di file_locations dengan index shard-nya,
+	// manifest-nya disimpan oleh RegisterFile (FileRegistration.Erasure)
+	SetShardLocationActive(fileKey string, shardIndex int, nodeID string) error
+	// GetShardLocations: node -> index shard ACTIVE
+	GetShardLocations(fileKey string) (map[string]int, error)
+
+	// upload_sessions dan upload_parts (multipart upload)
+	CreateUploadSession(uploadID, fileKey, originalFilename string, replicationFactor int, ttl time.Duration) error
+	// GetUploadSession mengembalikan errUploadSessionNotFound jika tidak ada;
+	// Parts urut part_number
+	GetUploadSession(uploadID string) (*UploadSession, error)
+	// RecordUploadPart: lihat recordPart
+	RecordUploadPart(uploadID string, part UploadPart, ttl time.Duration) ([]string, error)
+	// SetUploadSessionStatus: lihat setUploadSessionStatus
+	SetUploadSessionStatus(uploadID, from, to string) error
+	DeleteUploadParts(uploadID string) error
+	// ListExpiredUploadSessions: session ACTIVE/COMPLETING yang melewati TTL
+	// (hanya UploadID, FileKey dan Status yang diisi)
+	ListExpiredUploadSessions() ([]UploadSession, error)
+
+	// drain
+	//
+	// StartDrain menandai node DRAINING dengan jumlah object awal
+	StartDrain(nodeID string, totalObjects int) error
+	// CancelDrain mengembalikan errNodeNotDraining jika node tidak sedang drain
+	CancelDrain(nodeID string) error
+	// FinishDrain menandai node yang sudah di-retire sebagai DECOMMISSIONED
+	FinishDrain(nodeID string) error
+	// GetDrainStatus mengisi state, waktu mulai, total object dan hitungan
+	// replikasi dari node sejak drain dimulai; sisa object dihitung pemanggil
+	GetDrainStatus(nodeID string) (*DrainStatus, error)
+	// ListLostNodes: node yang sudah DOWN lebih lama dari grace (node lama tanpa
+	// down_since memakai last_heartbeat)
+	ListLostNodes(grace time.Duration) ([]string, error)
+
+	// file_versions. Key di files / file_locations adalah object key, satu per
+	// versi; ListFiles hanya mengembalikan versi terbaru per file_key.
+	// ListFileVersions urut dari versi terbaru, nil jika file_key tidak ada
+	ListFileVersions(fileKey string) ([]FileVersion, error)
+
+	// trash (soft delete) per object; object di trash tetap direplikasi dan
+	// di-scrub sampai di-purge. Upload ulang ke object yang sama me-restore-nya.
+	TrashFile(objectKey string) error
+	RestoreFile(objectKey string) error
+	ListTrash(limit int) ([]FileVersion, error)
+	// ListExpiredTrash: object yang sudah di trash lebih lama dari olderThan
+	ListExpiredTrash(olderThan time.Duration, limit int) ([]string, error)
+
+	// delete_tombstones; nodeID kosong = semua node
+	ListTombstones(nodeID string, limit int) ([]DeleteTombstone, error)
+	DeleteTombstone(id int) error
+	FailTombstone(id int, errorMsg string) error
+
+	// replication_queue
+	EnqueueReplication(fileKey string, chunkIndex, shardIndex *int, targetNodeID, sourceNodeID string) error
+	ListReplications(targetNodeID, status string, limit int) ([]ReplicationQueueItem, error)
+	// LeaseReplications: lihat leaseReplications
+	LeaseReplications(owner, targetNodeID string, limit int, lease time.Duration) ([]ReplicationQueueItem, error)
+	ExtendReplicationLease(queueID int, owner string, lease time.Duration) error
+	CompleteReplication(queueID int) error
+	// FailReplication mencatat kegagalan dan menjadwalkan retry setelah retryAfter
+	FailReplication(queueID int, status, errorMsg string, retryAfter time.Duration) error
+	ReclaimExpiredReplications() (int64, error)
+	ResetReplicationBackoff(nodeID string) error
+	RetryDeadReplication(queueID int) (bool, error)
+	// ListQueuedTargets: lihat getQueuedTargets
+	ListQueuedTargets(fileKey string, chunkIndex, shardIndex *int) (map[string]string, error)
+	// CancelReplicationsToNode memindahkan item PENDING/FAILED ke node ke DEAD
+	CancelReplicationsToNode(nodeID, reason string) error
+}
+
+// ObjectCopy adalah satu salinan ACTIVE di satu node: file utuh, satu chunk
+// (ChunkIndex) atau satu shard erasure (ShardIndex)
+type ObjectCopy struct {
+	NodeID      string
+	FileKey     string
+	ChunkIndex  *int
+	ShardIndex  *int
+	StorageMode string
+	// Ukuran file utuh, atau ukuran chunk untuk salinan chunk
+	SizeBytes         int64
+	ReplicationFactor *int
+}
+
+// ObjectLocation adalah satu baris lokasi: blob key di satu node beserta
+// statusnya (ACTIVE, CORRUPT, MISSING atau DELETED)
+type ObjectLocation struct {
+	NodeID  string
+	BlobKey string
+	Status  string
+}
+
+// target adalah jumlah salinan yang diinginkan untuk file / chunk
+func (o ObjectCopy) target() int {
+	if o.ReplicationFactor != nil {
+		return *o.ReplicationFactor
+	}
+	return defaultReplicationFactor
+}
+
+// errIdempotencyKeyReused: key yang sama dipakai untuk file_key lain
+var errIdempotencyKeyReused = errors.New("idempotency key sudah dipakai untuk file lain")
+
+// FileRegistration adalah satu upload yang didaftarkan sekaligus: row files,
+// versi, lokasi dan manifest (EC) dalam satu transaksi
+type FileRegistration struct {
+	FileKey string
+	// Object yang disimpan di node; kosong = FileKey. Object berbeda dari versi
+	// terbaru menjadi versi baru FileKey.
+	ObjectKey         string
+	OriginalFilename  string
+	SizeBytes         int64
+	Checksum          string
+	ReplicationFactor *int
+	// Node yang sudah menyimpan file (file_locations ACTIVE). Untuk upload
+	// EC, NodeIDs[i] menyimpan shard ke-i.
+	NodeIDs []string
+	// Tidak nil = file ERASURE dengan parameter ini
+	Erasure *ErasureParams
+	// Tidak kosong = file CHUNKED; lokasi diambil dari setiap chunk
+	Chunks         []FileChunk
+	ChunkSizeBytes int64
+	// Node yang gagal menerima salinan, diantrikan dari SourceNodeID
+	FailedNodeIDs  []string
+	SourceNodeID   string
+	IdempotencyKey string
+}
+
+func (reg FileRegistration) objectKey() string {
+	if reg.ObjectKey != "" {
+		return reg.ObjectKey
+	}
+	return reg.FileKey
+}
+
+var metadata MetadataStore
+
+// Idempotency key disimpan cukup lama untuk menutup retry storage node
+var idempotencyKeyTTL = time.Duration(getEnvInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)) * time.Hour
+
+func initMetadataStore() {
+	switch backend := strings.ToLower(getEnv("METADATA_STORE", "mysql")); backend {
+	case "mysql":
+		store := openMySQLStore()
+		// Skema disamakan dengan migrasi yang di-embed sebelum service jalan
+		if getEnv("MIGRATE_ON_START", "true") == "true" {
+			applied, err := store.migrateUp()
+			if err != nil {
+				log.Fatalf("gagal menjalankan migrasi: %v", err)
+			}
+			if applied > 0 {
+				log.Printf("✅ %d migration(s) applied\n", applied)
+			}
+		}
+		metadata = store
+	case "bolt", "embedded":
+		path := getEnv("METADATA_BOLT_PATH", "naming-service.db")
+		store, err := openBoltStore(path)
+		if err != nil {
+			log.Fatalf("gagal buka metadata store %s: %v", path, err)
+		}
+		metadata = store
+		log.Printf("Metadata disimpan di %s (bolt), single instance\n", path)
+	default:
+		log.Fatalf("METADATA_STORE=%q tidak dikenal (mysql atau bolt)", backend)
+	}
+}
+
+func runIdempotencyKeyGC() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		pruned, err := metadata.PruneIdempotencyKeys(idempotencyKeyTTL)
+		if err != nil {
+			log.Println("error prune idempotency keys:", err)
+			continue
+		}
+		if pruned > 0 {
+			log.Printf("🧹 Pruned %d expired idempotency keys\n", pruned)
+		}
+	}
+}
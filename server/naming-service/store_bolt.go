@@ -0,0 +1,1952 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore adalah MetadataStore embedded di satu file bbolt. Setiap tabel
+// menjadi bucket berisi JSON; semua tulis berjalan dalam transaksi bbolt yang
+// serial, jadi lease replikasi tidak perlu SKIP LOCKED. File hanya bisa dibuka
+// satu proses sehingga backend ini untuk single instance saja.
+type boltStore struct {
+	db *bolt.DB
+}
+
+var (
+	boltNodes        = []byte("nodes")
+	boltFiles        = []byte("files")
+	boltLocations    = []byte("file_locations")
+	boltReplications = []byte("replication_queue")
+	boltIdempotency  = []byte("idempotency_keys")
+	boltVersions     = []byte("file_versions")
+	boltTombstones   = []byte("delete_tombstones")
+	// locationKey -> index shard untuk lokasi file ERASURE
+	boltShards         = []byte("file_location_shards")
+	boltChunks         = []byte("file_chunks")
+	boltChunkLocations = []byte("chunk_locations")
+	boltUploadSessions = []byte("upload_sessions")
+	boltUploadParts    = []byte("upload_parts")
+	// Progress drain per node (drain_started_at / drain_total_objects)
+	boltDrains = []byte("node_drains")
+)
+
+// boltFileVersion: key bucket file_versions adalah versionKey(file_key, version)
+type boltFileVersion struct {
+	ObjectKey string    `json:"object_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type boltIdempotencyKey struct {
+	FileKey   string    `json:"file_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// boltFile adalah row files
+type boltFile struct {
+	FileMetadata
+	ReplicationFactor *int  `json:"replication_factor,omitempty"`
+	ChunkSizeBytes    int64 `json:"chunk_size_bytes,omitempty"`
+	// FileMetadata.ECBlockSize tidak ikut di-encode ke JSON
+	ErasureBlockSize int64 `json:"ec_block_size,omitempty"`
+}
+
+func (f *boltFile) fileMetadata() *FileMetadata {
+	f.ECBlockSize = f.ErasureBlockSize
+	return &f.FileMetadata
+}
+
+// boltChunk: key bucket file_chunks adalah indexKey(file_key, chunk_index),
+// lokasinya di chunk_locations dengan key chunkLocationKey
+type boltChunk struct {
+	SizeBytes      int64  `json:"size_bytes"`
+	ChecksumSHA256 string `json:"checksum_sha256"`
+}
+
+type boltUploadSession struct {
+	FileKey           string    `json:"file_key"`
+	OriginalFilename  string    `json:"original_filename"`
+	ReplicationFactor int       `json:"replication_factor"`
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// boltUploadPart: key bucket upload_parts adalah indexKey(upload_id, part_number)
+type boltUploadPart struct {
+	SizeBytes      int64     `json:"size_bytes"`
+	ChecksumSHA256 string    `json:"checksum_sha256"`
+	NodeIDs        []string  `json:"node_ids"`
+	UploadedAt     time.Time `json:"uploaded_at"`
+}
+
+type boltDrain struct {
+	StartedAt    time.Time `json:"started_at"`
+	TotalObjects int       `json:"total_objects"`
+}
+
+func openBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltNodes, boltFiles, boltLocations, boltReplications, boltIdempotency, boltVersions, boltTombstones,
+			boltShards, boltChunks, boltChunkLocations, boltUploadSessions, boltUploadParts, boltDrains} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Name() string { return "bolt" }
+
+func (s *boltStore) Close() error { return s.db.Close() }
+
+func getJSON(b *bolt.Bucket, key []byte, v interface{}) (bool, error) {
+	data := b.Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func putJSON(b *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// locationKey: file_key lalu node_id, supaya lokasi satu file bisa di-scan per prefix
+func locationKey(fileKey, nodeID string) []byte {
+	return []byte(fileKey + "\x00" + nodeID)
+}
+
+func locationPrefix(fileKey string) []byte {
+	return []byte(fileKey + "\x00")
+}
+
+// splitLocationKey memecah locationKey; file_key dan object key tidak pernah
+// berisi \x00
+func splitLocationKey(k []byte) (string, string) {
+	i := bytes.IndexByte(k, 0)
+	return string(k[:i]), string(k[i+1:])
+}
+
+// indexKey: prefix lalu index big-endian, supaya urutan cursor = urutan index
+func indexKey(prefix string, index int) []byte {
+	key := make([]byte, len(prefix)+1, len(prefix)+5)
+	copy(key, prefix)
+	return binary.BigEndian.AppendUint32(key, uint32(index))
+}
+
+// versionKey: file_key lalu versi
+func versionKey(fileKey string, version int) []byte {
+	return indexKey(fileKey, version)
+}
+
+// chunkLocationKey: indexKey(file_key, chunk_index) lalu node_id, supaya lokasi
+// satu chunk dan satu file bisa di-scan per prefix
+func chunkLocationKey(fileKey string, chunkIndex int, nodeID string) []byte {
+	return append(indexKey(fileKey, chunkIndex), nodeID...)
+}
+
+func splitChunkLocationKey(k []byte) (string, int, string) {
+	i := bytes.IndexByte(k, 0)
+	return string(k[:i]), int(binary.BigEndian.Uint32(k[i+1 : i+5])), string(k[i+5:])
+}
+
+// deletePrefix menghapus semua key dengan prefix tertentu. Delete di tengah
+// iterasi cursor menggeser posisi, jadi key dikumpulkan dulu.
+func deletePrefix(b *bolt.Bucket, prefix []byte) error {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queueKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// updateNode membaca satu node, menjalankan fn, lalu menyimpannya kembali
+func (s *boltStore) updateNode(nodeID string, fn func(n *Node) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		var n Node
+		found, err := getJSON(b, []byte(nodeID), &n)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errNodeNotFound
+		}
+		if err := fn(&n); err != nil {
+			return err
+		}
+		return putJSON(b, []byte(nodeID), n)
+	})
+}
+
+// updateQueueItem menjalankan fn untuk satu item; fn mengembalikan false jika
+// item tidak perlu diubah (setara UPDATE ... WHERE yang tidak match)
+func (s *boltStore) updateQueueItem(queueID int, fn func(item *ReplicationQueueItem) bool) (bool, error) {
+	changed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltReplications)
+		var item ReplicationQueueItem
+		found, err := getJSON(b, queueKey(queueID), &item)
+		if err != nil || !found || !fn(&item) {
+			return err
+		}
+		changed = true
+		return putJSON(b, queueKey(queueID), item)
+	})
+	return changed, err
+}
+
+// updateQueueItems menjalankan fn untuk semua item dan menyimpan yang berubah
+func (s *boltStore) updateQueueItems(fn func(item *ReplicationQueueItem) bool) (int64, error) {
+	var changed []ReplicationQueueItem
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltReplications)
+		// Bucket tidak boleh diubah di dalam ForEach, jadi simpan setelahnya
+		err := b.ForEach(func(_, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if fn(&item) {
+				changed = append(changed, item)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range changed {
+			if err := putJSON(b, queueKey(item.ID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return int64(len(changed)), err
+}
+
+func (s *boltStore) ListNodes() ([]Node, error) {
+	var nodes []Node
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltNodes).ForEach(func(_, v []byte) error {
+			var n Node
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			nodes = append(nodes, n)
+			return nil
+		})
+	})
+	return nodes, err
+}
+
+func (s *boltStore) GetNode(nodeID string) (*Node, error) {
+	var n Node
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = getJSON(tx.Bucket(boltNodes), []byte(nodeID), &n)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errNodeNotFound
+	}
+	return &n, nil
+}
+
+func (s *boltStore) UpsertNode(nodeID, address, role, zone, rack string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		var n Node
+		if _, err := getJSON(b, []byte(nodeID), &n); err != nil {
+			return err
+		}
+		// Sama seperti ON DUPLICATE KEY UPDATE: heartbeat dan kapasitas lama dipertahankan
+		n.ID, n.Address, n.Status, n.Role, n.Zone, n.Rack = nodeID, address, "DOWN", role, zone, rack
+		n.LatencyMs = 0
+		n.LeaseExpiresAt = nil
+		n.DrainState = "NONE"
+		if err := tx.Bucket(boltDrains).Delete([]byte(nodeID)); err != nil {
+			return err
+		}
+		return putJSON(b, []byte(nodeID), n)
+	})
+}
+
+func (s *boltStore) UpdateNode(nodeID, address, role, zone, rack string) error {
+	return s.updateNode(nodeID, func(n *Node) error {
+		if n.Status == "RETIRED" {
+			return errNodeNotFound
+		}
+		n.Address, n.Role, n.Zone, n.Rack = address, role, zone, rack
+		return nil
+	})
+}
+
+func (s *boltStore) SetNodeStatus(nodeID, status string) error {
+	err := s.updateNode(nodeID, func(n *Node) error {
+		now := time.Now()
+		n.Status = status
+		n.LastHeartbeat = &now
+		if status != "DOWN" {
+			n.DownSince = nil
+		} else if n.DownSince == nil {
+			n.DownSince = &now
+		}
+		return nil
+	})
+	// UPDATE tanpa row yang match bukan error
+	if err == errNodeNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *boltStore) SetNodeLatency(nodeID string, latencyMs int64) error {
+	err := s.updateNode(nodeID, func(n *Node) error {
+		n.LatencyMs = latencyMs
+		return nil
+	})
+	if err == errNodeNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *boltStore) SetNodeRole(nodeID, role string) error {
+	return s.updateNode(nodeID, func(n *Node) error {
+		// Node RETIRED tetap ada tapi role-nya tidak diubah
+		if n.Status != "RETIRED" {
+			n.Role = role
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) PromoteNode(nodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		var target Node
+		found, err := getJSON(b, []byte(nodeID), &target)
+		if err != nil {
+			return err
+		}
+		if !found || target.Status == "RETIRED" {
+			return errNodeNotFound
+		}
+
+		var demoted []Node
+		err = b.ForEach(func(k, v []byte) error {
+			var n Node
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			if n.Role == "MAIN" && n.ID != nodeID {
+				n.Role = "REPLICA"
+				demoted = append(demoted, n)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, n := range demoted {
+			if err := putJSON(b, []byte(n.ID), n); err != nil {
+				return err
+			}
+		}
+
+		target.Role = "MAIN"
+		return putJSON(b, []byte(nodeID), target)
+	})
+}
+
+func (s *boltStore) RecordHeartbeat(nodeID string, report HeartbeatReport, lease time.Duration) error {
+	return s.updateNode(nodeID, func(n *Node) error {
+		now := time.Now()
+		expires := now.Add(lease)
+		n.Status = "UP"
+		n.DownSince = nil
+		n.LastHeartbeat = &now
+		n.LeaseExpiresAt = &expires
+		if report.CapacityBytes != nil {
+			n.CapacityBytes = report.CapacityBytes
+		}
+		if report.FreeBytes != nil {
+			n.FreeBytes = report.FreeBytes
+		}
+		if report.InflightRequests != nil {
+			n.InflightRequests = report.InflightRequests
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) ExpireLeases() ([]string, error) {
+	var expired []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		now := time.Now()
+		var nodes []Node
+		err := b.ForEach(func(_, v []byte) error {
+			var n Node
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			if n.Status == "UP" && n.LeaseExpiresAt != nil && n.LeaseExpiresAt.Before(now) {
+				nodes = append(nodes, n)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, n := range nodes {
+			n.Status = "DOWN"
+			n.LeaseExpiresAt = nil
+			if n.DownSince == nil {
+				n.DownSince = &now
+			}
+			if err := putJSON(b, []byte(n.ID), n); err != nil {
+				return err
+			}
+			expired = append(expired, n.ID)
+		}
+		return nil
+	})
+	return expired, err
+}
+
+func (s *boltStore) RetireNode(nodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		nodes := tx.Bucket(boltNodes)
+		var n Node
+		found, err := getJSON(nodes, []byte(nodeID), &n)
+		if err != nil || !found {
+			return err
+		}
+		n.Status = "RETIRED"
+		n.LeaseExpiresAt = nil
+		if err := putJSON(nodes, []byte(nodeID), n); err != nil {
+			return err
+		}
+
+		locations := tx.Bucket(boltLocations)
+		var deleted [][]byte
+		err = locations.ForEach(func(k, v []byte) error {
+			if string(v) == "ACTIVE" && bytes.HasSuffix(k, []byte("\x00"+nodeID)) {
+				deleted = append(deleted, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range deleted {
+			if err := locations.Put(k, []byte("DELETED")); err != nil {
+				return err
+			}
+		}
+
+		chunkLocations := tx.Bucket(boltChunkLocations)
+		deleted = nil
+		err = chunkLocations.ForEach(func(k, v []byte) error {
+			if _, _, holder := splitChunkLocationKey(k); holder == nodeID && string(v) == "ACTIVE" {
+				deleted = append(deleted, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range deleted {
+			if err := chunkLocations.Put(k, []byte("DELETED")); err != nil {
+				return err
+			}
+		}
+
+		queue := tx.Bucket(boltReplications)
+		var dropped [][]byte
+		err = queue.ForEach(func(k, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.TargetNodeID == nodeID && (item.Status == "PENDING" || item.Status == "FAILED") {
+				dropped = append(dropped, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range dropped {
+			if err := queue.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		// Data node RETIRED dianggap sudah hilang, delete tertunda tidak perlu diulang
+		tombstones := tx.Bucket(boltTombstones)
+		var cleared [][]byte
+		err = tombstones.ForEach(func(k, v []byte) error {
+			var t DeleteTombstone
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			if t.NodeID == nodeID {
+				cleared = append(cleared, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range cleared {
+			if err := tombstones.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) CountActiveLocationsOnNode(nodeID string) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(boltLocations).ForEach(func(k, v []byte) error {
+			if string(v) == "ACTIVE" && bytes.HasSuffix(k, []byte("\x00"+nodeID)) {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltChunkLocations).ForEach(func(k, v []byte) error {
+			if _, _, holder := splitChunkLocationKey(k); holder == nodeID && string(v) == "ACTIVE" {
+				count++
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+func (s *boltStore) RegisterFile(reg FileRegistration) (bool, error) {
+	replayed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if reg.IdempotencyKey != "" {
+			keys := tx.Bucket(boltIdempotency)
+			var seen boltIdempotencyKey
+			found, err := getJSON(keys, []byte(reg.IdempotencyKey), &seen)
+			if err != nil {
+				return err
+			}
+			if found {
+				if seen.FileKey != reg.FileKey {
+					return errIdempotencyKeyReused
+				}
+				replayed = true
+				return nil
+			}
+			if err := putJSON(keys, []byte(reg.IdempotencyKey), boltIdempotencyKey{FileKey: reg.FileKey, CreatedAt: time.Now()}); err != nil {
+				return err
+			}
+		}
+
+		objectKey := reg.objectKey()
+		if err := saveBoltFile(tx, objectKey, reg.OriginalFilename, reg.SizeBytes, reg.Checksum, reg.ReplicationFactor); err != nil {
+			return err
+		}
+		if _, err := addBoltFileVersion(tx, reg.FileKey, objectKey); err != nil {
+			return err
+		}
+
+		if reg.Erasure != nil {
+			if err := putBoltErasureManifest(tx, objectKey, *reg.Erasure, reg.NodeIDs); err != nil {
+				return err
+			}
+		} else if len(reg.Chunks) > 0 {
+			if err := putBoltChunkManifest(tx, objectKey, reg.ChunkSizeBytes, reg.Chunks); err != nil {
+				return err
+			}
+		} else {
+			locations := tx.Bucket(boltLocations)
+			for _, nodeID := range reg.NodeIDs {
+				if err := locations.Put(locationKey(objectKey, nodeID), []byte("ACTIVE")); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Target yang sudah punya item terbuka tidak diantrikan lagi
+		queue := tx.Bucket(boltReplications)
+		open := make(map[string]bool)
+		err := queue.ForEach(func(_, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.FileKey == objectKey && item.ChunkIndex == nil && item.ShardIndex == nil &&
+				(item.Status == "PENDING" || item.Status == "FAILED" || item.Status == "IN_PROGRESS") {
+				open[item.TargetNodeID] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, nodeID := range reg.FailedNodeIDs {
+			if open[nodeID] {
+				continue
+			}
+			open[nodeID] = true
+			if err := enqueueBolt(tx, objectKey, nil, nil, nodeID, reg.SourceNodeID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return replayed, err
+}
+
+func (s *boltStore) PruneIdempotencyKeys(olderThan time.Duration) (int64, error) {
+	var expired [][]byte
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(boltIdempotency)
+		cutoff := time.Now().Add(-olderThan)
+		err := keys.ForEach(func(k, v []byte) error {
+			var seen boltIdempotencyKey
+			if err := json.Unmarshal(v, &seen); err != nil {
+				return err
+			}
+			if seen.CreatedAt.Before(cutoff) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := keys.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return int64(len(expired)), err
+}
+
+// saveBoltFile adalah upsert row files seperti INSERT ... ON DUPLICATE KEY UPDATE
+func saveBoltFile(tx *bolt.Tx, fileKey, originalFilename string, sizeBytes int64, checksum string, replicationFactor *int) error {
+	b := tx.Bucket(boltFiles)
+	var f boltFile
+	found, err := getJSON(b, []byte(fileKey), &f)
+	if err != nil {
+		return err
+	}
+	if !found {
+		f.FileKey = fileKey
+		f.UploadedAt = time.Now().UTC().Format(time.RFC3339)
+		f.StorageMode = "REPLICATED"
+	}
+	f.OriginalFilename = originalFilename
+	f.SizeBytes = sizeBytes
+	f.ChecksumSHA256 = checksum
+	f.TrashedAt = nil
+	if replicationFactor != nil {
+		f.ReplicationFactor = replicationFactor
+	}
+	return putJSON(b, []byte(fileKey), f)
+}
+
+func (s *boltStore) GetFile(fileKey string) (*FileMetadata, error) {
+	var f boltFile
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		found, err = getJSON(tx.Bucket(boltFiles), []byte(fileKey), &f)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return f.fileMetadata(), nil
+}
+
+func (s *boltStore) ListFiles(limit int) ([]FileMetadata, error) {
+	var files []FileMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltFiles)
+
+		// object key -> versi; cursor urut versi jadi yang terakhir adalah
+		// versi terbaru di luar trash
+		owners := make(map[string]FileMetadata)
+		latest := make(map[string]string)
+		err := tx.Bucket(boltVersions).ForEach(func(k, v []byte) error {
+			var fv boltFileVersion
+			if err := json.Unmarshal(v, &fv); err != nil {
+				return err
+			}
+			fileKey, version := splitIndexKey(k)
+			owners[fv.ObjectKey] = FileMetadata{FileKey: fileKey, Version: version}
+
+			var f boltFile
+			found, err := getJSON(bucket, []byte(fv.ObjectKey), &f)
+			if err != nil {
+				return err
+			}
+			if found && f.TrashedAt == nil {
+				latest[fileKey] = fv.ObjectKey
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, v []byte) error {
+			var f boltFile
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			if f.TrashedAt != nil {
+				return nil
+			}
+			f.ObjectKey = f.FileKey
+			f.Version = 1
+			if owner, ok := owners[f.ObjectKey]; ok {
+				if latest[owner.FileKey] != f.ObjectKey {
+					return nil
+				}
+				f.FileKey = owner.FileKey
+				f.Version = owner.Version
+			}
+			files = append(files, f.FileMetadata)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return parseUploadedAt(files[i].UploadedAt).After(parseUploadedAt(files[j].UploadedAt))
+	})
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
+
+func (s *boltStore) DeleteFile(fileKey string, tombstones []DeleteTombstone) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, t := range tombstones {
+			if err := addBoltTombstone(tx, t); err != nil {
+				return err
+			}
+		}
+
+		queue := tx.Bucket(boltReplications)
+		var dropped [][]byte
+		err := queue.ForEach(func(k, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.FileKey == fileKey {
+				dropped = append(dropped, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range dropped {
+			if err := queue.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		// Semua bucket per file memakai prefix file_key + \x00
+		prefix := locationPrefix(fileKey)
+		for _, name := range [][]byte{boltLocations, boltShards, boltChunks, boltChunkLocations} {
+			if err := deletePrefix(tx.Bucket(name), prefix); err != nil {
+				return err
+			}
+		}
+
+		versions := tx.Bucket(boltVersions)
+		var versionKeys [][]byte
+		err = versions.ForEach(func(k, v []byte) error {
+			var fv boltFileVersion
+			if err := json.Unmarshal(v, &fv); err != nil {
+				return err
+			}
+			if fv.ObjectKey == fileKey {
+				versionKeys = append(versionKeys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range versionKeys {
+			if err := versions.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(boltFiles).Delete([]byte(fileKey))
+	})
+}
+
+func splitIndexKey(k []byte) (string, int) {
+	return string(k[:len(k)-5]), int(binary.BigEndian.Uint32(k[len(k)-4:]))
+}
+
+// boltVersionOwner mencari file_key dan versi yang disimpan di objectKey
+func boltVersionOwner(tx *bolt.Tx, objectKey string) (string, int, error) {
+	var fileKey string
+	var version int
+	err := tx.Bucket(boltVersions).ForEach(func(k, v []byte) error {
+		var fv boltFileVersion
+		if err := json.Unmarshal(v, &fv); err != nil {
+			return err
+		}
+		if fv.ObjectKey == objectKey {
+			fileKey, version = splitIndexKey(k)
+		}
+		return nil
+	})
+	return fileKey, version, err
+}
+
+// addBoltFileVersion sama dengan addFileVersionTx di store MySQL
+func addBoltFileVersion(tx *bolt.Tx, fileKey, objectKey string) (int, error) {
+	owner, version, err := boltVersionOwner(tx, objectKey)
+	if err != nil {
+		return 0, err
+	}
+	if owner == fileKey {
+		return version, nil
+	}
+
+	versions := tx.Bucket(boltVersions)
+	latest := 0
+	prefix := []byte(fileKey + "\x00")
+	c := versions.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		_, latest = splitIndexKey(k)
+	}
+	now := time.Now()
+
+	// File lama tanpa riwayat: row files dengan key fileKey menjadi versi 1
+	if latest == 0 && objectKey != fileKey && tx.Bucket(boltFiles).Get([]byte(fileKey)) != nil {
+		if err := putJSON(versions, versionKey(fileKey, 1), boltFileVersion{ObjectKey: fileKey, CreatedAt: now}); err != nil {
+			return 0, err
+		}
+		latest = 1
+	}
+
+	if err := putJSON(versions, versionKey(fileKey, latest+1), boltFileVersion{ObjectKey: objectKey, CreatedAt: now}); err != nil {
+		return 0, err
+	}
+	return latest + 1, nil
+}
+
+func (s *boltStore) ListFileVersions(fileKey string) ([]FileVersion, error) {
+	var versions []FileVersion
+	err := s.db.View(func(tx *bolt.Tx) error {
+		files := tx.Bucket(boltFiles)
+		c := tx.Bucket(boltVersions).Cursor()
+		prefix := []byte(fileKey + "\x00")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var fv boltFileVersion
+			if err := json.Unmarshal(v, &fv); err != nil {
+				return err
+			}
+			var f boltFile
+			found, err := getJSON(files, []byte(fv.ObjectKey), &f)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			_, version := splitIndexKey(k)
+			fileVersion := implicitFileVersion(&f.FileMetadata)
+			fileVersion.FileKey = fileKey
+			fileVersion.Version = version
+			versions = append([]FileVersion{fileVersion}, versions...)
+		}
+		if len(versions) > 0 {
+			markLatestVersion(versions)
+			return nil
+		}
+
+		// Tanpa riwayat: row files fileKey adalah versi 1, kecuali row itu
+		// sendiri object versi dari file_key lain
+		owner, _, err := boltVersionOwner(tx, fileKey)
+		if err != nil || owner != "" {
+			return err
+		}
+		var f boltFile
+		found, err := getJSON(files, []byte(fileKey), &f)
+		if err != nil || !found {
+			return err
+		}
+		versions = []FileVersion{implicitFileVersion(&f.FileMetadata)}
+		return nil
+	})
+	return versions, err
+}
+
+// updateFile membaca satu row files, menjalankan fn, lalu menyimpannya kembali;
+// object yang tidak ada diabaikan
+func (s *boltStore) updateFile(objectKey string, fn func(f *boltFile)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltFiles)
+		var f boltFile
+		found, err := getJSON(b, []byte(objectKey), &f)
+		if err != nil || !found {
+			return err
+		}
+		fn(&f)
+		return putJSON(b, []byte(objectKey), f)
+	})
+}
+
+func (s *boltStore) TrashFile(objectKey string) error {
+	return s.updateFile(objectKey, func(f *boltFile) {
+		if f.TrashedAt == nil {
+			now := time.Now()
+			f.TrashedAt = &now
+		}
+	})
+}
+
+func (s *boltStore) RestoreFile(objectKey string) error {
+	return s.updateFile(objectKey, func(f *boltFile) {
+		f.TrashedAt = nil
+	})
+}
+
+func (s *boltStore) ListTrash(limit int) ([]FileVersion, error) {
+	var trashed []FileVersion
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltFiles).ForEach(func(_, v []byte) error {
+			var f boltFile
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			if f.TrashedAt == nil {
+				return nil
+			}
+			version := implicitFileVersion(&f.FileMetadata)
+			if owner, number, err := boltVersionOwner(tx, f.FileKey); err != nil {
+				return err
+			} else if owner != "" {
+				version.FileKey = owner
+				version.Version = number
+			}
+			trashed = append(trashed, version)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return trashed[i].TrashedAt.After(*trashed[j].TrashedAt)
+	})
+	if len(trashed) > limit {
+		trashed = trashed[:limit]
+	}
+	return trashed, nil
+}
+
+func (s *boltStore) ListExpiredTrash(olderThan time.Duration, limit int) ([]string, error) {
+	var objectKeys []string
+	cutoff := time.Now().Add(-olderThan)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltFiles).ForEach(func(k, v []byte) error {
+			var f boltFile
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			if f.TrashedAt != nil && f.TrashedAt.Before(cutoff) && len(objectKeys) < limit {
+				objectKeys = append(objectKeys, string(k))
+			}
+			return nil
+		})
+	})
+	return objectKeys, err
+}
+
+func (s *boltStore) GetFileLocations(fileKey string) ([]string, error) {
+	var nodeIDs []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltLocations).Cursor()
+		prefix := locationPrefix(fileKey)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if string(v) == "ACTIVE" {
+				nodeIDs = append(nodeIDs, string(k[len(prefix):]))
+			}
+		}
+		return nil
+	})
+	return nodeIDs, err
+}
+
+func (s *boltStore) ListObjectLocations(fileKey string) ([]ObjectLocation, error) {
+	var locations []ObjectLocation
+	err := s.db.View(func(tx *bolt.Tx) error {
+		shards := tx.Bucket(boltShards)
+		prefix := locationPrefix(fileKey)
+		c := tx.Bucket(boltLocations).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			loc := ObjectLocation{NodeID: string(k[len(prefix):]), BlobKey: fileKey, Status: string(v)}
+			var index int
+			if found, err := getJSON(shards, k, &index); err != nil {
+				return err
+			} else if found {
+				loc.BlobKey = shardObjectKey(fileKey, index)
+			}
+			locations = append(locations, loc)
+		}
+
+		c = tx.Bucket(boltChunkLocations).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			_, chunkIndex, nodeID := splitChunkLocationKey(k)
+			locations = append(locations, ObjectLocation{NodeID: nodeID, BlobKey: chunkObjectKey(fileKey, chunkIndex), Status: string(v)})
+		}
+		return nil
+	})
+	return locations, err
+}
+
+func (s *boltStore) ListNodeLocations(nodeID string) (map[string]string, error) {
+	locations := make(map[string]string)
+	suffix := []byte("\x00" + nodeID)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		shards := tx.Bucket(boltShards)
+		err := tx.Bucket(boltLocations).ForEach(func(k, v []byte) error {
+			if !bytes.HasSuffix(k, suffix) {
+				return nil
+			}
+			blobKey := string(bytes.TrimSuffix(k, suffix))
+			var index int
+			if found, err := getJSON(shards, k, &index); err != nil {
+				return err
+			} else if found {
+				blobKey = shardObjectKey(blobKey, index)
+			}
+			locations[blobKey] = string(v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(boltChunkLocations).ForEach(func(k, v []byte) error {
+			if fileKey, chunkIndex, holder := splitChunkLocationKey(k); holder == nodeID {
+				locations[chunkObjectKey(fileKey, chunkIndex)] = string(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Part upload session yang belum selesai belum tercatat di chunk_locations
+		sessions := tx.Bucket(boltUploadSessions)
+		return tx.Bucket(boltUploadParts).ForEach(func(k, v []byte) error {
+			uploadID, partNumber := splitIndexKey(k)
+			var session boltUploadSession
+			found, err := getJSON(sessions, []byte(uploadID), &session)
+			if err != nil || !found || (session.Status != "ACTIVE" && session.Status != "COMPLETING") {
+				return err
+			}
+			var part boltUploadPart
+			if err := json.Unmarshal(v, &part); err != nil {
+				return err
+			}
+			if slices.Contains(part.NodeIDs, nodeID) {
+				locations[partObjectKey(session.FileKey, partNumber)] = "ACTIVE"
+			}
+			return nil
+		})
+	})
+	return locations, err
+}
+
+func (s *boltStore) SetFileLocationStatus(fileKey, nodeID, status string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltLocations).Put(locationKey(fileKey, nodeID), []byte(status))
+	})
+}
+
+// addBoltTombstone menyimpan tombstone baru; pasangan node + blob yang sudah
+// tercatat dilewati seperti INSERT IGNORE di MySQL
+func addBoltTombstone(tx *bolt.Tx, t DeleteTombstone) error {
+	b := tx.Bucket(boltTombstones)
+	exists := false
+	err := b.ForEach(func(k, v []byte) error {
+		var existing DeleteTombstone
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return err
+		}
+		if existing.NodeID == t.NodeID && existing.BlobKey == t.BlobKey {
+			exists = true
+		}
+		return nil
+	})
+	if err != nil || exists {
+		return err
+	}
+
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	t.Attempts = 0
+	t.LastAttempt = nil
+	t.LastError = ""
+	t.CreatedAt = time.Now()
+	return putJSON(b, queueKey(t.ID), t)
+}
+
+func (s *boltStore) ListTombstones(nodeID string, limit int) ([]DeleteTombstone, error) {
+	var tombstones []DeleteTombstone
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltTombstones).Cursor()
+		for k, v := c.First(); k != nil && len(tombstones) < limit; k, v = c.Next() {
+			var t DeleteTombstone
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			if nodeID == "" || t.NodeID == nodeID {
+				tombstones = append(tombstones, t)
+			}
+		}
+		return nil
+	})
+	return tombstones, err
+}
+
+func (s *boltStore) DeleteTombstone(id int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTombstones).Delete(queueKey(id))
+	})
+}
+
+func (s *boltStore) FailTombstone(id int, errorMsg string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltTombstones)
+		var t DeleteTombstone
+		found, err := getJSON(b, queueKey(id), &t)
+		if err != nil || !found {
+			return err
+		}
+		now := time.Now()
+		t.Attempts++
+		t.LastAttempt = &now
+		t.LastError = errorMsg
+		return putJSON(b, queueKey(id), t)
+	})
+}
+
+func (s *boltStore) EnqueueReplication(fileKey string, chunkIndex, shardIndex *int, targetNodeID, sourceNodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return enqueueBolt(tx, fileKey, chunkIndex, shardIndex, targetNodeID, sourceNodeID)
+	})
+}
+
+func enqueueBolt(tx *bolt.Tx, fileKey string, chunkIndex, shardIndex *int, targetNodeID, sourceNodeID string) error {
+	b := tx.Bucket(boltReplications)
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	item := ReplicationQueueItem{
+		ID:           int(id),
+		FileKey:      fileKey,
+		TargetNodeID: targetNodeID,
+		SourceNodeID: sourceNodeID,
+		Status:       "PENDING",
+		CreatedAt:    time.Now(),
+		ChunkIndex:   chunkIndex,
+		ShardIndex:   shardIndex,
+	}
+	return putJSON(b, queueKey(item.ID), item)
+}
+
+func (s *boltStore) ListReplications(targetNodeID, status string, limit int) ([]ReplicationQueueItem, error) {
+	var items []ReplicationQueueItem
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Id naik sesuai urutan insert, jadi iterasi mundur = created_at DESC
+		c := tx.Bucket(boltReplications).Cursor()
+		for k, v := c.Last(); k != nil && len(items) < limit; k, v = c.Prev() {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if (targetNodeID == "" || item.TargetNodeID == targetNodeID) && (status == "" || item.Status == status) {
+				items = append(items, item)
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (s *boltStore) LeaseReplications(owner, targetNodeID string, limit int, lease time.Duration) ([]ReplicationQueueItem, error) {
+	var items []ReplicationQueueItem
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		nodes := tx.Bucket(boltNodes)
+		queue := tx.Bucket(boltReplications)
+		now := time.Now()
+		expires := now.Add(lease)
+
+		c := queue.Cursor()
+		for k, v := c.First(); k != nil && len(items) < limit; k, v = c.Next() {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.Status != "PENDING" && item.Status != "FAILED" {
+				continue
+			}
+
+			var target Node
+			found, err := getJSON(nodes, []byte(item.TargetNodeID), &target)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if targetNodeID != "" {
+				if item.TargetNodeID != targetNodeID {
+					continue
+				}
+			} else if target.Status != "UP" || (item.NextAttemptAt != nil && item.NextAttemptAt.After(now)) {
+				continue
+			}
+
+			item.Status = "IN_PROGRESS"
+			item.LeaseOwner = &owner
+			item.LeaseExpiresAt = &expires
+			item.LastAttempt = &now
+			items = append(items, item)
+		}
+
+		for _, item := range items {
+			if err := putJSON(queue, queueKey(item.ID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *boltStore) ExtendReplicationLease(queueID int, owner string, lease time.Duration) error {
+	_, err := s.updateQueueItem(queueID, func(item *ReplicationQueueItem) bool {
+		if item.Status != "IN_PROGRESS" || item.LeaseOwner == nil || *item.LeaseOwner != owner {
+			return false
+		}
+		expires := time.Now().Add(lease)
+		item.LeaseExpiresAt = &expires
+		return true
+	})
+	return err
+}
+
+func (s *boltStore) CompleteReplication(queueID int) error {
+	_, err := s.updateQueueItem(queueID, func(item *ReplicationQueueItem) bool {
+		item.Status = "COMPLETED"
+		item.LeaseOwner = nil
+		item.LeaseExpiresAt = nil
+		return true
+	})
+	return err
+}
+
+func (s *boltStore) FailReplication(queueID int, status, errorMsg string, retryAfter time.Duration) error {
+	_, err := s.updateQueueItem(queueID, func(item *ReplicationQueueItem) bool {
+		now := time.Now()
+		next := now.Add(retryAfter)
+		item.Status = status
+		item.RetryCount++
+		item.LastAttempt = &now
+		item.ErrorMessage = errorMsg
+		item.NextAttemptAt = &next
+		item.LeaseOwner = nil
+		item.LeaseExpiresAt = nil
+		return true
+	})
+	return err
+}
+
+func (s *boltStore) ReclaimExpiredReplications() (int64, error) {
+	now := time.Now()
+	return s.updateQueueItems(func(item *ReplicationQueueItem) bool {
+		if item.Status != "IN_PROGRESS" || (item.LeaseExpiresAt != nil && !item.LeaseExpiresAt.Before(now)) {
+			return false
+		}
+		item.Status = "PENDING"
+		item.LeaseOwner = nil
+		item.LeaseExpiresAt = nil
+		return true
+	})
+}
+
+func (s *boltStore) ResetReplicationBackoff(nodeID string) error {
+	_, err := s.updateQueueItems(func(item *ReplicationQueueItem) bool {
+		if (item.Status != "PENDING" && item.Status != "FAILED") || item.NextAttemptAt == nil {
+			return false
+		}
+		if item.TargetNodeID != nodeID && item.SourceNodeID != nodeID {
+			return false
+		}
+		item.NextAttemptAt = nil
+		return true
+	})
+	return err
+}
+
+func (s *boltStore) RetryDeadReplication(queueID int) (bool, error) {
+	return s.updateQueueItem(queueID, func(item *ReplicationQueueItem) bool {
+		if item.Status != "DEAD" {
+			return false
+		}
+		item.Status = "PENDING"
+		item.RetryCount = 0
+		item.NextAttemptAt = nil
+		item.ErrorMessage = ""
+		return true
+	})
+}
+
+func (s *boltStore) UpdateLocationStatus(fileKey string, chunkIndex *int, nodeID, status string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, key := tx.Bucket(boltLocations), locationKey(fileKey, nodeID)
+		if chunkIndex != nil {
+			b, key = tx.Bucket(boltChunkLocations), chunkLocationKey(fileKey, *chunkIndex, nodeID)
+		}
+		if b.Get(key) == nil {
+			return nil
+		}
+		return b.Put(key, []byte(status))
+	})
+}
+
+func (s *boltStore) ListObjectCopies(nodeID string) ([]ObjectCopy, error) {
+	var copies []ObjectCopy
+	err := s.db.View(func(tx *bolt.Tx) error {
+		files := tx.Bucket(boltFiles)
+		shards := tx.Bucket(boltShards)
+		chunks := tx.Bucket(boltChunks)
+
+		err := tx.Bucket(boltLocations).ForEach(func(k, v []byte) error {
+			fileKey, holder := splitLocationKey(k)
+			if string(v) != "ACTIVE" || (nodeID != "" && holder != nodeID) {
+				return nil
+			}
+			var f boltFile
+			found, err := getJSON(files, []byte(fileKey), &f)
+			if err != nil || !found {
+				return err
+			}
+			c := ObjectCopy{NodeID: holder, FileKey: fileKey, StorageMode: f.StorageMode, SizeBytes: f.SizeBytes, ReplicationFactor: f.ReplicationFactor}
+			var index int
+			if found, err := getJSON(shards, k, &index); err != nil {
+				return err
+			} else if found {
+				c.ShardIndex = &index
+			}
+			copies = append(copies, c)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(boltChunkLocations).ForEach(func(k, v []byte) error {
+			fileKey, chunkIndex, holder := splitChunkLocationKey(k)
+			if string(v) != "ACTIVE" || (nodeID != "" && holder != nodeID) {
+				return nil
+			}
+			var f boltFile
+			var chunk boltChunk
+			found, err := getJSON(files, []byte(fileKey), &f)
+			if err != nil || !found {
+				return err
+			}
+			if found, err = getJSON(chunks, indexKey(fileKey, chunkIndex), &chunk); err != nil || !found {
+				return err
+			}
+			copies = append(copies, ObjectCopy{
+				NodeID:            holder,
+				FileKey:           fileKey,
+				ChunkIndex:        &chunkIndex,
+				StorageMode:       f.StorageMode,
+				SizeBytes:         chunk.SizeBytes,
+				ReplicationFactor: f.ReplicationFactor,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sama dengan ORDER BY file_key, chunk_index di MySQL (file utuh duluan)
+	sort.SliceStable(copies, func(i, j int) bool {
+		if copies[i].FileKey != copies[j].FileKey {
+			return copies[i].FileKey < copies[j].FileKey
+		}
+		if copies[i].ChunkIndex == nil || copies[j].ChunkIndex == nil {
+			return copies[i].ChunkIndex == nil && copies[j].ChunkIndex != nil
+		}
+		return *copies[i].ChunkIndex < *copies[j].ChunkIndex
+	})
+	return copies, nil
+}
+
+func (s *boltStore) NextFileKey(cursor string) (string, error) {
+	var fileKey string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltFiles).Cursor()
+		k, _ := c.Seek([]byte(cursor))
+		if k != nil && string(k) == cursor {
+			k, _ = c.Next()
+		}
+		fileKey = string(k)
+		return nil
+	})
+	return fileKey, err
+}
+
+func (s *boltStore) CountFiles() (int, error) {
+	var total int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		total = tx.Bucket(boltFiles).Stats().KeyN
+		return nil
+	})
+	return total, err
+}
+
+func putBoltChunkManifest(tx *bolt.Tx, fileKey string, chunkSize int64, chunks []FileChunk) error {
+	files := tx.Bucket(boltFiles)
+	var f boltFile
+	found, err := getJSON(files, []byte(fileKey), &f)
+	if err != nil {
+		return err
+	}
+	if found {
+		f.StorageMode = "CHUNKED"
+		f.ChunkSizeBytes = chunkSize
+		if err := putJSON(files, []byte(fileKey), f); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range chunks {
+		if err := putJSON(tx.Bucket(boltChunks), indexKey(fileKey, chunk.ChunkIndex), boltChunk{SizeBytes: chunk.SizeBytes, ChecksumSHA256: chunk.ChecksumSHA256}); err != nil {
+			return err
+		}
+		for _, nodeID := range chunk.Locations {
+			if err := tx.Bucket(boltChunkLocations).Put(chunkLocationKey(fileKey, chunk.ChunkIndex, nodeID), []byte("ACTIVE")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// activeChunkLocations mengembalikan node dengan salinan ACTIVE satu chunk
+func activeChunkLocations(tx *bolt.Tx, fileKey string, chunkIndex int) []string {
+	var nodeIDs []string
+	prefix := indexKey(fileKey, chunkIndex)
+	c := tx.Bucket(boltChunkLocations).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if string(v) == "ACTIVE" {
+			nodeIDs = append(nodeIDs, string(k[len(prefix):]))
+		}
+	}
+	return nodeIDs
+}
+
+func (s *boltStore) GetChunkManifest(fileKey string) ([]FileChunk, error) {
+	var chunks []FileChunk
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := locationPrefix(fileKey)
+		c := tx.Bucket(boltChunks).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var chunk boltChunk
+			if err := json.Unmarshal(v, &chunk); err != nil {
+				return err
+			}
+			_, index := splitIndexKey(k)
+			chunks = append(chunks, FileChunk{
+				FileKey:        fileKey,
+				ChunkIndex:     index,
+				SizeBytes:      chunk.SizeBytes,
+				ChecksumSHA256: chunk.ChecksumSHA256,
+				Locations:      activeChunkLocations(tx, fileKey, index),
+			})
+		}
+		return nil
+	})
+	return chunks, err
+}
+
+func (s *boltStore) GetChunk(fileKey string, chunkIndex int) (*FileChunk, error) {
+	var result *FileChunk
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var chunk boltChunk
+		found, err := getJSON(tx.Bucket(boltChunks), indexKey(fileKey, chunkIndex), &chunk)
+		if err != nil || !found {
+			return err
+		}
+		result = &FileChunk{
+			FileKey:        fileKey,
+			ChunkIndex:     chunkIndex,
+			SizeBytes:      chunk.SizeBytes,
+			ChecksumSHA256: chunk.ChecksumSHA256,
+			Locations:      activeChunkLocations(tx, fileKey, chunkIndex),
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (s *boltStore) SetChunkLocationActive(fileKey string, chunkIndex int, nodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltChunkLocations).Put(chunkLocationKey(fileKey, chunkIndex, nodeID), []byte("ACTIVE"))
+	})
+}
+
+func (s *boltStore) GetChunkedFileNodes(fileKey string) ([]string, error) {
+	var nodeIDs []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		seen := make(map[string]bool)
+		prefix := locationPrefix(fileKey)
+		c := tx.Bucket(boltChunkLocations).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if _, _, nodeID := splitChunkLocationKey(k); string(v) == "ACTIVE" && !seen[nodeID] {
+				seen[nodeID] = true
+				nodeIDs = append(nodeIDs, nodeID)
+			}
+		}
+		return nil
+	})
+	return nodeIDs, err
+}
+
+func putBoltErasureManifest(tx *bolt.Tx, fileKey string, params ErasureParams, nodeIDs []string) error {
+	files := tx.Bucket(boltFiles)
+	var f boltFile
+	found, err := getJSON(files, []byte(fileKey), &f)
+	if err != nil {
+		return err
+	}
+	if found {
+		f.StorageMode = "ERASURE"
+		f.ECDataShards = params.DataShards
+		f.ECParityShards = params.ParityShards
+		f.ErasureBlockSize = params.BlockSize
+		if err := putJSON(files, []byte(fileKey), f); err != nil {
+			return err
+		}
+	}
+
+	for i, nodeID := range nodeIDs {
+		if err := putBoltShard(tx, fileKey, i, nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putBoltShard(tx *bolt.Tx, fileKey string, shardIndex int, nodeID string) error {
+	key := locationKey(fileKey, nodeID)
+	if err := tx.Bucket(boltLocations).Put(key, []byte("ACTIVE")); err != nil {
+		return err
+	}
+	return putJSON(tx.Bucket(boltShards), key, shardIndex)
+}
+
+func (s *boltStore) SetShardLocationActive(fileKey string, shardIndex int, nodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putBoltShard(tx, fileKey, shardIndex, nodeID)
+	})
+}
+
+func (s *boltStore) GetShardLocations(fileKey string) (map[string]int, error) {
+	shardByNode := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		shards := tx.Bucket(boltShards)
+		prefix := locationPrefix(fileKey)
+		c := tx.Bucket(boltLocations).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if string(v) != "ACTIVE" {
+				continue
+			}
+			var index int
+			found, err := getJSON(shards, k, &index)
+			if err != nil {
+				return err
+			}
+			if found {
+				shardByNode[string(k[len(prefix):])] = index
+			}
+		}
+		return nil
+	})
+	return shardByNode, err
+}
+
+func (s *boltStore) CreateUploadSession(uploadID, fileKey, originalFilename string, replicationFactor int, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		return putJSON(tx.Bucket(boltUploadSessions), []byte(uploadID), boltUploadSession{
+			FileKey:           fileKey,
+			OriginalFilename:  originalFilename,
+			ReplicationFactor: replicationFactor,
+			Status:            "ACTIVE",
+			CreatedAt:         now,
+			ExpiresAt:         now.Add(ttl),
+		})
+	})
+}
+
+func (s *boltStore) GetUploadSession(uploadID string) (*UploadSession, error) {
+	var result *UploadSession
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var session boltUploadSession
+		found, err := getJSON(tx.Bucket(boltUploadSessions), []byte(uploadID), &session)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errUploadSessionNotFound
+		}
+
+		result = &UploadSession{
+			UploadID:          uploadID,
+			FileKey:           session.FileKey,
+			OriginalFilename:  session.OriginalFilename,
+			ReplicationFactor: session.ReplicationFactor,
+			Status:            session.Status,
+			CreatedAt:         session.CreatedAt.Format(time.RFC3339Nano),
+			ExpiresAt:         session.ExpiresAt.Format(time.RFC3339Nano),
+			Parts:             []UploadPart{},
+		}
+		prefix := locationPrefix(uploadID)
+		c := tx.Bucket(boltUploadParts).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var part boltUploadPart
+			if err := json.Unmarshal(v, &part); err != nil {
+				return err
+			}
+			_, partNumber := splitIndexKey(k)
+			result.Parts = append(result.Parts, UploadPart{
+				PartNumber:     partNumber,
+				SizeBytes:      part.SizeBytes,
+				ChecksumSHA256: part.ChecksumSHA256,
+				Locations:      part.NodeIDs,
+				UploadedAt:     part.UploadedAt.Format(time.RFC3339Nano),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStore) RecordUploadPart(uploadID string, part UploadPart, ttl time.Duration) ([]string, error) {
+	var previous []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltUploadSessions)
+		var session boltUploadSession
+		found, err := getJSON(sessions, []byte(uploadID), &session)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errUploadSessionNotFound
+		}
+		if session.Status != "ACTIVE" {
+			return errUploadSessionClosed
+		}
+
+		parts := tx.Bucket(boltUploadParts)
+		key := indexKey(uploadID, part.PartNumber)
+		var old boltUploadPart
+		if found, err := getJSON(parts, key, &old); err != nil {
+			return err
+		} else if found {
+			previous = old.NodeIDs
+		}
+
+		now := time.Now()
+		if err := putJSON(parts, key, boltUploadPart{
+			SizeBytes:      part.SizeBytes,
+			ChecksumSHA256: part.ChecksumSHA256,
+			NodeIDs:        part.Locations,
+			UploadedAt:     now,
+		}); err != nil {
+			return err
+		}
+
+		session.ExpiresAt = now.Add(ttl)
+		return putJSON(sessions, []byte(uploadID), session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+func (s *boltStore) SetUploadSessionStatus(uploadID, from, to string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltUploadSessions)
+		var session boltUploadSession
+		found, err := getJSON(sessions, []byte(uploadID), &session)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errUploadSessionNotFound
+		}
+		if session.Status != from {
+			return errUploadSessionClosed
+		}
+		session.Status = to
+		return putJSON(sessions, []byte(uploadID), session)
+	})
+}
+
+func (s *boltStore) DeleteUploadParts(uploadID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deletePrefix(tx.Bucket(boltUploadParts), locationPrefix(uploadID))
+	})
+}
+
+func (s *boltStore) ListExpiredUploadSessions() ([]UploadSession, error) {
+	var sessions []UploadSession
+	now := time.Now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUploadSessions).ForEach(func(k, v []byte) error {
+			var session boltUploadSession
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if (session.Status == "ACTIVE" || session.Status == "COMPLETING") && session.ExpiresAt.Before(now) {
+				sessions = append(sessions, UploadSession{UploadID: string(k), FileKey: session.FileKey, Status: session.Status})
+			}
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+func (s *boltStore) StartDrain(nodeID string, totalObjects int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		var n Node
+		found, err := getJSON(b, []byte(nodeID), &n)
+		if err != nil || !found {
+			return err
+		}
+		n.DrainState = "DRAINING"
+		if err := putJSON(b, []byte(nodeID), n); err != nil {
+			return err
+		}
+		return putJSON(tx.Bucket(boltDrains), []byte(nodeID), boltDrain{StartedAt: time.Now(), TotalObjects: totalObjects})
+	})
+}
+
+func (s *boltStore) CancelDrain(nodeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltNodes)
+		var n Node
+		found, err := getJSON(b, []byte(nodeID), &n)
+		if err != nil {
+			return err
+		}
+		if !found || n.DrainState != "DRAINING" {
+			return errNodeNotDraining
+		}
+		n.DrainState = "NONE"
+		if err := putJSON(b, []byte(nodeID), n); err != nil {
+			return err
+		}
+		return tx.Bucket(boltDrains).Delete([]byte(nodeID))
+	})
+}
+
+func (s *boltStore) FinishDrain(nodeID string) error {
+	err := s.updateNode(nodeID, func(n *Node) error {
+		n.DrainState = "DECOMMISSIONED"
+		return nil
+	})
+	if err == errNodeNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *boltStore) GetDrainStatus(nodeID string) (*DrainStatus, error) {
+	var status *DrainStatus
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var n Node
+		found, err := getJSON(tx.Bucket(boltNodes), []byte(nodeID), &n)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errNodeNotFound
+		}
+		status = &DrainStatus{NodeID: n.ID, DrainState: n.DrainState}
+		if status.DrainState == "" {
+			status.DrainState = "NONE"
+		}
+
+		var drain boltDrain
+		if found, err := getJSON(tx.Bucket(boltDrains), []byte(nodeID), &drain); err != nil {
+			return err
+		} else if found {
+			status.DrainStartedAt = &drain.StartedAt
+			status.TotalObjects = drain.TotalObjects
+		}
+
+		return tx.Bucket(boltReplications).ForEach(func(_, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.SourceNodeID != nodeID || (status.DrainStartedAt != nil && item.CreatedAt.Before(*status.DrainStartedAt)) {
+				return nil
+			}
+			switch item.Status {
+			case "PENDING", "IN_PROGRESS", "FAILED":
+				status.PendingReplications++
+			case "DEAD":
+				status.DeadReplications++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *boltStore) ListLostNodes(grace time.Duration) ([]string, error) {
+	nodes, err := s.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-grace)
+	var lost []string
+	for _, n := range nodes {
+		since := n.DownSince
+		if since == nil {
+			since = n.LastHeartbeat
+		}
+		if n.Status == "DOWN" && since != nil && since.Before(cutoff) {
+			lost = append(lost, n.ID)
+		}
+	}
+	return lost, nil
+}
+
+func (s *boltStore) ListQueuedTargets(fileKey string, chunkIndex, shardIndex *int) (map[string]string, error) {
+	sameIndex := func(a, b *int) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+	}
+
+	targets := make(map[string]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltReplications).ForEach(func(_, v []byte) error {
+			var item ReplicationQueueItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.FileKey != fileKey || item.Status == "COMPLETED" || !sameIndex(item.ChunkIndex, chunkIndex) || !sameIndex(item.ShardIndex, shardIndex) {
+				return nil
+			}
+			// Jika ada beberapa item untuk target yang sama, yang masih hidup menang
+			if prev, ok := targets[item.TargetNodeID]; !ok || prev == "DEAD" {
+				targets[item.TargetNodeID] = item.Status
+			}
+			return nil
+		})
+	})
+	return targets, err
+}
+
+func (s *boltStore) CancelReplicationsToNode(nodeID, reason string) error {
+	_, err := s.updateQueueItems(func(item *ReplicationQueueItem) bool {
+		if item.TargetNodeID != nodeID || (item.Status != "PENDING" && item.Status != "FAILED") {
+			return false
+		}
+		item.Status = "DEAD"
+		item.ErrorMessage = reason
+		return true
+	})
+	return err
+}
@@ -0,0 +1,819 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestBoltStore(t *testing.T) *boltStore {
+	t.Helper()
+	store, err := openBoltStore(filepath.Join(t.TempDir(), "meta.db"))
+	if err != nil {
+		t.Fatalf("openBoltStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestBoltNodeLifecycle(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, id := range []string{"n1", "n2"} {
+		if err := s.UpsertNode(id, "http://"+id, "REPLICA", "z1", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.GetNode("nope"); err != errNodeNotFound {
+		t.Errorf("GetNode(nope) err = %v, want errNodeNotFound", err)
+	}
+
+	// Heartbeat dengan lease yang sudah lewat langsung di-expire
+	free := int64(500)
+	if err := s.RecordHeartbeat("n1", HeartbeatReport{FreeBytes: &free}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordHeartbeat("n2", HeartbeatReport{}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.ExpireLeases()
+	if err != nil || !reflect.DeepEqual(expired, []string{"n1"}) {
+		t.Fatalf("ExpireLeases = %v, %v; want [n1]", expired, err)
+	}
+	n1, err := s.GetNode("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n1.Status != "DOWN" || n1.DownSince == nil || n1.FreeBytes == nil || *n1.FreeBytes != 500 {
+		t.Errorf("n1 after expire = %+v", n1)
+	}
+
+	if err := s.PromoteNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PromoteNode("n2"); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := s.ListNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := make(map[string]string)
+	for _, n := range nodes {
+		roles[n.ID] = n.Role
+	}
+	if want := map[string]string{"n1": "REPLICA", "n2": "MAIN"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("roles = %v, want %v", roles, want)
+	}
+
+	if err := s.RetireNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNode("n1", "http://other", "REPLICA", "", ""); err != errNodeNotFound {
+		t.Errorf("UpdateNode on retired node err = %v", err)
+	}
+	if err := s.PromoteNode("n1"); err != errNodeNotFound {
+		t.Errorf("PromoteNode on retired node err = %v", err)
+	}
+}
+
+func TestBoltFilesAndLocations(t *testing.T) {
+	s := newTestBoltStore(t)
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "f", OriginalFilename: "f.txt", SizeBytes: 10, Checksum: "sum", NodeIDs: []string{"n1", "n2"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Lokasi key lain dengan prefix sama tidak ikut terbaca
+	if err := s.SetFileLocationStatus("f2", "n1", "ACTIVE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetFileLocationStatus("f", "n2", "MISSING"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.GetFile("f")
+	if err != nil || f == nil {
+		t.Fatalf("GetFile = %v, %v", f, err)
+	}
+	if f.StorageMode != "REPLICATED" || f.OriginalFilename != "f.txt" || f.ChecksumSHA256 != "sum" {
+		t.Errorf("GetFile = %+v", f)
+	}
+	locations, err := s.GetFileLocations("f")
+	if err != nil || !reflect.DeepEqual(locations, []string{"n1"}) {
+		t.Errorf("GetFileLocations = %v, %v; want [n1]", locations, err)
+	}
+	if count, _ := s.CountActiveLocationsOnNode("n1"); count != 2 {
+		t.Errorf("CountActiveLocationsOnNode(n1) = %d, want 2", count)
+	}
+
+	if err := s.EnqueueReplication("f", nil, nil, "n3", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteFile("f", nil); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := s.GetFile("f"); f != nil {
+		t.Errorf("GetFile after delete = %+v", f)
+	}
+	if locations, _ := s.GetFileLocations("f"); len(locations) != 0 {
+		t.Errorf("locations after delete = %v", locations)
+	}
+	if items, _ := s.ListReplications("", "", 10); len(items) != 0 {
+		t.Errorf("queue after delete = %+v", items)
+	}
+	if locations, _ := s.GetFileLocations("f2"); !reflect.DeepEqual(locations, []string{"n1"}) {
+		t.Errorf("GetFileLocations(f2) = %v", locations)
+	}
+}
+
+func TestBoltReplicationLease(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, id := range []string{"n1", "n2"} {
+		if err := s.UpsertNode(id, "http://"+id, "REPLICA", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetNodeStatus(id, "UP"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.EnqueueReplication("f", nil, nil, "n2", "n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	lease := func(want int) []ReplicationQueueItem {
+		t.Helper()
+		items, err := s.LeaseReplications("w1", "", 10, time.Minute)
+		if err != nil || len(items) != want {
+			t.Fatalf("LeaseReplications = %+v, %v; want %d items", items, err, want)
+		}
+		return items
+	}
+
+	id := lease(1)[0].ID
+	lease(0)
+
+	// Retry ditunda sampai backoff lewat, kecuali node kembali UP
+	if err := s.FailReplication(id, "FAILED", "boom", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	lease(0)
+	if err := s.ResetReplicationBackoff("n2"); err != nil {
+		t.Fatal(err)
+	}
+	lease(1)
+
+	if err := s.FailReplication(id, "DEAD", "boom", 0); err != nil {
+		t.Fatal(err)
+	}
+	lease(0)
+	if ok, err := s.RetryDeadReplication(id); err != nil || !ok {
+		t.Fatalf("RetryDeadReplication = %v, %v", ok, err)
+	}
+	lease(1)
+
+	if err := s.CompleteReplication(id); err != nil {
+		t.Fatal(err)
+	}
+	items, err := s.ListReplications("n2", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, item := range items {
+		statuses = append(statuses, item.Status)
+	}
+	sort.Strings(statuses)
+	if !reflect.DeepEqual(statuses, []string{"COMPLETED"}) {
+		t.Errorf("statuses after complete = %v", statuses)
+	}
+}
+
+func TestBoltRegisterFileIdempotency(t *testing.T) {
+	s := newTestBoltStore(t)
+	reg := FileRegistration{
+		FileKey:          "f",
+		OriginalFilename: "f.txt",
+		SizeBytes:        3,
+		Checksum:         "sum",
+		NodeIDs:          []string{"n1"},
+		FailedNodeIDs:    []string{"n2"},
+		SourceNodeID:     "n1",
+		IdempotencyKey:   "k1",
+	}
+
+	if replayed, err := s.RegisterFile(reg); err != nil || replayed {
+		t.Fatalf("first RegisterFile = %v, %v", replayed, err)
+	}
+	// Retry storage node dengan key yang sama hanya di-replay
+	if replayed, err := s.RegisterFile(reg); err != nil || !replayed {
+		t.Fatalf("retry RegisterFile = %v, %v; want replayed", replayed, err)
+	}
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "g", IdempotencyKey: "k1"}); !errors.Is(err, errIdempotencyKeyReused) {
+		t.Errorf("key reused for other file err = %v", err)
+	}
+	if f, _ := s.GetFile("g"); f != nil {
+		t.Errorf("file g registered with reused key: %+v", f)
+	}
+
+	locations, err := s.GetFileLocations("f")
+	if err != nil || !reflect.DeepEqual(locations, []string{"n1"}) {
+		t.Errorf("GetFileLocations = %v, %v", locations, err)
+	}
+	items, err := s.ListReplications("n2", "", 10)
+	if err != nil || len(items) != 1 || items[0].SourceNodeID != "n1" {
+		t.Errorf("ListReplications(n2) = %+v, %v", items, err)
+	}
+}
+
+func TestBoltFileVersions(t *testing.T) {
+	s := newTestBoltStore(t)
+	// "doc" tanpa riwayat versi menjadi versi 1 saat versi baru diupload
+	for _, objectKey := range []string{"doc", "doc_v2"} {
+		if _, err := s.RegisterFile(FileRegistration{FileKey: "doc", ObjectKey: objectKey, OriginalFilename: objectKey, SizeBytes: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := s.ListFileVersions("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range versions {
+		got = append(got, fmt.Sprintf("v%d=%s latest=%v", v.Version, v.ObjectKey, v.IsLatest))
+	}
+	if want := []string{"v2=doc_v2 latest=true", "v1=doc latest=false"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFileVersions = %v, want %v", got, want)
+	}
+	if files, err := s.ListFiles(10); err != nil || len(files) != 1 || files[0].FileKey != "doc" {
+		t.Errorf("ListFiles = %+v, %v; want only latest version", files, err)
+	}
+	if versions, err := s.ListFileVersions("nope"); err != nil || versions != nil {
+		t.Errorf("ListFileVersions(nope) = %+v, %v; want nil", versions, err)
+	}
+}
+
+func TestBoltTrash(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, objectKey := range []string{"doc", "doc_v2"} {
+		if _, err := s.RegisterFile(FileRegistration{FileKey: "doc", ObjectKey: objectKey, SizeBytes: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.TrashFile(objectKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if trash, err := s.ListTrash(10); err != nil || len(trash) != 2 {
+		t.Errorf("ListTrash = %+v, %v", trash, err)
+	}
+	if expired, err := s.ListExpiredTrash(time.Hour, 10); err != nil || len(expired) != 0 {
+		t.Errorf("ListExpiredTrash(1h) = %v, %v", expired, err)
+	}
+	if expired, err := s.ListExpiredTrash(-time.Second, 10); err != nil || len(expired) != 2 {
+		t.Errorf("ListExpiredTrash(0) = %v, %v", expired, err)
+	}
+
+	if err := s.RestoreFile("doc_v2"); err != nil {
+		t.Fatal(err)
+	}
+	if trash, _ := s.ListTrash(10); len(trash) != 1 || trash[0].ObjectKey != "doc" {
+		t.Errorf("ListTrash after restore = %+v", trash)
+	}
+	if f, _ := s.GetFile("doc_v2"); f == nil || f.TrashedAt != nil {
+		t.Errorf("GetFile(doc_v2) after restore = %+v", f)
+	}
+}
+
+func TestBoltDeleteFileTombstones(t *testing.T) {
+	s := newTestBoltStore(t)
+	if _, err := s.RegisterFile(FileRegistration{FileKey: "f", SizeBytes: 1, NodeIDs: []string{"n1", "n2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteFile("f", []DeleteTombstone{{NodeID: "n2", FileKey: "f", BlobKey: "f"}}); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := s.GetFile("f"); f != nil {
+		t.Errorf("GetFile after delete = %+v", f)
+	}
+
+	if tombstones, _ := s.ListTombstones("n1", 10); len(tombstones) != 0 {
+		t.Errorf("ListTombstones(n1) = %+v", tombstones)
+	}
+	tombstones, err := s.ListTombstones("", 10)
+	if err != nil || len(tombstones) != 1 || tombstones[0].NodeID != "n2" || tombstones[0].BlobKey != "f" {
+		t.Fatalf("ListTombstones = %+v, %v", tombstones, err)
+	}
+
+	id := tombstones[0].ID
+	if err := s.FailTombstone(id, "node down"); err != nil {
+		t.Fatal(err)
+	}
+	tombstones, _ = s.ListTombstones("n2", 10)
+	if len(tombstones) != 1 || tombstones[0].Attempts != 1 || tombstones[0].LastError != "node down" {
+		t.Errorf("tombstone after fail = %+v", tombstones)
+	}
+	if err := s.DeleteTombstone(id); err != nil {
+		t.Fatal(err)
+	}
+	if tombstones, _ := s.ListTombstones("", 10); len(tombstones) != 0 {
+		t.Errorf("ListTombstones after delete = %+v", tombstones)
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestBoltChunkManifest(t *testing.T) {
+	s := newTestBoltStore(t)
+	rf := 2
+	if _, err := s.RegisterFile(FileRegistration{
+		FileKey:           "big",
+		OriginalFilename:  "big.bin",
+		SizeBytes:         30,
+		Checksum:          "sum",
+		ReplicationFactor: &rf,
+		Chunks: []FileChunk{
+			{ChunkIndex: 0, SizeBytes: 20, ChecksumSHA256: "c0", Locations: []string{"n1", "n2"}},
+			{ChunkIndex: 1, SizeBytes: 10, ChecksumSHA256: "c1", Locations: []string{"n2", "n3"}},
+		},
+		ChunkSizeBytes: 20,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if versions, err := s.ListFileVersions("big"); err != nil || len(versions) != 1 || versions[0].StorageMode != "CHUNKED" {
+		t.Fatalf("ListFileVersions = %+v, %v", versions, err)
+	}
+
+	f, err := s.GetFile("big")
+	if err != nil || f == nil || f.StorageMode != "CHUNKED" {
+		t.Fatalf("GetFile = %+v, %v; want CHUNKED", f, err)
+	}
+
+	manifest, err := s.GetChunkManifest("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 || manifest[0].ChunkIndex != 0 || manifest[1].ChunkIndex != 1 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if !reflect.DeepEqual(manifest[1].Locations, []string{"n2", "n3"}) {
+		t.Errorf("chunk 1 locations = %v", manifest[1].Locations)
+	}
+
+	// Salinan CORRUPT tidak dihitung, salinan hasil replikasi ditambahkan
+	if err := s.UpdateLocationStatus("big", intPtr(0), "n1", "CORRUPT"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChunkLocationActive("big", 0, "n3"); err != nil {
+		t.Fatal(err)
+	}
+	// Lokasi yang tidak tercatat tidak dibuat oleh UpdateLocationStatus
+	if err := s.UpdateLocationStatus("big", intPtr(1), "n9", "DELETED"); err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := s.GetChunk("big", 0)
+	if err != nil || chunk == nil {
+		t.Fatalf("GetChunk = %v, %v", chunk, err)
+	}
+	if chunk.ChecksumSHA256 != "c0" || !reflect.DeepEqual(chunk.Locations, []string{"n2", "n3"}) {
+		t.Errorf("chunk 0 = %+v", chunk)
+	}
+	if missing, err := s.GetChunk("big", 5); err != nil || missing != nil {
+		t.Errorf("GetChunk(5) = %v, %v; want nil", missing, err)
+	}
+
+	nodes, err := s.GetChunkedFileNodes("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(nodes)
+	if !reflect.DeepEqual(nodes, []string{"n2", "n3"}) {
+		t.Errorf("GetChunkedFileNodes = %v", nodes)
+	}
+
+	locations, err := s.ListNodeLocations("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{chunkObjectKey("big", 0): "CORRUPT"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("ListNodeLocations(n1) = %v, want %v", locations, want)
+	}
+
+	all, err := s.ListObjectLocations("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 || all[0] != (ObjectLocation{NodeID: "n1", BlobKey: chunkObjectKey("big", 0), Status: "CORRUPT"}) {
+		t.Errorf("ListObjectLocations = %+v", all)
+	}
+
+	count, err := s.CountActiveLocationsOnNode("n2")
+	if err != nil || count != 2 {
+		t.Errorf("CountActiveLocationsOnNode(n2) = %d, %v; want 2", count, err)
+	}
+
+	copies, err := s.ListObjectCopies("n3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 2 || *copies[0].ChunkIndex != 0 || *copies[1].ChunkIndex != 1 {
+		t.Fatalf("ListObjectCopies(n3) = %+v", copies)
+	}
+	if copies[0].SizeBytes != 20 || copies[0].target() != 2 {
+		t.Errorf("copy = %+v, target %d", copies[0], copies[0].target())
+	}
+
+	if err := s.DeleteFile("big", nil); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, _ := s.GetChunkManifest("big"); len(manifest) != 0 {
+		t.Errorf("manifest after delete = %+v", manifest)
+	}
+	if locations, _ := s.ListNodeLocations("n2"); len(locations) != 0 {
+		t.Errorf("locations after delete = %v", locations)
+	}
+}
+
+func TestBoltRetireNodeReleasesChunks(t *testing.T) {
+	s := newTestBoltStore(t)
+	if err := s.UpsertNode("n1", "http://n1", "REPLICA", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RegisterFile(FileRegistration{
+		FileKey:        "f",
+		SizeBytes:      10,
+		Chunks:         []FileChunk{{ChunkIndex: 0, SizeBytes: 10, Locations: []string{"n1", "n2"}}},
+		ChunkSizeBytes: 10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RetireNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk, err := s.GetChunk("f", 0)
+	if err != nil || !reflect.DeepEqual(chunk.Locations, []string{"n2"}) {
+		t.Errorf("GetChunk after retire = %+v, %v", chunk, err)
+	}
+	if count, _ := s.CountActiveLocationsOnNode("n1"); count != 0 {
+		t.Errorf("CountActiveLocationsOnNode(n1) = %d, want 0", count)
+	}
+}
+
+func TestBoltErasureManifest(t *testing.T) {
+	s := newTestBoltStore(t)
+	params := ErasureParams{DataShards: 2, ParityShards: 1, BlockSize: 64}
+	if _, err := s.RegisterFile(FileRegistration{
+		FileKey:          "ec",
+		OriginalFilename: "ec.bin",
+		SizeBytes:        100,
+		Checksum:         "sum",
+		NodeIDs:          []string{"n1", "n2", "n3"},
+		Erasure:          &params,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if versions, err := s.ListFileVersions("ec"); err != nil || len(versions) != 1 || versions[0].StorageMode != "ERASURE" {
+		t.Fatalf("ListFileVersions = %+v, %v", versions, err)
+	}
+
+	f, err := s.GetFile("ec")
+	if err != nil || f == nil {
+		t.Fatalf("GetFile = %v, %v", f, err)
+	}
+	if f.StorageMode != "ERASURE" || f.ECDataShards != 2 || f.ECParityShards != 1 || f.ECBlockSize != 64 {
+		t.Errorf("GetFile = %+v", f)
+	}
+
+	shards, err := s.GetShardLocations("ec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"n1": 0, "n2": 1, "n3": 2}; !reflect.DeepEqual(shards, want) {
+		t.Errorf("GetShardLocations = %v, want %v", shards, want)
+	}
+
+	// Shard 1 dibangun ulang di n4 setelah n2 hilang
+	if err := s.UpdateLocationStatus("ec", nil, "n2", "MISSING"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetShardLocationActive("ec", 1, "n4"); err != nil {
+		t.Fatal(err)
+	}
+	shards, _ = s.GetShardLocations("ec")
+	if want := map[string]int{"n1": 0, "n3": 2, "n4": 1}; !reflect.DeepEqual(shards, want) {
+		t.Errorf("GetShardLocations after rebuild = %v, want %v", shards, want)
+	}
+
+	locations, err := s.ListNodeLocations("n2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{shardObjectKey("ec", 1): "MISSING"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("ListNodeLocations(n2) = %v, want %v", locations, want)
+	}
+
+	// Delete juga butuh salinan yang tidak ACTIVE
+	all, err := s.ListObjectLocations("ec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ObjectLocation{
+		{NodeID: "n1", BlobKey: shardObjectKey("ec", 0), Status: "ACTIVE"},
+		{NodeID: "n2", BlobKey: shardObjectKey("ec", 1), Status: "MISSING"},
+		{NodeID: "n3", BlobKey: shardObjectKey("ec", 2), Status: "ACTIVE"},
+		{NodeID: "n4", BlobKey: shardObjectKey("ec", 1), Status: "ACTIVE"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("ListObjectLocations = %+v, want %+v", all, want)
+	}
+
+	copies, err := s.ListObjectCopies("n4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 1 || copies[0].ShardIndex == nil || *copies[0].ShardIndex != 1 || copies[0].StorageMode != "ERASURE" {
+		t.Errorf("ListObjectCopies(n4) = %+v", copies)
+	}
+
+	if err := s.DeleteFile("ec", nil); err != nil {
+		t.Fatal(err)
+	}
+	// Object yang diupload ulang dengan key sama tidak mewarisi index shard lama
+	if err := s.SetFileLocationStatus("ec", "n1", "ACTIVE"); err != nil {
+		t.Fatal(err)
+	}
+	if shards, _ := s.GetShardLocations("ec"); len(shards) != 0 {
+		t.Errorf("shards after delete = %v", shards)
+	}
+}
+
+func TestBoltUploadSession(t *testing.T) {
+	s := newTestBoltStore(t)
+	if err := s.CreateUploadSession("up1", "target", "movie.mp4", 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUploadSession("nope"); !errors.Is(err, errUploadSessionNotFound) {
+		t.Errorf("GetUploadSession(nope) err = %v", err)
+	}
+
+	for _, part := range []UploadPart{
+		{PartNumber: 2, SizeBytes: 5, ChecksumSHA256: "p2", Locations: []string{"n1", "n2"}},
+		{PartNumber: 1, SizeBytes: 10, ChecksumSHA256: "p1", Locations: []string{"n2", "n3"}},
+	} {
+		previous, err := s.RecordUploadPart("up1", part, time.Hour)
+		if err != nil || previous != nil {
+			t.Fatalf("RecordUploadPart(%d) = %v, %v", part.PartNumber, previous, err)
+		}
+	}
+	previous, err := s.RecordUploadPart("up1", UploadPart{PartNumber: 2, SizeBytes: 6, ChecksumSHA256: "p2b", Locations: []string{"n3"}}, time.Hour)
+	if err != nil || !reflect.DeepEqual(previous, []string{"n1", "n2"}) {
+		t.Fatalf("re-upload part 2 = %v, %v", previous, err)
+	}
+
+	session, err := s.GetUploadSession("up1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.FileKey != "target" || session.Status != "ACTIVE" || len(session.Parts) != 2 {
+		t.Fatalf("session = %+v", session)
+	}
+	if session.Parts[0].PartNumber != 1 || session.Parts[1].ChecksumSHA256 != "p2b" {
+		t.Errorf("parts = %+v", session.Parts)
+	}
+
+	// Part yang belum selesai dikenal orphan GC sebagai chunk file tujuan
+	locations, err := s.ListNodeLocations("n3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedKeys(locations), []string{partObjectKey("target", 1), partObjectKey("target", 2)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListNodeLocations(n3) = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		from, to string
+		want     error
+	}{
+		{"COMPLETING", "COMPLETED", errUploadSessionClosed},
+		{"ACTIVE", "COMPLETING", nil},
+		{"ACTIVE", "ABORTED", errUploadSessionClosed},
+	}
+	for _, tt := range tests {
+		if err := s.SetUploadSessionStatus("up1", tt.from, tt.to); !errors.Is(err, tt.want) {
+			t.Errorf("SetUploadSessionStatus(%s -> %s) = %v, want %v", tt.from, tt.to, err, tt.want)
+		}
+	}
+	if _, err := s.RecordUploadPart("up1", UploadPart{PartNumber: 3}, time.Hour); !errors.Is(err, errUploadSessionClosed) {
+		t.Errorf("RecordUploadPart on COMPLETING session err = %v", err)
+	}
+	if err := s.SetUploadSessionStatus("nope", "ACTIVE", "ABORTED"); !errors.Is(err, errUploadSessionNotFound) {
+		t.Errorf("SetUploadSessionStatus(nope) = %v", err)
+	}
+
+	if err := s.CreateUploadSession("up2", "other", "x", 1, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.ListExpiredUploadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].UploadID != "up2" || expired[0].FileKey != "other" {
+		t.Errorf("ListExpiredUploadSessions = %+v", expired)
+	}
+
+	if err := s.DeleteUploadParts("up1"); err != nil {
+		t.Fatal(err)
+	}
+	if session, _ := s.GetUploadSession("up1"); len(session.Parts) != 0 {
+		t.Errorf("parts after delete = %+v", session.Parts)
+	}
+}
+
+func TestBoltDrain(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, id := range []string{"n1", "n2"} {
+		if err := s.UpsertNode(id, "http://"+id, "REPLICA", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.EnqueueReplication("old", nil, nil, "n2", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if err := s.StartDrain("n1", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnqueueReplication("f", nil, nil, "n2", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnqueueReplication("g", nil, nil, "n1", "n2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelReplicationsToNode("n1", "target node draining"); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := s.GetDrainStatus("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.DrainState != "DRAINING" || status.TotalObjects != 7 || status.DrainStartedAt == nil {
+		t.Errorf("GetDrainStatus = %+v", status)
+	}
+	// Item "old" dibuat sebelum drain dimulai sehingga tidak dihitung
+	if status.PendingReplications != 1 || status.DeadReplications != 0 {
+		t.Errorf("pending/dead = %d/%d, want 1/0", status.PendingReplications, status.DeadReplications)
+	}
+	queued, err := s.ListQueuedTargets("g", nil, nil)
+	if err != nil || queued["n1"] != "DEAD" {
+		t.Errorf("queue to draining node = %v, %v; want DEAD", queued, err)
+	}
+
+	if err := s.CancelDrain("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelDrain("n1"); !errors.Is(err, errNodeNotDraining) {
+		t.Errorf("second CancelDrain = %v", err)
+	}
+	if err := s.CancelDrain("ghost"); !errors.Is(err, errNodeNotDraining) {
+		t.Errorf("CancelDrain(ghost) = %v", err)
+	}
+	if status, _ := s.GetDrainStatus("n1"); status.DrainStartedAt != nil || status.DrainState != "NONE" {
+		t.Errorf("status after cancel = %+v", status)
+	}
+
+	if err := s.FinishDrain("n2"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.GetNode("n2"); n.DrainState != "DECOMMISSIONED" {
+		t.Errorf("drain state = %s", n.DrainState)
+	}
+	if _, err := s.GetDrainStatus("ghost"); !errors.Is(err, errNodeNotFound) {
+		t.Errorf("GetDrainStatus(ghost) = %v", err)
+	}
+}
+
+func TestBoltListQueuedTargets(t *testing.T) {
+	s := newTestBoltStore(t)
+	enqueue := []struct {
+		chunk, shard *int
+		target       string
+	}{
+		{nil, nil, "n1"},
+		{intPtr(0), nil, "n2"},
+		{nil, intPtr(1), "n3"},
+		{nil, nil, "n4"},
+	}
+	for _, e := range enqueue {
+		if err := s.EnqueueReplication("f", e.chunk, e.shard, e.target, "src"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// n4: item DEAD lalu item baru, yang masih hidup menang
+	items, _ := s.ListReplications("n4", "", 10)
+	if err := s.FailReplication(items[0].ID, "DEAD", "boom", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnqueueReplication("f", nil, nil, "n4", "src"); err != nil {
+		t.Fatal(err)
+	}
+	// Item COMPLETED diabaikan
+	items, _ = s.ListReplications("n1", "", 10)
+	if err := s.CompleteReplication(items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		chunk, shard *int
+		want         map[string]string
+	}{
+		{"file", nil, nil, map[string]string{"n4": "PENDING"}},
+		{"chunk", intPtr(0), nil, map[string]string{"n2": "PENDING"}},
+		{"other chunk", intPtr(1), nil, map[string]string{}},
+		{"shard", nil, intPtr(1), map[string]string{"n3": "PENDING"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ListQueuedTargets("f", tt.chunk, tt.shard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListQueuedTargets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoltListLostNodes(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, id := range []string{"up", "down-new", "down-old"} {
+		if err := s.UpsertNode(id, "http://"+id, "REPLICA", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetNodeStatus("up", "UP"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"down-new", "down-old"} {
+		if err := s.SetNodeStatus(id, "DOWN"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := s.updateNode("down-old", func(n *Node) error {
+		n.DownSince = &old
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	lost, err := s.ListLostNodes(10 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lost, []string{"down-old"}) {
+		t.Errorf("ListLostNodes = %v, want [down-old]", lost)
+	}
+}
+
+func TestBoltNextFileKey(t *testing.T) {
+	s := newTestBoltStore(t)
+	for _, key := range []string{"b", "a", "c"} {
+		if _, err := s.RegisterFile(FileRegistration{FileKey: key, OriginalFilename: key, SizeBytes: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total, err := s.CountFiles(); err != nil || total != 3 {
+		t.Errorf("CountFiles = %d, %v", total, err)
+	}
+
+	var visited []string
+	for cursor := ""; ; {
+		next, err := s.NextFileKey(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next == "" {
+			break
+		}
+		visited = append(visited, next)
+		cursor = next
+	}
+	if !reflect.DeepEqual(visited, []string{"a", "b", "c"}) {
+		t.Errorf("scrub order = %v", visited)
+	}
+}
@@ -0,0 +1,1516 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// mysqlStore adalah MetadataStore di atas tabel MySQL (schema.sql)
+type mysqlStore struct {
+	db *sql.DB
+}
+
+// openMySQLStore membuka koneksi MySQL dari environment variables dan
+// menunggu sampai MySQL siap
+func openMySQLStore() *mysqlStore {
+	// Baca config dari environment variables atau gunakan default
+	dbHost := getEnv("DB_HOST", "127.0.0.1")
+	dbPort := getEnv("DB_PORT", "3306")
+	dbUser := getEnv("DB_USER", "dfs_user")
+	dbPassword := getEnv("DB_PASSWORD", "admin123")
+	dbName := getEnv("DB_NAME", "dfs_meta")
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPassword, dbHost, dbPort, dbName)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		log.Fatalf("gagal buka koneksi ke MySQL: %v", err)
+	}
+
+	// Retry connection (untuk Docker - MySQL mungkin belum siap)
+	for i := 0; i < 30; i++ {
+		if err := db.Ping(); err == nil {
+			break
+		}
+		log.Printf("Menunggu MySQL... (%d/30)\n", i+1)
+		time.Sleep(2 * time.Second)
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("gagal ping MySQL: %v", err)
+	}
+
+	log.Println("Terhubung ke MySQL dfs_meta")
+	return &mysqlStore{db: db}
+}
+
+func (s *mysqlStore) Name() string { return "mysql" }
+
+func (s *mysqlStore) Close() error { return s.db.Close() }
+
+const nodeColumns = `id, address, status, role, COALESCE(zone, ''), COALESCE(rack, ''), last_heartbeat, COALESCE(latency_ms, 0) as latency_ms,
+	capacity_bytes, free_bytes, inflight_requests, lease_expires_at, down_since,
+	COALESCE(drain_state, 'NONE')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNode(row rowScanner, n *Node) error {
+	return row.Scan(&n.ID, &n.Address, &n.Status, &n.Role, &n.Zone, &n.Rack, &n.LastHeartbeat, &n.LatencyMs,
+		&n.CapacityBytes, &n.FreeBytes, &n.InflightRequests, &n.LeaseExpiresAt, &n.DownSince,
+		&n.DrainState)
+}
+
+func (s *mysqlStore) ListNodes() ([]Node, error) {
+	rows, err := s.db.Query(`SELECT ` + nodeColumns + ` FROM nodes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []Node
+	for rows.Next() {
+		var n Node
+		if err := scanNode(rows, &n); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return nodes, rows.Err()
+}
+
+func (s *mysqlStore) GetNode(nodeID string) (*Node, error) {
+	var n Node
+	err := scanNode(s.db.QueryRow(`SELECT `+nodeColumns+` FROM nodes WHERE id = ?`, nodeID), &n)
+	if err == sql.ErrNoRows {
+		return nil, errNodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (s *mysqlStore) UpsertNode(nodeID, address, role, zone, rack string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO nodes (id, address, status, role, zone, rack, latency_ms)
+		VALUES (?, ?, 'DOWN', ?, NULLIF(?, ''), NULLIF(?, ''), 0)
+		ON DUPLICATE KEY UPDATE
+			address = VALUES(address),
+			status = 'DOWN',
+			role = VALUES(role),
+			zone = VALUES(zone),
+			rack = VALUES(rack),
+			latency_ms = 0,
+			lease_expires_at = NULL,
+			drain_state = 'NONE',
+			drain_started_at = NULL,
+			drain_total_objects = NULL
+	`, nodeID, address, role, zone, rack)
+	return err
+}
+
+func (s *mysqlStore) UpdateNode(nodeID, address, role, zone, rack string) error {
+	result, err := s.db.Exec(`
+		UPDATE nodes
+		SET address = ?, role = ?, zone = NULLIF(?, ''), rack = NULLIF(?, '')
+		WHERE id = ? AND status <> 'RETIRED'
+	`, address, role, zone, rack, nodeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		// RowsAffected juga 0 kalau nilai tidak berubah, jadi cek ulang keberadaan node
+		n, err := s.GetNode(nodeID)
+		if err != nil {
+			return err
+		}
+		if n.Status == "RETIRED" {
+			return errNodeNotFound
+		}
+	}
+	return nil
+}
+
+func (s *mysqlStore) SetNodeStatus(nodeID, status string) error {
+	_, err := s.db.Exec(`
+		UPDATE nodes
+		SET status = ?, last_heartbeat = NOW(),
+			down_since = CASE WHEN ? = 'DOWN' THEN COALESCE(down_since, NOW()) ELSE NULL END
+		WHERE id = ?
+	`, status, status, nodeID)
+	return err
+}
+
+func (s *mysqlStore) SetNodeLatency(nodeID string, latencyMs int64) error {
+	_, err := s.db.Exec(`
+		UPDATE nodes
+		SET latency_ms = ?
+		WHERE id = ?
+	`, latencyMs, nodeID)
+	return err
+}
+
+func (s *mysqlStore) SetNodeRole(nodeID, role string) error {
+	result, err := s.db.Exec(`UPDATE nodes SET role = ? WHERE id = ? AND status <> 'RETIRED'`, role, nodeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		if _, err := s.GetNode(nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mysqlStore) PromoteNode(nodeID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM nodes WHERE id = ? FOR UPDATE`, nodeID).Scan(&status)
+	if err == sql.ErrNoRows || status == "RETIRED" {
+		return errNodeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE nodes SET role = 'REPLICA' WHERE role = 'MAIN' AND id <> ?`, nodeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE nodes SET role = 'MAIN' WHERE id = ?`, nodeID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *mysqlStore) RecordHeartbeat(nodeID string, report HeartbeatReport, lease time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE nodes
+		SET status = 'UP',
+			down_since = NULL,
+			last_heartbeat = NOW(),
+			lease_expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND),
+			capacity_bytes = COALESCE(?, capacity_bytes),
+			free_bytes = COALESCE(?, free_bytes),
+			inflight_requests = COALESCE(?, inflight_requests)
+		WHERE id = ?
+	`, int(lease.Seconds()), report.CapacityBytes, report.FreeBytes, report.InflightRequests, nodeID)
+	return err
+}
+
+func (s *mysqlStore) ExpireLeases() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM nodes
+		WHERE status = 'UP' AND lease_expires_at IS NOT NULL AND lease_expires_at < NOW()
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range expired {
+		// Kondisi diulang supaya heartbeat yang masuk di antara SELECT dan UPDATE tidak tertimpa
+		if _, err := s.db.Exec(`
+			UPDATE nodes
+			SET status = 'DOWN', lease_expires_at = NULL, down_since = COALESCE(down_since, NOW())
+			WHERE id = ? AND lease_expires_at < NOW()
+		`, id); err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// RetireNode tidak menghapus row karena file_locations dan replication_queue
+// memakai FOREIGN KEY ... ON DELETE CASCADE. Lokasi file di node tersebut
+// ditandai DELETED dan antrian replikasi ke node itu dibuang.
+func (s *mysqlStore) RetireNode(nodeID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE nodes SET status = 'RETIRED', lease_expires_at = NULL WHERE id = ?`, nodeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE file_locations SET status = 'DELETED'
+		WHERE node_id = ? AND status = 'ACTIVE'
+	`, nodeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE chunk_locations SET status = 'DELETED'
+		WHERE node_id = ? AND status = 'ACTIVE'
+	`, nodeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM replication_queue
+		WHERE target_node_id = ? AND status IN ('PENDING', 'FAILED')
+	`, nodeID); err != nil {
+		return err
+	}
+	// Data node RETIRED dianggap sudah hilang, delete tertunda tidak perlu diulang
+	if _, err := tx.Exec(`DELETE FROM delete_tombstones WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *mysqlStore) CountActiveLocationsOnNode(nodeID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM file_locations WHERE node_id = ? AND status = 'ACTIVE') +
+			(SELECT COUNT(*) FROM chunk_locations WHERE node_id = ? AND status = 'ACTIVE')
+	`, nodeID, nodeID).Scan(&count)
+	return count, err
+}
+
+func (s *mysqlStore) RegisterFile(reg FileRegistration) (bool, error) {
+	objectKey := reg.objectKey()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if reg.IdempotencyKey != "" {
+		// Retry dengan key yang sama menunggu lock row ini sampai transaksi pertama selesai
+		result, err := tx.Exec(`
+			INSERT IGNORE INTO idempotency_keys (idempotency_key, file_key) VALUES (?, ?)
+		`, reg.IdempotencyKey, reg.FileKey)
+		if err != nil {
+			return false, err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			var fileKey string
+			if err := tx.QueryRow(`
+				SELECT file_key FROM idempotency_keys WHERE idempotency_key = ?
+			`, reg.IdempotencyKey).Scan(&fileKey); err != nil {
+				return false, err
+			}
+			if fileKey != reg.FileKey {
+				return false, errIdempotencyKeyReused
+			}
+			return true, nil
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO files (file_key, original_filename, size_bytes, checksum_sha256, replication_factor)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			original_filename = VALUES(original_filename),
+			size_bytes = VALUES(size_bytes),
+			checksum_sha256 = VALUES(checksum_sha256),
+			replication_factor = COALESCE(VALUES(replication_factor), replication_factor),
+			trashed_at = NULL
+	`, objectKey, reg.OriginalFilename, reg.SizeBytes, reg.Checksum, reg.ReplicationFactor); err != nil {
+		return false, err
+	}
+	if _, err := addFileVersionTx(tx, reg.FileKey, objectKey); err != nil {
+		return false, err
+	}
+
+	if reg.Erasure != nil {
+		if err := saveErasureManifestTx(tx, objectKey, *reg.Erasure, reg.NodeIDs); err != nil {
+			return false, err
+		}
+	} else if len(reg.Chunks) > 0 {
+		if err := saveChunkManifestTx(tx, objectKey, reg.ChunkSizeBytes, reg.Chunks); err != nil {
+			return false, err
+		}
+	} else {
+		for _, nodeID := range reg.NodeIDs {
+			if _, err := tx.Exec(`
+				INSERT INTO file_locations (file_key, node_id, status)
+				VALUES (?, ?, 'ACTIVE')
+				ON DUPLICATE KEY UPDATE status = 'ACTIVE'
+			`, objectKey, nodeID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// Tanpa idempotency key pun, target yang sudah punya item terbuka tidak diantrikan lagi
+	for _, nodeID := range reg.FailedNodeIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO replication_queue (file_key, target_node_id, source_node_id, status)
+			SELECT ?, ?, ?, 'PENDING' FROM DUAL
+			WHERE NOT EXISTS (
+				SELECT 1 FROM replication_queue
+				WHERE file_key = ? AND target_node_id = ? AND chunk_index IS NULL AND shard_index IS NULL
+					AND status IN ('PENDING', 'FAILED', 'IN_PROGRESS')
+			)
+		`, objectKey, nodeID, reg.SourceNodeID, objectKey, nodeID); err != nil {
+			return false, err
+		}
+	}
+
+	return false, tx.Commit()
+}
+
+func (s *mysqlStore) PruneIdempotencyKeys(olderThan time.Duration) (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM idempotency_keys WHERE created_at < DATE_SUB(NOW(), INTERVAL ? SECOND)
+	`, int64(olderThan.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *mysqlStore) GetFile(fileKey string) (*FileMetadata, error) {
+	var f FileMetadata
+	err := s.db.QueryRow(`
+		SELECT file_key, original_filename, size_bytes, COALESCE(checksum_sha256, ''), uploaded_at, storage_mode,
+			COALESCE(ec_data_shards, 0), COALESCE(ec_parity_shards, 0), COALESCE(ec_block_size, 0), trashed_at
+		FROM files
+		WHERE file_key = ?
+	`, fileKey).Scan(&f.FileKey, &f.OriginalFilename, &f.SizeBytes, &f.ChecksumSHA256, &f.UploadedAt, &f.StorageMode,
+		&f.ECDataShards, &f.ECParityShards, &f.ECBlockSize, &f.TrashedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *mysqlStore) ListFiles(limit int) ([]FileMetadata, error) {
+	// Satu row per file_key: versi terbaru di luar trash, atau row files tanpa
+	// riwayat versi
+	rows, err := s.db.Query(`
+		SELECT COALESCE(v.file_key, f.file_key), f.file_key, COALESCE(v.version, 1),
+			f.original_filename, f.size_bytes, COALESCE(f.checksum_sha256, ''), f.uploaded_at, f.storage_mode
+		FROM files f
+		LEFT JOIN file_versions v ON v.object_key = f.file_key
+		WHERE f.trashed_at IS NULL
+			AND (v.file_key IS NULL OR v.version = (
+				SELECT MAX(v2.version) FROM file_versions v2
+				JOIN files f2 ON f2.file_key = v2.object_key
+				WHERE v2.file_key = v.file_key AND f2.trashed_at IS NULL
+			))
+		ORDER BY f.uploaded_at DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []FileMetadata
+	for rows.Next() {
+		var f FileMetadata
+		if err := rows.Scan(&f.FileKey, &f.ObjectKey, &f.Version,
+			&f.OriginalFilename, &f.SizeBytes, &f.ChecksumSHA256, &f.UploadedAt, &f.StorageMode); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+func (s *mysqlStore) DeleteFile(fileKey string, tombstones []DeleteTombstone) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tombstones {
+		if _, err := tx.Exec(`
+			INSERT IGNORE INTO delete_tombstones (node_id, file_key, blob_key) VALUES (?, ?, ?)
+		`, t.NodeID, t.FileKey, t.BlobKey); err != nil {
+			return err
+		}
+	}
+
+	for _, query := range []string{
+		`DELETE FROM file_versions WHERE object_key = ?`,
+		`DELETE FROM replication_queue WHERE file_key = ?`,
+		`DELETE FROM file_locations WHERE file_key = ?`,
+		`DELETE FROM chunk_locations WHERE file_key = ?`,
+		`DELETE FROM file_chunks WHERE file_key = ?`,
+		`DELETE FROM files WHERE file_key = ?`,
+	} {
+		if _, err := tx.Exec(query, fileKey); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addFileVersionTx mencatat objectKey (row files-nya sudah ada) sebagai versi
+// terbaru fileKey dan mengembalikan nomor versinya. Object yang sudah tercatat
+// sebagai versi fileKey tidak ditambah lagi (register ulang object yang sama).
+func addFileVersionTx(tx *sql.Tx, fileKey, objectKey string) (int, error) {
+	var version int
+	err := tx.QueryRow(`
+		SELECT version FROM file_versions WHERE file_key = ? AND object_key = ?
+	`, fileKey, objectKey).Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	var latest int
+	if err := tx.QueryRow(`
+		SELECT COALESCE(MAX(version), 0) FROM file_versions WHERE file_key = ? FOR UPDATE
+	`, fileKey).Scan(&latest); err != nil {
+		return 0, err
+	}
+
+	// File lama tanpa riwayat: row files dengan key fileKey menjadi versi 1
+	if latest == 0 && objectKey != fileKey {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM files WHERE file_key = ?`, fileKey).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists > 0 {
+			if _, err := tx.Exec(`
+				INSERT INTO file_versions (file_key, version, object_key) VALUES (?, 1, ?)
+			`, fileKey, fileKey); err != nil {
+				return 0, err
+			}
+			latest = 1
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO file_versions (file_key, version, object_key) VALUES (?, ?, ?)
+	`, fileKey, latest+1, objectKey); err != nil {
+		return 0, err
+	}
+	return latest + 1, nil
+}
+
+func (s *mysqlStore) ListFileVersions(fileKey string) ([]FileVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT v.file_key, v.version, v.object_key, f.original_filename, f.size_bytes,
+			COALESCE(f.checksum_sha256, ''), f.storage_mode, f.uploaded_at, f.trashed_at
+		FROM file_versions v
+		JOIN files f ON f.file_key = v.object_key
+		WHERE v.file_key = ?
+		ORDER BY v.version DESC
+	`, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []FileVersion
+	for rows.Next() {
+		var v FileVersion
+		if err := rows.Scan(&v.FileKey, &v.Version, &v.ObjectKey, &v.OriginalFilename, &v.SizeBytes,
+			&v.ChecksumSHA256, &v.StorageMode, &v.UploadedAt, &v.TrashedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		markLatestVersion(versions)
+		return versions, nil
+	}
+
+	// Tanpa riwayat: row files fileKey adalah versi 1, kecuali row itu
+	// sendiri object versi dari file_key lain
+	var owned int
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM file_versions WHERE object_key = ?
+	`, fileKey).Scan(&owned); err != nil || owned > 0 {
+		return nil, err
+	}
+	f, err := s.GetFile(fileKey)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return []FileVersion{implicitFileVersion(f)}, nil
+}
+
+func (s *mysqlStore) TrashFile(objectKey string) error {
+	_, err := s.db.Exec(`
+		UPDATE files SET trashed_at = NOW() WHERE file_key = ? AND trashed_at IS NULL
+	`, objectKey)
+	return err
+}
+
+func (s *mysqlStore) RestoreFile(objectKey string) error {
+	_, err := s.db.Exec(`UPDATE files SET trashed_at = NULL WHERE file_key = ?`, objectKey)
+	return err
+}
+
+func (s *mysqlStore) ListTrash(limit int) ([]FileVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT COALESCE(v.file_key, f.file_key), COALESCE(v.version, 1), f.file_key, f.original_filename,
+			f.size_bytes, COALESCE(f.checksum_sha256, ''), f.storage_mode, f.uploaded_at, f.trashed_at
+		FROM files f
+		LEFT JOIN file_versions v ON v.object_key = f.file_key
+		WHERE f.trashed_at IS NOT NULL
+		ORDER BY f.trashed_at DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trashed []FileVersion
+	for rows.Next() {
+		var v FileVersion
+		if err := rows.Scan(&v.FileKey, &v.Version, &v.ObjectKey, &v.OriginalFilename,
+			&v.SizeBytes, &v.ChecksumSHA256, &v.StorageMode, &v.UploadedAt, &v.TrashedAt); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, v)
+	}
+	return trashed, rows.Err()
+}
+
+func (s *mysqlStore) ListExpiredTrash(olderThan time.Duration, limit int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT file_key FROM files
+		WHERE trashed_at < DATE_SUB(NOW(), INTERVAL ? SECOND)
+		ORDER BY trashed_at
+		LIMIT ?
+	`, int64(olderThan.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objectKeys []string
+	for rows.Next() {
+		var objectKey string
+		if err := rows.Scan(&objectKey); err != nil {
+			return nil, err
+		}
+		objectKeys = append(objectKeys, objectKey)
+	}
+	return objectKeys, rows.Err()
+}
+
+func (s *mysqlStore) ListTombstones(nodeID string, limit int) ([]DeleteTombstone, error) {
+	rows, err := s.db.Query(`
+		SELECT id, node_id, file_key, blob_key, attempts, last_attempt, COALESCE(last_error, ''), created_at
+		FROM delete_tombstones
+		WHERE ? = '' OR node_id = ?
+		ORDER BY id
+		LIMIT ?
+	`, nodeID, nodeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tombstones []DeleteTombstone
+	for rows.Next() {
+		var t DeleteTombstone
+		if err := rows.Scan(&t.ID, &t.NodeID, &t.FileKey, &t.BlobKey, &t.Attempts, &t.LastAttempt, &t.LastError, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+func (s *mysqlStore) DeleteTombstone(id int) error {
+	_, err := s.db.Exec(`DELETE FROM delete_tombstones WHERE id = ?`, id)
+	return err
+}
+
+func (s *mysqlStore) FailTombstone(id int, errorMsg string) error {
+	_, err := s.db.Exec(`
+		UPDATE delete_tombstones
+		SET attempts = attempts + 1, last_attempt = NOW(), last_error = ?
+		WHERE id = ?
+	`, errorMsg, id)
+	return err
+}
+
+func (s *mysqlStore) GetFileLocations(fileKey string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT node_id FROM file_locations
+		WHERE file_key = ? AND status = 'ACTIVE'
+	`, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodeIDs []string
+	for rows.Next() {
+		var nodeID string
+		if err := rows.Scan(&nodeID); err != nil {
+			return nil, err
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+
+	return nodeIDs, rows.Err()
+}
+
+func (s *mysqlStore) ListObjectLocations(fileKey string) ([]ObjectLocation, error) {
+	rows, err := s.db.Query(`
+		SELECT node_id, shard_index, NULL, status FROM file_locations WHERE file_key = ?
+		UNION ALL
+		SELECT node_id, NULL, chunk_index, status FROM chunk_locations WHERE file_key = ?
+	`, fileKey, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []ObjectLocation
+	for rows.Next() {
+		var loc ObjectLocation
+		var shardIndex, chunkIndex sql.NullInt64
+		if err := rows.Scan(&loc.NodeID, &shardIndex, &chunkIndex, &loc.Status); err != nil {
+			return nil, err
+		}
+		switch {
+		case shardIndex.Valid:
+			loc.BlobKey = shardObjectKey(fileKey, int(shardIndex.Int64))
+		case chunkIndex.Valid:
+			loc.BlobKey = chunkObjectKey(fileKey, int(chunkIndex.Int64))
+		default:
+			loc.BlobKey = fileKey
+		}
+		locations = append(locations, loc)
+	}
+	return locations, rows.Err()
+}
+
+func (s *mysqlStore) ListNodeLocations(nodeID string) (map[string]string, error) {
+	rows, err := s.db.Query(`
+		SELECT file_key, shard_index, NULL, status FROM file_locations WHERE node_id = ?
+		UNION ALL
+		SELECT file_key, NULL, chunk_index, status FROM chunk_locations WHERE node_id = ?
+	`, nodeID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := make(map[string]string)
+	for rows.Next() {
+		var fileKey, status string
+		var shardIndex, chunkIndex sql.NullInt64
+		if err := rows.Scan(&fileKey, &shardIndex, &chunkIndex, &status); err != nil {
+			return nil, err
+		}
+		switch {
+		case shardIndex.Valid:
+			fileKey = shardObjectKey(fileKey, int(shardIndex.Int64))
+		case chunkIndex.Valid:
+			fileKey = chunkObjectKey(fileKey, int(chunkIndex.Int64))
+		}
+		locations[fileKey] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Part upload session yang belum selesai belum tercatat di chunk_locations
+	partRows, err := s.db.Query(`
+		SELECT s.file_key, p.part_number, p.node_ids
+		FROM upload_parts p
+		JOIN upload_sessions s ON s.upload_id = p.upload_id
+		WHERE s.status IN ('ACTIVE', 'COMPLETING')
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer partRows.Close()
+	for partRows.Next() {
+		var fileKey, nodeIDs string
+		var partNumber int
+		if err := partRows.Scan(&fileKey, &partNumber, &nodeIDs); err != nil {
+			return nil, err
+		}
+		for _, id := range strings.Split(nodeIDs, ",") {
+			if id == nodeID {
+				locations[partObjectKey(fileKey, partNumber)] = "ACTIVE"
+			}
+		}
+	}
+	return locations, partRows.Err()
+}
+
+func (s *mysqlStore) SetFileLocationStatus(fileKey, nodeID, status string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO file_locations (file_key, node_id, status)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE status = VALUES(status)
+	`, fileKey, nodeID, status)
+	return err
+}
+
+func (s *mysqlStore) UpdateLocationStatus(fileKey string, chunkIndex *int, nodeID, status string) error {
+	var err error
+	if chunkIndex != nil {
+		_, err = s.db.Exec(`
+			UPDATE chunk_locations SET status = ?
+			WHERE file_key = ? AND chunk_index = ? AND node_id = ?
+		`, status, fileKey, *chunkIndex, nodeID)
+	} else {
+		_, err = s.db.Exec(`
+			UPDATE file_locations SET status = ?
+			WHERE file_key = ? AND node_id = ?
+		`, status, fileKey, nodeID)
+	}
+	return err
+}
+
+func (s *mysqlStore) ListObjectCopies(nodeID string) ([]ObjectCopy, error) {
+	fileFilter, chunkFilter := "", ""
+	var args []interface{}
+	if nodeID != "" {
+		fileFilter, chunkFilter = " AND fl.node_id = ?", " AND cl.node_id = ?"
+		args = []interface{}{nodeID, nodeID}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT fl.node_id, fl.file_key, NULL, fl.shard_index, f.storage_mode, f.size_bytes, f.replication_factor
+		FROM file_locations fl
+		JOIN files f ON f.file_key = fl.file_key
+		WHERE fl.status = 'ACTIVE'`+fileFilter+`
+		UNION ALL
+		SELECT cl.node_id, cl.file_key, cl.chunk_index, NULL, f.storage_mode, fc.size_bytes, f.replication_factor
+		FROM chunk_locations cl
+		JOIN file_chunks fc ON fc.file_key = cl.file_key AND fc.chunk_index = cl.chunk_index
+		JOIN files f ON f.file_key = cl.file_key
+		WHERE cl.status = 'ACTIVE'`+chunkFilter+`
+		ORDER BY 2, 3
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var copies []ObjectCopy
+	for rows.Next() {
+		var c ObjectCopy
+		if err := rows.Scan(&c.NodeID, &c.FileKey, &c.ChunkIndex, &c.ShardIndex, &c.StorageMode, &c.SizeBytes, &c.ReplicationFactor); err != nil {
+			return nil, err
+		}
+		copies = append(copies, c)
+	}
+	return copies, rows.Err()
+}
+
+func (s *mysqlStore) NextFileKey(cursor string) (string, error) {
+	var fileKey string
+	err := s.db.QueryRow(`
+		SELECT file_key FROM files WHERE file_key > ? ORDER BY file_key ASC LIMIT 1
+	`, cursor).Scan(&fileKey)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return fileKey, err
+}
+
+func (s *mysqlStore) CountFiles() (int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&total)
+	return total, err
+}
+
+func saveChunkManifestTx(tx *sql.Tx, fileKey string, chunkSize int64, chunks []FileChunk) error {
+	if _, err := tx.Exec(`
+		UPDATE files SET storage_mode = 'CHUNKED', chunk_size_bytes = ?
+		WHERE file_key = ?
+	`, chunkSize, fileKey); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if _, err := tx.Exec(`
+			INSERT INTO file_chunks (file_key, chunk_index, size_bytes, checksum_sha256)
+			VALUES (?, ?, ?, ?)
+		`, fileKey, chunk.ChunkIndex, chunk.SizeBytes, chunk.ChecksumSHA256); err != nil {
+			return err
+		}
+		for _, nodeID := range chunk.Locations {
+			if _, err := tx.Exec(`
+				INSERT INTO chunk_locations (file_key, chunk_index, node_id, status)
+				VALUES (?, ?, ?, 'ACTIVE')
+			`, fileKey, chunk.ChunkIndex, nodeID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *mysqlStore) GetChunkManifest(fileKey string) ([]FileChunk, error) {
+	rows, err := s.db.Query(`
+		SELECT c.chunk_index, c.size_bytes, c.checksum_sha256, l.node_id
+		FROM file_chunks c
+		LEFT JOIN chunk_locations l
+			ON l.file_key = c.file_key AND l.chunk_index = c.chunk_index AND l.status = 'ACTIVE'
+		WHERE c.file_key = ?
+		ORDER BY c.chunk_index ASC
+	`, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []FileChunk
+	for rows.Next() {
+		var chunk FileChunk
+		var nodeID sql.NullString
+		if err := rows.Scan(&chunk.ChunkIndex, &chunk.SizeBytes, &chunk.ChecksumSHA256, &nodeID); err != nil {
+			return nil, err
+		}
+		if n := len(chunks); n > 0 && chunks[n-1].ChunkIndex == chunk.ChunkIndex {
+			if nodeID.Valid {
+				chunks[n-1].Locations = append(chunks[n-1].Locations, nodeID.String)
+			}
+			continue
+		}
+		chunk.FileKey = fileKey
+		if nodeID.Valid {
+			chunk.Locations = []string{nodeID.String}
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
+
+func (s *mysqlStore) GetChunk(fileKey string, chunkIndex int) (*FileChunk, error) {
+	rows, err := s.db.Query(`
+		SELECT c.size_bytes, COALESCE(c.checksum_sha256, ''), l.node_id
+		FROM file_chunks c
+		LEFT JOIN chunk_locations l
+			ON l.file_key = c.file_key AND l.chunk_index = c.chunk_index AND l.status = 'ACTIVE'
+		WHERE c.file_key = ? AND c.chunk_index = ?
+	`, fileKey, chunkIndex)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunk *FileChunk
+	for rows.Next() {
+		if chunk == nil {
+			chunk = &FileChunk{FileKey: fileKey, ChunkIndex: chunkIndex}
+		}
+		var nodeID sql.NullString
+		if err := rows.Scan(&chunk.SizeBytes, &chunk.ChecksumSHA256, &nodeID); err != nil {
+			return nil, err
+		}
+		if nodeID.Valid {
+			chunk.Locations = append(chunk.Locations, nodeID.String)
+		}
+	}
+	return chunk, rows.Err()
+}
+
+func (s *mysqlStore) SetChunkLocationActive(fileKey string, chunkIndex int, nodeID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO chunk_locations (file_key, chunk_index, node_id, status)
+		VALUES (?, ?, ?, 'ACTIVE')
+		ON DUPLICATE KEY UPDATE status = 'ACTIVE'
+	`, fileKey, chunkIndex, nodeID)
+	return err
+}
+
+func (s *mysqlStore) GetChunkedFileNodes(fileKey string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT node_id FROM chunk_locations
+		WHERE file_key = ? AND status = 'ACTIVE'
+	`, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodeIDs []string
+	for rows.Next() {
+		var nodeID string
+		if err := rows.Scan(&nodeID); err != nil {
+			return nil, err
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+
+	return nodeIDs, rows.Err()
+}
+
+func saveErasureManifestTx(tx *sql.Tx, fileKey string, params ErasureParams, nodeIDs []string) error {
+	if _, err := tx.Exec(`
+		UPDATE files
+		SET storage_mode = 'ERASURE', ec_data_shards = ?, ec_parity_shards = ?, ec_block_size = ?
+		WHERE file_key = ?
+	`, params.DataShards, params.ParityShards, params.BlockSize, fileKey); err != nil {
+		return err
+	}
+
+	for i, nodeID := range nodeIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO file_locations (file_key, node_id, status, shard_index)
+			VALUES (?, ?, 'ACTIVE', ?)
+			ON DUPLICATE KEY UPDATE status = 'ACTIVE', shard_index = VALUES(shard_index)
+		`, fileKey, nodeID, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *mysqlStore) SetShardLocationActive(fileKey string, shardIndex int, nodeID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO file_locations (file_key, node_id, status, shard_index)
+		VALUES (?, ?, 'ACTIVE', ?)
+		ON DUPLICATE KEY UPDATE status = 'ACTIVE', shard_index = VALUES(shard_index)
+	`, fileKey, nodeID, shardIndex)
+	return err
+}
+
+func (s *mysqlStore) GetShardLocations(fileKey string) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT node_id, shard_index FROM file_locations
+		WHERE file_key = ? AND status = 'ACTIVE' AND shard_index IS NOT NULL
+	`, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shards := make(map[string]int)
+	for rows.Next() {
+		var nodeID string
+		var index int
+		if err := rows.Scan(&nodeID, &index); err != nil {
+			return nil, err
+		}
+		shards[nodeID] = index
+	}
+
+	return shards, rows.Err()
+}
+
+const replicationQueueColumns = `id, file_key, target_node_id, source_node_id, status, retry_count, last_attempt, created_at, chunk_index,
+	COALESCE(error_message, ''), lease_owner, lease_expires_at, next_attempt_at, shard_index`
+
+func scanReplicationQueueItem(row rowScanner, item *ReplicationQueueItem) error {
+	return row.Scan(&item.ID, &item.FileKey, &item.TargetNodeID, &item.SourceNodeID,
+		&item.Status, &item.RetryCount, &item.LastAttempt, &item.CreatedAt, &item.ChunkIndex,
+		&item.ErrorMessage, &item.LeaseOwner, &item.LeaseExpiresAt, &item.NextAttemptAt, &item.ShardIndex)
+}
+
+func (s *mysqlStore) EnqueueReplication(fileKey string, chunkIndex, shardIndex *int, targetNodeID, sourceNodeID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO replication_queue (file_key, chunk_index, shard_index, target_node_id, source_node_id, status)
+		VALUES (?, ?, ?, ?, ?, 'PENDING')
+	`, fileKey, chunkIndex, shardIndex, targetNodeID, sourceNodeID)
+	return err
+}
+
+func (s *mysqlStore) ListReplications(targetNodeID, status string, limit int) ([]ReplicationQueueItem, error) {
+	query := "SELECT " + replicationQueueColumns + " FROM replication_queue WHERE 1=1"
+	args := []interface{}{}
+
+	if targetNodeID != "" {
+		query += " AND target_node_id = ?"
+		args = append(args, targetNodeID)
+	}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ReplicationQueueItem
+	for rows.Next() {
+		var item ReplicationQueueItem
+		if err := scanReplicationQueueItem(rows, &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *mysqlStore) LeaseReplications(owner, targetNodeID string, limit int, lease time.Duration) ([]ReplicationQueueItem, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT q.id FROM replication_queue q
+		JOIN nodes t ON t.id = q.target_node_id
+		WHERE q.status IN ('PENDING', 'FAILED')`
+	args := []interface{}{}
+	if targetNodeID != "" {
+		query += ` AND q.target_node_id = ?`
+		args = append(args, targetNodeID)
+	} else {
+		query += ` AND t.status = 'UP' AND (q.next_attempt_at IS NULL OR q.next_attempt_at <= NOW())`
+	}
+	query += ` ORDER BY q.created_at ASC LIMIT ? FOR UPDATE OF q SKIP LOCKED`
+	args = append(args, limit)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args = append([]interface{}{owner, int64(lease.Seconds())}, ids...)
+	if _, err := tx.Exec(`
+		UPDATE replication_queue
+		SET status = 'IN_PROGRESS', lease_owner = ?,
+			lease_expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND), last_attempt = NOW()
+		WHERE id IN (`+placeholders+`)
+	`, args...); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT `+replicationQueueColumns+` FROM replication_queue
+		WHERE id IN (`+placeholders+`) ORDER BY created_at ASC
+	`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ReplicationQueueItem
+	for rows.Next() {
+		var item ReplicationQueueItem
+		if err := scanReplicationQueueItem(rows, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, tx.Commit()
+}
+
+func (s *mysqlStore) ExtendReplicationLease(queueID int, owner string, lease time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE replication_queue SET lease_expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		WHERE id = ? AND status = 'IN_PROGRESS' AND lease_owner = ?
+	`, int64(lease.Seconds()), queueID, owner)
+	return err
+}
+
+func (s *mysqlStore) CompleteReplication(queueID int) error {
+	_, err := s.db.Exec(`
+		UPDATE replication_queue
+		SET status = 'COMPLETED', completed_at = NOW(), lease_owner = NULL, lease_expires_at = NULL
+		WHERE id = ?
+	`, queueID)
+	return err
+}
+
+func (s *mysqlStore) FailReplication(queueID int, status, errorMsg string, retryAfter time.Duration) error {
+	_, err := s.db.Exec(`
+		UPDATE replication_queue
+		SET status = ?, retry_count = retry_count + 1, last_attempt = NOW(), error_message = ?,
+			next_attempt_at = DATE_ADD(NOW(), INTERVAL ? SECOND), lease_owner = NULL, lease_expires_at = NULL
+		WHERE id = ?
+	`, status, errorMsg, int64(retryAfter.Seconds()), queueID)
+	return err
+}
+
+func (s *mysqlStore) ReclaimExpiredReplications() (int64, error) {
+	result, err := s.db.Exec(`
+		UPDATE replication_queue
+		SET status = 'PENDING', lease_owner = NULL, lease_expires_at = NULL
+		WHERE status = 'IN_PROGRESS' AND (lease_expires_at IS NULL OR lease_expires_at < NOW())
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *mysqlStore) ResetReplicationBackoff(nodeID string) error {
+	_, err := s.db.Exec(`
+		UPDATE replication_queue SET next_attempt_at = NULL
+		WHERE status IN ('PENDING', 'FAILED') AND (target_node_id = ? OR source_node_id = ?)
+	`, nodeID, nodeID)
+	return err
+}
+
+func (s *mysqlStore) RetryDeadReplication(queueID int) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE replication_queue
+		SET status = 'PENDING', retry_count = 0, next_attempt_at = NULL, error_message = NULL
+		WHERE id = ? AND status = 'DEAD'
+	`, queueID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func (s *mysqlStore) ListQueuedTargets(fileKey string, chunkIndex, shardIndex *int) (map[string]string, error) {
+	rows, err := s.db.Query(`
+		SELECT target_node_id, status FROM replication_queue
+		WHERE file_key = ? AND chunk_index <=> ? AND shard_index <=> ? AND status <> 'COMPLETED'
+	`, fileKey, chunkIndex, shardIndex)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make(map[string]string)
+	for rows.Next() {
+		var nodeID, status string
+		if err := rows.Scan(&nodeID, &status); err != nil {
+			return nil, err
+		}
+		// Jika ada beberapa item untuk target yang sama, yang masih hidup menang
+		if prev, ok := targets[nodeID]; !ok || prev == "DEAD" {
+			targets[nodeID] = status
+		}
+	}
+	return targets, rows.Err()
+}
+
+func (s *mysqlStore) CancelReplicationsToNode(nodeID, reason string) error {
+	_, err := s.db.Exec(`
+		UPDATE replication_queue
+		SET status = 'DEAD', error_message = ?
+		WHERE target_node_id = ? AND status IN ('PENDING', 'FAILED')
+	`, reason, nodeID)
+	return err
+}
+
+func (s *mysqlStore) CreateUploadSession(uploadID, fileKey, originalFilename string, replicationFactor int, ttl time.Duration) error {
+	_, err := s.db.Exec(`
+		INSERT INTO upload_sessions (upload_id, file_key, original_filename, replication_factor, status, expires_at)
+		VALUES (?, ?, ?, ?, 'ACTIVE', DATE_ADD(NOW(), INTERVAL ? SECOND))
+	`, uploadID, fileKey, originalFilename, replicationFactor, int64(ttl.Seconds()))
+	return err
+}
+
+func (s *mysqlStore) GetUploadSession(uploadID string) (*UploadSession, error) {
+	var us UploadSession
+	err := s.db.QueryRow(`
+		SELECT upload_id, file_key, original_filename, replication_factor, status, created_at, expires_at
+		FROM upload_sessions WHERE upload_id = ?
+	`, uploadID).Scan(&us.UploadID, &us.FileKey, &us.OriginalFilename, &us.ReplicationFactor, &us.Status, &us.CreatedAt, &us.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT part_number, size_bytes, checksum_sha256, node_ids, uploaded_at
+		FROM upload_parts WHERE upload_id = ?
+		ORDER BY part_number ASC
+	`, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	us.Parts = []UploadPart{}
+	for rows.Next() {
+		var p UploadPart
+		var nodeIDs string
+		if err := rows.Scan(&p.PartNumber, &p.SizeBytes, &p.ChecksumSHA256, &nodeIDs, &p.UploadedAt); err != nil {
+			return nil, err
+		}
+		p.Locations = strings.Split(nodeIDs, ",")
+		us.Parts = append(us.Parts, p)
+	}
+
+	return &us, rows.Err()
+}
+
+func (s *mysqlStore) RecordUploadPart(uploadID string, part UploadPart, ttl time.Duration) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM upload_sessions WHERE upload_id = ? FOR UPDATE`, uploadID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, errUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != "ACTIVE" {
+		return nil, errUploadSessionClosed
+	}
+
+	var previous []string
+	var previousIDs string
+	err = tx.QueryRow(`
+		SELECT node_ids FROM upload_parts WHERE upload_id = ? AND part_number = ?
+	`, uploadID, part.PartNumber).Scan(&previousIDs)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if previousIDs != "" {
+		previous = strings.Split(previousIDs, ",")
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO upload_parts (upload_id, part_number, size_bytes, checksum_sha256, node_ids)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE size_bytes = VALUES(size_bytes), checksum_sha256 = VALUES(checksum_sha256),
+			node_ids = VALUES(node_ids), uploaded_at = CURRENT_TIMESTAMP
+	`, uploadID, part.PartNumber, part.SizeBytes, part.ChecksumSHA256, strings.Join(part.Locations, ",")); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE upload_sessions SET expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		WHERE upload_id = ?
+	`, int64(ttl.Seconds()), uploadID); err != nil {
+		return nil, err
+	}
+
+	return previous, tx.Commit()
+}
+
+func (s *mysqlStore) SetUploadSessionStatus(uploadID, from, to string) error {
+	result, err := s.db.Exec(`
+		UPDATE upload_sessions SET status = ? WHERE upload_id = ? AND status = ?
+	`, to, uploadID, from)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		if _, err := s.GetUploadSession(uploadID); err != nil {
+			return err
+		}
+		return errUploadSessionClosed
+	}
+	return nil
+}
+
+func (s *mysqlStore) DeleteUploadParts(uploadID string) error {
+	_, err := s.db.Exec(`DELETE FROM upload_parts WHERE upload_id = ?`, uploadID)
+	return err
+}
+
+func (s *mysqlStore) ListExpiredUploadSessions() ([]UploadSession, error) {
+	rows, err := s.db.Query(`
+		SELECT upload_id, file_key, status FROM upload_sessions
+		WHERE status IN ('ACTIVE', 'COMPLETING') AND expires_at < NOW()
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []UploadSession
+	for rows.Next() {
+		var us UploadSession
+		if err := rows.Scan(&us.UploadID, &us.FileKey, &us.Status); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, us)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *mysqlStore) StartDrain(nodeID string, totalObjects int) error {
+	_, err := s.db.Exec(`
+		UPDATE nodes
+		SET drain_state = 'DRAINING', drain_started_at = NOW(), drain_total_objects = ?
+		WHERE id = ?
+	`, totalObjects, nodeID)
+	return err
+}
+
+func (s *mysqlStore) CancelDrain(nodeID string) error {
+	result, err := s.db.Exec(`
+		UPDATE nodes
+		SET drain_state = 'NONE', drain_started_at = NULL, drain_total_objects = NULL
+		WHERE id = ? AND drain_state = 'DRAINING'
+	`, nodeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNodeNotDraining
+	}
+	return nil
+}
+
+func (s *mysqlStore) FinishDrain(nodeID string) error {
+	_, err := s.db.Exec(`UPDATE nodes SET drain_state = 'DECOMMISSIONED' WHERE id = ?`, nodeID)
+	return err
+}
+
+func (s *mysqlStore) GetDrainStatus(nodeID string) (*DrainStatus, error) {
+	var status DrainStatus
+	var total sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT id, COALESCE(drain_state, 'NONE'), drain_started_at, drain_total_objects
+		FROM nodes WHERE id = ?
+	`, nodeID).Scan(&status.NodeID, &status.DrainState, &status.DrainStartedAt, &total)
+	if err == sql.ErrNoRows {
+		return nil, errNodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.TotalObjects = int(total.Int64)
+
+	err = s.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(status IN ('PENDING', 'IN_PROGRESS', 'FAILED')), 0),
+			COALESCE(SUM(status = 'DEAD'), 0)
+		FROM replication_queue
+		WHERE source_node_id = ? AND created_at >= COALESCE(?, created_at)
+	`, nodeID, status.DrainStartedAt).Scan(&status.PendingReplications, &status.DeadReplications)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *mysqlStore) ListLostNodes(grace time.Duration) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM nodes
+		WHERE status = 'DOWN'
+			AND COALESCE(down_since, last_heartbeat) < DATE_SUB(NOW(), INTERVAL ? SECOND)
+	`, int64(grace.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lost []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		lost = append(lost, id)
+	}
+	return lost, rows.Err()
+}
+
+// AcquireLeaderLease: lihat leaderLeaseStore
+func (s *mysqlStore) AcquireLeaderLease(name, holder string, ttl time.Duration) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var expired bool
+	err = tx.QueryRow(`
+		SELECT holder, expires_at < NOW() FROM leader_lease WHERE name = ? FOR UPDATE
+	`, name).Scan(&current, &expired)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`
+			INSERT INTO leader_lease (name, holder, acquired_at, expires_at)
+			VALUES (?, ?, NOW(), DATE_ADD(NOW(), INTERVAL ? SECOND))
+		`, name, holder, int64(ttl.Seconds()))
+		current = holder
+	case err != nil:
+		return "", err
+	case current == holder:
+		_, err = tx.Exec(`
+			UPDATE leader_lease SET expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE name = ?
+		`, int64(ttl.Seconds()), name)
+	case expired:
+		_, err = tx.Exec(`
+			UPDATE leader_lease
+			SET holder = ?, acquired_at = NOW(), expires_at = DATE_ADD(NOW(), INTERVAL ? SECOND)
+			WHERE name = ?
+		`, holder, int64(ttl.Seconds()), name)
+		current = holder
+	default:
+		return current, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return current, tx.Commit()
+}
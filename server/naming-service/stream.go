@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"time"
+)
+
+// streamClient dipakai untuk transfer isi file (upload, replikasi, download).
+// Tidak memakai Client.Timeout karena timeout itu mencakup seluruh body,
+// sehingga file besar akan terputus. Yang dibatasi hanya koneksi dan
+// waktu tunggu response header.
+var streamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: 60 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   8,
+	},
+}
+
+// postMultipartStream mengirim src sebagai field "file" multipart tanpa
+// menampung seluruh isi file di memory: multipart writer menulis ke io.Pipe
+// yang langsung dibaca oleh HTTP request.
+func postMultipartStream(url, filename string, src io.Reader) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		part, err := writer.CreateFormFile("file", filename)
+		if err == nil {
+			_, err = io.Copy(part, src)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest("POST", url, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := streamClient.Do(req)
+	// Tunggu goroutine writer selesai supaya caller aman memakai src lagi
+	// (misalnya membaca hasil hash dari TeeReader)
+	pr.Close()
+	<-done
+	return resp, err
+}
+
+// sendObjectToNode mengupload src ke storage node dengan is_replica=true sambil
+// menghitung SHA256 data yang dikirim. Hasilnya dicocokkan dengan checksum yang
+// dilaporkan node dan expectedChecksum (jika tidak kosong). Object yang tidak
+// cocok dihapus lagi dari node.
+func sendObjectToNode(nodeAddr, objectKey, filename string, src io.Reader, expectedChecksum string) (map[string]interface{}, error) {
+	hasher := sha256.New()
+	url := fmt.Sprintf("%s/files?file_id=%s&is_replica=true", nodeAddr, objectKey)
+	resp, err := postMultipartStream(url, filename, io.TeeReader(src, hasher))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("node return status %d", resp.StatusCode)
+	}
+
+	var uploadResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&uploadResp); err != nil {
+		return nil, fmt.Errorf("gagal parse response: %v", err)
+	}
+
+	stored, _ := uploadResp["checksum_sha256"].(string)
+	if err := verifyStoredObject(objectKey, hex.EncodeToString(hasher.Sum(nil)), stored, expectedChecksum); err != nil {
+		deleteFileFromNode(nodeAddr, objectKey)
+		return nil, err
+	}
+
+	return uploadResp, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Tombstone delete. Blob yang tidak bisa dihapus saat delete permanen (node
+// DOWN atau delete ke node gagal) dicatat per node di metadata store, lalu
+// dihapus ulang oleh replayer begitu node UP, supaya delete akhirnya konsisten
+// di semua replica walaupun metadata file sudah hilang.
+const (
+	tombstoneReplayInterval = 30 * time.Second
+	tombstoneBatch          = 100
+)
+
+// DeleteTombstone adalah satu blob yang masih harus dihapus dari satu node
+type DeleteTombstone struct {
+	ID     int    `json:"id"`
+	NodeID string `json:"node_id"`
+	// FileKey adalah object di metadata, BlobKey nama blob di node (bisa chunk / shard)
+	FileKey     string     `json:"file_key"`
+	BlobKey     string     `json:"blob_key"`
+	Attempts    int        `json:"attempts"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// tombstoneWake membangunkan replayer saat ada node yang kembali UP
+var tombstoneWake = make(chan struct{}, 1)
+
+func wakeTombstoneReplayer() {
+	select {
+	case tombstoneWake <- struct{}{}:
+	default:
+	}
+}
+
+// replayNodeTombstones menghapus blob tertunda di satu node UP. Berhenti di
+// kegagalan pertama supaya node yang belum sehat tidak dibanjiri request.
+func replayNodeTombstones(node Node) (int, error) {
+	tombstones, err := metadata.ListTombstones(node.ID, tombstoneBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, t := range tombstones {
+		// Object dengan key yang sama sudah dibuat lagi, blob di node milik object baru
+		record, err := getFileRecord(t.FileKey)
+		if err != nil {
+			return replayed, err
+		}
+		if record != nil {
+			log.Printf("ℹ️ Tombstone %s on %s dropped, object was re-created\n", t.BlobKey, node.ID)
+			if err := metadata.DeleteTombstone(t.ID); err != nil {
+				return replayed, err
+			}
+			continue
+		}
+
+		status, err := deleteFileFromNode(node.Address, t.BlobKey)
+		if err == nil && status != 200 && status != 404 {
+			err = fmt.Errorf("node return status %d", status)
+		}
+		if err != nil {
+			if ferr := metadata.FailTombstone(t.ID, err.Error()); ferr != nil {
+				log.Println("error update tombstone:", ferr)
+			}
+			return replayed, err
+		}
+
+		if err := metadata.DeleteTombstone(t.ID); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func replayTombstones() {
+	nodes, err := getAllNodes()
+	if err != nil {
+		log.Println("error ambil nodes untuk replay tombstone:", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if node.Status != "UP" {
+			continue
+		}
+		replayed, err := replayNodeTombstones(node)
+		if replayed > 0 {
+			log.Printf("🪦 Replayed %d delete tombstones on %s\n", replayed, node.ID)
+		}
+		if err != nil {
+			log.Printf("⚠️ Replay tombstones on %s stopped: %v\n", node.ID, err)
+		}
+	}
+}
+
+func runTombstoneReplayer() {
+	for {
+		if isLeader() {
+			replayTombstones()
+		}
+
+		select {
+		case <-tombstoneWake:
+		case <-time.After(tombstoneReplayInterval):
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Soft delete. DELETE /files/:fileKey memindahkan semua versi file ke trash:
+// file hilang dari GET /files dan download, tetapi blob tetap di node sampai
+// purge worker menghapusnya setelah TRASH_RETENTION_HOURS. Selama itu file
+// bisa dikembalikan lewat POST /files/:fileKey/restore.
+// Soft delete opt-in: default 0 = DELETE langsung menghapus permanen seperti sebelumnya.
+var trashRetention = time.Duration(getEnvInt("TRASH_RETENTION_HOURS", 0)) * time.Hour
+
+const (
+	trashPurgeInterval = 10 * time.Minute
+	trashPurgeBatch    = 100
+)
+
+// fileObjectKeys mengembalikan object semua versi file_key, termasuk yang di
+// trash. Key tanpa riwayat versi dianggap object itu sendiri.
+func fileObjectKeys(fileKey string) ([]string, error) {
+	versions, err := metadata.ListFileVersions(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return []string{fileKey}, nil
+	}
+
+	objectKeys := make([]string, 0, len(versions))
+	for _, v := range versions {
+		objectKeys = append(objectKeys, v.ObjectKey)
+	}
+	return objectKeys, nil
+}
+
+// trashObjects memindahkan object ke trash. known = jumlah object yang ada di
+// metadata, trashed = yang baru dipindah (sisanya sudah ada di trash).
+func trashObjects(objectKeys []string) (trashed, known int, err error) {
+	for _, objectKey := range objectKeys {
+		record, err := getFileRecord(objectKey)
+		if err != nil {
+			return trashed, known, err
+		}
+		if record == nil {
+			continue
+		}
+		known++
+		if record.TrashedAt != nil {
+			continue
+		}
+		if err := metadata.TrashFile(objectKey); err != nil {
+			return trashed, known, err
+		}
+		trashed++
+	}
+	return trashed, known, nil
+}
+
+// restoreTrashedFile mengeluarkan semua versi file_key dari trash selama
+// masa retensinya belum lewat
+func restoreTrashedFile(fileKey string) (int, error) {
+	objectKeys, err := fileObjectKeys(fileKey)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, objectKey := range objectKeys {
+		record, err := getFileRecord(objectKey)
+		if err != nil {
+			return restored, err
+		}
+		if record == nil || record.TrashedAt == nil || time.Since(*record.TrashedAt) >= trashRetention {
+			continue
+		}
+		if err := metadata.RestoreFile(objectKey); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// purgeExpiredTrash menghapus permanen object yang masa retensinya sudah lewat
+func purgeExpiredTrash() {
+	for {
+		objectKeys, err := metadata.ListExpiredTrash(trashRetention, trashPurgeBatch)
+		if err != nil {
+			log.Println("error ambil trash kedaluwarsa:", err)
+			return
+		}
+		if len(objectKeys) == 0 {
+			return
+		}
+
+		nodes, err := getAllNodes()
+		if err != nil {
+			log.Println("error ambil nodes untuk purge trash:", err)
+			return
+		}
+
+		for _, objectKey := range objectKeys {
+			result, err := deleteStoredObject(objectKey, nodes)
+			if err != nil {
+				// Metadata tetap ada, dicoba lagi di putaran berikutnya
+				log.Printf("⚠️ Purge %s from trash failed: %v\n", objectKey, err)
+				return
+			}
+			log.Printf("🧹 Purged %s from trash: %d deleted, %d failed, %d deferred\n",
+				objectKey, result.Deleted, result.Failed, result.Deferred)
+		}
+
+		if len(objectKeys) < trashPurgeBatch {
+			return
+		}
+	}
+}
+
+func runTrashPurger() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		purgeExpiredTrash()
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Upload session menyimpan state multipart upload di metadata store sehingga client bisa
+// melanjutkan upload yang terputus dan mengirim part secara paralel. Setiap part
+// disimpan sebagai chunk (chunkObjectKey dengan index part_number-1), jadi file
+// yang selesai diupload adalah file CHUNKED biasa.
+var (
+	uploadSessionTTL = time.Duration(getEnvInt("UPLOAD_SESSION_TTL_HOURS", 24)) * time.Hour
+	maxPartSizeBytes = int64(getEnvInt("UPLOAD_PART_MAX_MB", 1024)) << 20
+)
+
+const maxPartNumber = 10000
+
+var (
+	errUploadSessionNotFound = errors.New("upload session not found")
+	errUploadSessionClosed   = errors.New("upload session is not active")
+	errPartReplicationFailed = errors.New("part replication factor not reached")
+)
+
+type UploadSession struct {
+	UploadID          string       `json:"upload_id"`
+	FileKey           string       `json:"file_key"`
+	OriginalFilename  string       `json:"original_filename"`
+	ReplicationFactor int          `json:"replication_factor"`
+	Status            string       `json:"status"`
+	CreatedAt         string       `json:"created_at"`
+	ExpiresAt         string       `json:"expires_at"`
+	Parts             []UploadPart `json:"parts"`
+}
+
+type UploadPart struct {
+	PartNumber     int      `json:"part_number"`
+	SizeBytes      int64    `json:"size_bytes"`
+	ChecksumSHA256 string   `json:"checksum_sha256"`
+	Locations      []string `json:"locations"`
+	UploadedAt     string   `json:"uploaded_at"`
+}
+
+func createUploadSession(originalFilename string, replicationFactor int) (*UploadSession, error) {
+	uploadID, err := newFileKey()
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := newFileKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := metadata.CreateUploadSession(uploadID, fileKey, originalFilename, replicationFactor, uploadSessionTTL); err != nil {
+		return nil, err
+	}
+
+	return getUploadSession(uploadID)
+}
+
+// getUploadSession mengembalikan session beserta part yang sudah diupload (urut part_number)
+func getUploadSession(uploadID string) (*UploadSession, error) {
+	return metadata.GetUploadSession(uploadID)
+}
+
+// partObjectKey: part N disimpan sebagai chunk N-1 dari file tujuan
+func partObjectKey(fileKey string, partNumber int) string {
+	return chunkObjectKey(fileKey, partNumber-1)
+}
+
+// spoolPart menyalin body request ke file sementara sambil menghitung checksum,
+// supaya part yang sama bisa dikirim ke beberapa node
+func spoolPart(body io.Reader) (*os.File, int64, string, error) {
+	tmp, err := os.CreateTemp("", "dfs-part-*")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), body)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, "", err
+	}
+
+	return tmp, size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// storePart mengupload part ke replicationFactor node. Placement digeser per
+// part_number seperti chunk. Jika tidak tercapai, salinan yang sudah ada dihapus
+// kecuali di node previous (lokasi part yang sudah tercatat dengan nomor sama):
+// blob key part tetap, jadi rollback di sana ikut menghapus part lama yang masih
+// dipakai session. Node previous dicoba terakhir supaya retry yang gagal
+// sebisa mungkin tidak menimpa part lama.
+func storePart(fileKey string, partNumber int, src io.ReaderAt, size int64, checksum string, candidates []Node, replicationFactor int, previous []string) ([]string, error) {
+	objectKey := partObjectKey(fileKey, partNumber)
+
+	order := placementOrder(candidates, partNumber-1)
+	sort.SliceStable(order, func(i, j int) bool {
+		return !slices.Contains(previous, order[i].ID) && slices.Contains(previous, order[j].ID)
+	})
+
+	var stored []Node
+	for _, node := range order {
+		if len(stored) == replicationFactor {
+			break
+		}
+		if err := uploadObjectToNode(node.Address, objectKey, objectKey, io.NewSectionReader(src, 0, size), checksum); err != nil {
+			log.Printf("❌ Upload part %d of %s to %s failed: %v\n", partNumber, fileKey, node.ID, err)
+			continue
+		}
+		stored = append(stored, node)
+	}
+
+	if len(stored) < replicationFactor {
+		for _, node := range stored {
+			if slices.Contains(previous, node.ID) {
+				continue
+			}
+			if _, err := deleteFileFromNode(node.Address, objectKey); err != nil {
+				log.Printf("⚠️ Rollback delete part %d of %s from %s failed: %v\n", partNumber, fileKey, node.ID, err)
+			}
+		}
+		return nil, fmt.Errorf("%w: part %d stored on %d/%d nodes", errPartReplicationFailed, partNumber, len(stored), replicationFactor)
+	}
+
+	nodeIDs := make([]string, len(stored))
+	for i, node := range stored {
+		nodeIDs[i] = node.ID
+	}
+	return nodeIDs, nil
+}
+
+// recordPart menyimpan part (menimpa upload sebelumnya dengan nomor yang sama)
+// dan memperpanjang TTL session. Mengembalikan lokasi part lama.
+func recordPart(uploadID string, part UploadPart) ([]string, error) {
+	return metadata.RecordUploadPart(uploadID, part, uploadSessionTTL)
+}
+
+// setUploadSessionStatus memindahkan status session hanya jika status saat ini
+// == from; session yang tidak ada mengembalikan errUploadSessionNotFound dan
+// status lain errUploadSessionClosed
+func setUploadSessionStatus(uploadID, from, to string) error {
+	return metadata.SetUploadSessionStatus(uploadID, from, to)
+}
+
+// validateCompleteParts memastikan part lengkap mulai dari 1 tanpa lubang. Jika
+// client mengirim daftar part, daftar itu harus sama persis dengan yang tersimpan.
+func validateCompleteParts(parts []UploadPart, expected []UploadPart) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("belum ada part yang diupload")
+	}
+	for i, part := range parts {
+		if part.PartNumber != i+1 {
+			return fmt.Errorf("part %d belum diupload", i+1)
+		}
+	}
+
+	if expected == nil {
+		return nil
+	}
+	if len(expected) != len(parts) {
+		return fmt.Errorf("jumlah part tidak cocok: client %d, server %d", len(expected), len(parts))
+	}
+	for i, part := range expected {
+		if part.PartNumber != parts[i].PartNumber {
+			return fmt.Errorf("part %d tidak cocok dengan part %d di server", part.PartNumber, parts[i].PartNumber)
+		}
+		if part.ChecksumSHA256 != "" && !strings.EqualFold(part.ChecksumSHA256, parts[i].ChecksumSHA256) {
+			return fmt.Errorf("checksum part %d tidak cocok", part.PartNumber)
+		}
+	}
+	return nil
+}
+
+// partsToChunks mengubah part session menjadi manifest chunk file tujuan
+func partsToChunks(fileKey string, parts []UploadPart) ([]FileChunk, int64) {
+	chunks := make([]FileChunk, len(parts))
+	var size int64
+	for i, part := range parts {
+		chunks[i] = FileChunk{
+			FileKey:        fileKey,
+			ChunkIndex:     part.PartNumber - 1,
+			SizeBytes:      part.SizeBytes,
+			ChecksumSHA256: part.ChecksumSHA256,
+			Locations:      part.Locations,
+		}
+		size += part.SizeBytes
+	}
+	return chunks, size
+}
+
+// checksumAssembledFile membaca ulang semua part secara berurutan untuk menghitung
+// checksum file utuh, sekaligus memastikan setiap part bisa dibaca dari node UP
+func checksumAssembledFile(fileKey string, chunks []FileChunk, nodes []Node) (string, error) {
+	nodeByID := make(map[string]Node)
+	for _, n := range nodes {
+		nodeByID[n.ID] = n
+	}
+
+	source := &chunkedSource{fileKey: fileKey, chunks: chunks, sources: make([][]Node, len(chunks))}
+	for i, chunk := range chunks {
+		source.sources[i] = orderSources(chunk.Locations, nodeByID)
+		if len(source.sources[i]) == 0 {
+			return "", fmt.Errorf("%w: part %d", errNoSourceAvailable, chunk.ChunkIndex+1)
+		}
+	}
+
+	reader, err := source.OpenAt(0)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// deletePartObjects menghapus object part dari node (best effort)
+func deletePartObjects(fileKey string, partNumber int, nodeIDs []string, nodeMap map[string]string) {
+	objectKey := partObjectKey(fileKey, partNumber)
+	for _, nodeID := range nodeIDs {
+		addr, ok := nodeMap[nodeID]
+		if !ok {
+			continue
+		}
+		if _, err := deleteFileFromNode(addr, objectKey); err != nil {
+			log.Printf("⚠️ Delete part %d of %s from %s failed: %v\n", partNumber, fileKey, nodeID, err)
+		}
+	}
+}
+
+// abortUploadSession menandai session ABORTED lalu menghapus semua part dari node
+func abortUploadSession(uploadID string, from string) error {
+	if err := setUploadSessionStatus(uploadID, from, "ABORTED"); err != nil {
+		return err
+	}
+
+	session, err := getUploadSession(uploadID)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := getAllNodes()
+	if err != nil {
+		return err
+	}
+	nodeMap := make(map[string]string)
+	for _, n := range nodes {
+		nodeMap[n.ID] = n.Address
+	}
+
+	for _, part := range session.Parts {
+		deletePartObjects(session.FileKey, part.PartNumber, part.Locations, nodeMap)
+	}
+
+	return metadata.DeleteUploadParts(uploadID)
+}
+
+// getExpiredUploadSessions mengembalikan session ACTIVE/COMPLETING yang melewati TTL
+func getExpiredUploadSessions() ([]UploadSession, error) {
+	return metadata.ListExpiredUploadSessions()
+}
+
+// runUploadSessionGC membersihkan session yang ditinggalkan client. Session yang
+// berhenti di COMPLETING (misalnya naming service restart) dianggap selesai jika
+// metadata file sudah tersimpan, selain itu di-abort.
+func runUploadSessionGC() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !isLeader() {
+			continue
+		}
+		sessions, err := getExpiredUploadSessions()
+		if err != nil {
+			log.Println("error ambil upload session expired:", err)
+			continue
+		}
+
+		for _, s := range sessions {
+			if s.Status == "COMPLETING" {
+				record, err := getFileRecord(s.FileKey)
+				if err != nil {
+					log.Println("error cek file upload session:", err)
+					continue
+				}
+				if record != nil {
+					if err := setUploadSessionStatus(s.UploadID, "COMPLETING", "COMPLETED"); err != nil {
+						log.Println("error update upload session:", err)
+					}
+					continue
+				}
+			}
+
+			if err := abortUploadSession(s.UploadID, s.Status); err != nil {
+				log.Printf("❌ Failed to expire upload session %s: %v\n", s.UploadID, err)
+				continue
+			}
+			log.Printf("🧹 Upload session %s expired and aborted\n", s.UploadID)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValidateCompleteParts(t *testing.T) {
+	stored := []UploadPart{
+		{PartNumber: 1, ChecksumSHA256: "aa"},
+		{PartNumber: 2, ChecksumSHA256: "bb"},
+	}
+
+	// Session yang lengkap boleh di-complete dengan atau tanpa daftar part dari client
+	if err := validateCompleteParts(stored, nil); err != nil {
+		t.Errorf("tanpa daftar client: %v", err)
+	}
+	if err := validateCompleteParts(stored, []UploadPart{{PartNumber: 1, ChecksumSHA256: "AA"}, {PartNumber: 2}}); err != nil {
+		t.Errorf("daftar client cocok: %v", err)
+	}
+
+	invalid := map[string]struct {
+		parts, expected []UploadPart
+	}{
+		"belum ada part":      {parts: nil},
+		"part berlubang":      {parts: []UploadPart{{PartNumber: 1}, {PartNumber: 3}}},
+		"tidak mulai dari 1":  {parts: []UploadPart{{PartNumber: 2}}},
+		"daftar lebih pendek": {parts: stored, expected: []UploadPart{{PartNumber: 1}}},
+		"urutan berbeda":      {parts: stored, expected: []UploadPart{{PartNumber: 2}, {PartNumber: 1}}},
+		"checksum beda":       {parts: stored, expected: []UploadPart{{PartNumber: 1, ChecksumSHA256: "aa"}, {PartNumber: 2, ChecksumSHA256: "cc"}}},
+		"daftar kosong":       {parts: stored, expected: []UploadPart{}},
+	}
+	for name, c := range invalid {
+		if err := validateCompleteParts(c.parts, c.expected); err == nil {
+			t.Errorf("%s: validateCompleteParts tidak mengembalikan error", name)
+		}
+	}
+}
+
+func TestPartsToChunks(t *testing.T) {
+	parts := []UploadPart{
+		{PartNumber: 1, SizeBytes: 100, ChecksumSHA256: "aa", Locations: []string{"n1", "n2"}},
+		{PartNumber: 2, SizeBytes: 40, ChecksumSHA256: "bb", Locations: []string{"n2"}},
+	}
+
+	chunks, size := partsToChunks("target", parts)
+	if size != 140 {
+		t.Errorf("size = %d, want 140", size)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("len(chunks) = %d, want 2", len(chunks))
+	}
+	if chunks[0].ChunkIndex != 0 || chunks[1].ChunkIndex != 1 {
+		t.Errorf("chunk index = %d, %d; want 0, 1", chunks[0].ChunkIndex, chunks[1].ChunkIndex)
+	}
+	if chunks[1].FileKey != "target" || chunks[1].ChecksumSHA256 != "bb" || len(chunks[1].Locations) != 1 {
+		t.Errorf("chunk 1 = %+v", chunks[1])
+	}
+}
+
+// fakeStorageNode menerima upload (atau menolaknya jika fail) dan menghitung DELETE
+func fakeStorageNode(t *testing.T, fail bool, deletes *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			deletes.Add(1)
+		case fail:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			h := sha256.New()
+			io.Copy(h, file)
+			w.Write([]byte(`{"checksum_sha256":"` + hex.EncodeToString(h.Sum(nil)) + `"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStorePartRetryKeepsRecordedPart(t *testing.T) {
+	var oldDeletes, badDeletes atomic.Int32
+	old := fakeStorageNode(t, false, &oldDeletes)
+	bad := fakeStorageNode(t, true, &badDeletes)
+
+	data := "part two, second attempt"
+	sum := sha256.Sum256([]byte(data))
+	candidates := []Node{
+		{ID: "old", Address: old.URL, Status: "UP"},
+		{ID: "bad", Address: bad.URL, Status: "UP"},
+	}
+
+	// Retry gagal mencapai RF: salinan di node yang sudah menyimpan part 2 tidak di-rollback
+	_, err := storePart("f", 2, strings.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]), candidates, 2, []string{"old"})
+	if !errors.Is(err, errPartReplicationFailed) {
+		t.Fatalf("storePart err = %v, want errPartReplicationFailed", err)
+	}
+	if n := oldDeletes.Load(); n != 0 {
+		t.Errorf("recorded part deleted %d times on rollback", n)
+	}
+
+	// Upload pertama (belum ada lokasi tercatat) tetap dibersihkan
+	oldDeletes.Store(0)
+	_, err = storePart("f", 2, strings.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]), candidates, 2, nil)
+	if !errors.Is(err, errPartReplicationFailed) {
+		t.Fatalf("storePart err = %v, want errPartReplicationFailed", err)
+	}
+	if n := oldDeletes.Load(); n != 1 {
+		t.Errorf("rollback deletes = %d, want 1", n)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Object versioning. Setiap upload ke file_key yang sudah ada disimpan sebagai
+// object baru (<file_key>_v<acak>) dengan row files dan lokasi sendiri, lalu
+// dicatat di file_versions. Versi lama tidak pernah ditimpa; yang dihapus
+// hanya versi di luar FILE_VERSION_RETENTION (0 = simpan semua).
+var fileVersionRetention = getEnvInt("FILE_VERSION_RETENTION", 10)
+
+// file_key dari client dipakai sebagai nama object di storage node
+var fileKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,79}$`)
+
+// FileVersion adalah satu versi file_key beserta metadata object-nya
+type FileVersion struct {
+	FileKey          string `json:"file_key"`
+	Version          int    `json:"version"`
+	ObjectKey        string `json:"object_key"`
+	OriginalFilename string `json:"original_filename"`
+	SizeBytes        int64  `json:"size_bytes"`
+	ChecksumSHA256   string `json:"checksum_sha256"`
+	StorageMode      string `json:"storage_mode"`
+	UploadedAt       string `json:"uploaded_at"`
+	IsLatest         bool   `json:"is_latest"`
+	// Versi di trash tidak bisa didownload sampai di-restore
+	TrashedAt *time.Time `json:"trashed_at,omitempty"`
+}
+
+// implicitFileVersion: row files tanpa riwayat versi dianggap versi 1 dirinya sendiri
+func implicitFileVersion(f *FileMetadata) FileVersion {
+	return FileVersion{
+		FileKey:          f.FileKey,
+		Version:          1,
+		ObjectKey:        f.FileKey,
+		OriginalFilename: f.OriginalFilename,
+		SizeBytes:        f.SizeBytes,
+		ChecksumSHA256:   f.ChecksumSHA256,
+		StorageMode:      f.StorageMode,
+		UploadedAt:       f.UploadedAt,
+		IsLatest:         f.TrashedAt == nil,
+		TrashedAt:        f.TrashedAt,
+	}
+}
+
+// markLatestVersion menandai versi terbaru yang tidak ada di trash;
+// versions harus urut dari versi terbaru
+func markLatestVersion(versions []FileVersion) {
+	for i := range versions {
+		versions[i].IsLatest = false
+	}
+	for i := range versions {
+		if versions[i].TrashedAt == nil {
+			versions[i].IsLatest = true
+			return
+		}
+	}
+}
+
+// newVersionObjectKey membuat object key untuk versi baru file_key
+func newVersionObjectKey(fileKey string) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_v%x", fileKey, b), nil
+}
+
+// parseFileVersion membaca query ?version=N, kosong = versi terbaru (0)
+func parseFileVersion(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("version harus bilangan bulat >= 1")
+	}
+	return version, nil
+}
+
+// resolveFileVersion mencari versi file_key (0 = terbaru) yang tidak ada di
+// trash. Key yang bukan file_key terdaftar dicari langsung sebagai object,
+// supaya object key versi lama tetap bisa diakses. Mengembalikan nil jika
+// tidak ditemukan.
+func resolveFileVersion(fileKey string, version int) (*FileVersion, error) {
+	versions, err := metadata.ListFileVersions(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].TrashedAt != nil {
+			continue
+		}
+		if version == 0 || versions[i].Version == version {
+			return &versions[i], nil
+		}
+	}
+	if len(versions) > 0 || version > 1 {
+		return nil, nil
+	}
+
+	record, err := getFileRecord(fileKey)
+	if err != nil || record == nil || record.TrashedAt != nil {
+		return nil, err
+	}
+	v := implicitFileVersion(record)
+	return &v, nil
+}
+
+// pruneFileVersions menghapus versi tertua di luar FILE_VERSION_RETENTION
+func pruneFileVersions(fileKey string) {
+	if fileVersionRetention <= 0 {
+		return
+	}
+
+	versions, err := metadata.ListFileVersions(fileKey)
+	if err != nil {
+		log.Printf("error ambil versi %s untuk retention: %v\n", fileKey, err)
+		return
+	}
+	if len(versions) <= fileVersionRetention {
+		return
+	}
+
+	nodes, err := getAllNodes()
+	if err != nil {
+		log.Println("error ambil nodes untuk retention versi:", err)
+		return
+	}
+
+	for _, v := range versions[fileVersionRetention:] {
+		result, err := deleteStoredObject(v.ObjectKey, nodes)
+		if err != nil {
+			log.Printf("⚠️ Prune %s version %d (%s) failed: %v\n", fileKey, v.Version, v.ObjectKey, err)
+			continue
+		}
+		log.Printf("🗂️ Pruned %s version %d (%s): %d deleted, %d failed, %d deferred\n",
+			fileKey, v.Version, v.ObjectKey, result.Deleted, result.Failed, result.Deferred)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestParseFileVersion(t *testing.T) {
+	for raw, want := range map[string]int{"": 0, "1": 1, "12": 12} {
+		if got, err := parseFileVersion(raw); err != nil || got != want {
+			t.Errorf("parseFileVersion(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"0", "-1", "v2", "1.5"} {
+		if _, err := parseFileVersion(raw); err == nil {
+			t.Errorf("parseFileVersion(%q) tidak mengembalikan error", raw)
+		}
+	}
+}
@@ -21,7 +21,7 @@ tmux new-session -d -s mini-dfs
 tmux rename-window -t mini-dfs:0 'Naming-Service'
 tmux send-keys -t mini-dfs:0 'cd naming-service' C-m
 tmux send-keys -t mini-dfs:0 'export DB_DSN="dfs_user:admin123@tcp(localhost:3306)/dfs_meta?parseTime=true"' C-m
-tmux send-keys -t mini-dfs:0 'go run main.go' C-m
+tmux send-keys -t mini-dfs:0 'go run .' C-m
 
 sleep 2
 
@@ -8,6 +8,8 @@ import os
 import json
 import httpx
 import asyncio
+import shutil
+from datetime import datetime, timezone
 from typing import List
 
 app = FastAPI(title="Storage Node")
@@ -16,6 +18,10 @@ app = FastAPI(title="Storage Node")
 NODE_ID = os.getenv("NODE_ID", "node-1")
 NODE_PORT = int(os.getenv("NODE_PORT", "8001"))
 NAMING_SERVICE_URL = os.getenv("NAMING_SERVICE_URL", "http://localhost:8080")
+# Interval push heartbeat ke naming service (detik), 0 = nonaktif
+HEARTBEAT_INTERVAL = float(os.getenv("HEARTBEAT_INTERVAL", "5"))
+# Jumlah percobaan register metadata ke naming service
+REGISTER_RETRIES = int(os.getenv("REGISTER_RETRIES", "3"))
 
 # Parse ALL_NODES dari environment atau gunakan default
 def parse_all_nodes():
@@ -42,6 +48,48 @@ UPLOAD_DIR = BASE_DIR / "uploads"
 UPLOAD_DIR.mkdir(exist_ok=True)
 
 
+# Jumlah request yang sedang diproses, dilaporkan lewat heartbeat
+inflight_requests = 0
+
+
+@app.middleware("http")
+async def count_inflight_requests(request: Request, call_next):
+    global inflight_requests
+    inflight_requests += 1
+    try:
+        return await call_next(request)
+    finally:
+        inflight_requests -= 1
+
+
+async def send_heartbeats():
+    """Push heartbeat berkala ke naming service (kapasitas disk dan load)"""
+    async with httpx.AsyncClient(timeout=3.0) as client:
+        while True:
+            try:
+                usage = shutil.disk_usage(UPLOAD_DIR)
+                payload = {
+                    "capacity_bytes": usage.total,
+                    "free_bytes": usage.free,
+                    "inflight_requests": inflight_requests,
+                }
+                response = await client.post(
+                    f"{NAMING_SERVICE_URL}/nodes/{NODE_ID}/heartbeat",
+                    json=payload
+                )
+                if response.status_code != 200:
+                    print(f"[{NODE_ID}] Heartbeat rejected: HTTP {response.status_code}")
+            except Exception as e:
+                print(f"[{NODE_ID}] Failed to send heartbeat: {e}")
+            await asyncio.sleep(HEARTBEAT_INTERVAL)
+
+
+@app.on_event("startup")
+async def start_heartbeat():
+    if HEARTBEAT_INTERVAL > 0:
+        asyncio.create_task(send_heartbeats())
+
+
 def get_other_nodes():
     """Get list of other nodes (exclude self)"""
     return {k: v for k, v in ALL_NODES.items() if k != NODE_ID}
@@ -153,39 +201,50 @@ async def replicate_to_all_nodes(file_path: Path, file_id: str, original_filenam
 
 async def register_to_naming_service(file_key: str, original_filename: str,
                                      size_bytes: int, checksum: str,
-                                     successful_nodes: List[str], failed_nodes: List[str]):
-    """Register file metadata to naming service"""
-    try:
-        async with httpx.AsyncClient(timeout=5.0) as client:
-            payload = {
-                "file_key": file_key,
-                "original_filename": original_filename,
-                "size_bytes": size_bytes,
-                "checksum_sha256": checksum,
-                "node_id": NODE_ID,
-                "failed_nodes": failed_nodes
-            }
-            
-            response = await client.post(
-                f"{NAMING_SERVICE_URL}/files/register",
-                json=payload
-            )
-            
+                                     successful_nodes: List[str], failed_nodes: List[str],
+                                     object_key: str | None = None):
+    """Register file metadata to naming service.
+
+    Metadata, lokasi replica dan node yang gagal dikirim dalam satu request.
+    Request di-retry dengan Idempotency-Key yang sama supaya retry tidak
+    membuat entri replication queue ganda. object_key diisi jika file disimpan
+    sebagai versi baru dari file_key yang sudah ada.
+    """
+    payload = {
+        "file_key": file_key,
+        "object_key": object_key or file_key,
+        "original_filename": original_filename,
+        "size_bytes": size_bytes,
+        "checksum_sha256": checksum,
+        "node_id": NODE_ID,
+        "replica_nodes": successful_nodes,
+        "failed_nodes": failed_nodes
+    }
+    headers = {"Idempotency-Key": str(uuid4())}
+
+    for attempt in range(1, REGISTER_RETRIES + 1):
+        try:
+            async with httpx.AsyncClient(timeout=5.0) as client:
+                response = await client.post(
+                    f"{NAMING_SERVICE_URL}/files/register",
+                    json=payload,
+                    headers=headers
+                )
             if response.status_code == 200:
                 print(f"[{NODE_ID}] Registered {file_key} to naming service")
-                
-                for node_id in successful_nodes:
-                    try:
-                        await client.post(
-                            f"{NAMING_SERVICE_URL}/files/register-location",
-                            json={"file_key": file_key, "node_id": node_id}
-                        )
-                    except:
-                        pass
-            else:
+                return
+            # 4xx tidak akan berhasil walaupun diulang
+            if response.status_code < 500:
                 print(f"[{NODE_ID}] Failed to register {file_key}: HTTP {response.status_code}")
-    except Exception as e:
-        print(f"[{NODE_ID}] Failed to register to naming service: {e}")
+                return
+            print(f"[{NODE_ID}] Register {file_key} attempt {attempt} failed: HTTP {response.status_code}")
+        except Exception as e:
+            print(f"[{NODE_ID}] Register {file_key} attempt {attempt} failed: {e}")
+
+        if attempt < REGISTER_RETRIES:
+            await asyncio.sleep(attempt)
+
+    print(f"[{NODE_ID}] Giving up registering {file_key} after {REGISTER_RETRIES} attempts")
 
 
 @app.get("/health")
@@ -200,9 +259,19 @@ async def upload_file(file: UploadFile = File(...), request: Request = None):
     
     file_id = override_id or str(uuid4())
 
+    # Upload ulang ke file_id yang sudah ada disimpan sebagai object baru supaya
+    # versi lama tidak tertimpa; naming service mencatatnya sebagai versi baru
+    object_id = file_id
+    if override_id and not is_replica:
+        try:
+            resolve_file_path(file_id)
+            object_id = f"{file_id}_v{uuid4().hex[:8]}"
+        except FileNotFoundError:
+            pass
+
     try:
-        result = save_file_to_disk(file, file_id)
-        save_metadata(file_id, result["stored_name"], file.filename or "")
+        result = save_file_to_disk(file, object_id)
+        save_metadata(object_id, result["stored_name"], file.filename or "")
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Gagal menyimpan file: {e}")
 
@@ -212,10 +281,10 @@ async def upload_file(file: UploadFile = File(...), request: Request = None):
     if not is_replica:
         file_path = Path(result["file_path"])
         successful_nodes, failed_nodes = await replicate_to_all_nodes(
-            file_path, file_id, file.filename or ""
+            file_path, object_id, file.filename or ""
         )
         
-        print(f"[{NODE_ID}] Upload {file_id}: replicated to {successful_nodes}, failed: {failed_nodes}")
+        print(f"[{NODE_ID}] Upload {object_id}: replicated to {successful_nodes}, failed: {failed_nodes}")
         
         await register_to_naming_service(
             file_id,
@@ -223,12 +292,14 @@ async def upload_file(file: UploadFile = File(...), request: Request = None):
             result["size"],
             result["checksum"],
             successful_nodes,
-            failed_nodes
+            failed_nodes,
+            object_id
         )
 
     return {
         "success": True,
         "file_id": file_id,
+        "object_key": object_id,
         "stored_name": result["stored_name"],
         "original_filename": file.filename,
         "size_bytes": result["size"],
@@ -242,6 +313,71 @@ async def upload_file(file: UploadFile = File(...), request: Request = None):
     }
 
 
+def object_entry(file_id: str, path: Path, stored_name: str | None, has_data: bool) -> dict:
+    stat = path.stat()
+    return {
+        "file_id": file_id,
+        "stored_name": stored_name,
+        "size_bytes": stat.st_size if has_data else 0,
+        "modified_at": datetime.fromtimestamp(stat.st_mtime, tz=timezone.utc).isoformat(),
+        "has_data": has_data,
+    }
+
+
+@app.get("/objects")
+def list_objects():
+    """Inventory semua object di disk (dipakai orphan GC naming service).
+    Object tanpa file data (sisa delete setengah jalan) dilaporkan has_data=false."""
+    objects = {}
+    for meta_path in UPLOAD_DIR.glob("*.meta.json"):
+        file_id = meta_path.name[: -len(".meta.json")]
+        meta = load_metadata(file_id) or {}
+        stored_name = meta.get("stored_name")
+        data_path = UPLOAD_DIR / stored_name if stored_name else None
+        if data_path and data_path.is_file():
+            objects[file_id] = object_entry(file_id, data_path, stored_name, True)
+        else:
+            objects[file_id] = object_entry(file_id, meta_path, stored_name, False)
+
+    # File data tanpa .meta.json
+    stored_names = {o["stored_name"] for o in objects.values() if o["has_data"]}
+    for path in UPLOAD_DIR.iterdir():
+        if not path.is_file() or path.name.endswith(".meta.json") or path.name in stored_names:
+            continue
+        file_id = path.name.split(".", 1)[0]
+        if file_id not in objects:
+            objects[file_id] = object_entry(file_id, path, path.name, True)
+
+    return {
+        "node_id": NODE_ID,
+        "objects": sorted(objects.values(), key=lambda o: o["file_id"]),
+        "count": len(objects),
+    }
+
+
+@app.get("/files/{file_id}/checksum")
+def file_checksum(file_id: str):
+    """Hitung ulang checksum file di disk (dipakai scrubber naming service)"""
+    try:
+        file_path = resolve_file_path(file_id)
+    except FileNotFoundError:
+        raise HTTPException(status_code=404, detail="File tidak ditemukan")
+
+    sha256 = hashlib.sha256()
+    total_size = 0
+    with open(file_path, "rb") as f:
+        for chunk in iter(lambda: f.read(1024 * 1024), b""):
+            sha256.update(chunk)
+            total_size += len(chunk)
+
+    return {
+        "file_id": file_id,
+        "checksum_sha256": sha256.hexdigest(),
+        "size_bytes": total_size,
+        "node_id": NODE_ID,
+    }
+
+
 @app.get("/files/{file_id}")
 async def download_file(file_id: str):
     try:
@@ -261,17 +397,21 @@ async def download_file(file_id: str):
 
 @app.delete("/files/{file_id}")
 async def delete_file(file_id: str):
+    meta_path = get_meta_path(file_id)
     try:
         file_path = resolve_file_path(file_id)
     except FileNotFoundError:
-        raise HTTPException(status_code=404, detail="File tidak ditemukan")
+        # Sisa delete yang berhenti di tengah: data sudah hilang, metadata masih ada
+        if not meta_path.exists():
+            raise HTTPException(status_code=404, detail="File tidak ditemukan")
+        file_path = None
 
-    try:
-        os.remove(file_path)
-    except Exception as e:
-        raise HTTPException(status_code=500, detail=f"Gagal menghapus file: {e}")
+    if file_path is not None:
+        try:
+            os.remove(file_path)
+        except Exception as e:
+            raise HTTPException(status_code=500, detail=f"Gagal menghapus file: {e}")
 
-    meta_path = get_meta_path(file_id)
     if meta_path.exists():
         try:
             os.remove(meta_path)
@@ -7,7 +7,7 @@ Write-Host "🚀 Starting Distributed File Storage System..." -ForegroundColor C
 
 # Start Naming Service (Go)
 Write-Host "Starting Naming Service..." -ForegroundColor Yellow
-Start-Process powershell -ArgumentList "-NoExit", "-Command", "Set-Location '$rootPath\server\naming-service'; go run main.go"
+Start-Process powershell -ArgumentList "-NoExit", "-Command", "Set-Location '$rootPath\server\naming-service'; go run ."
 
 Start-Sleep -Seconds 2
 